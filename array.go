@@ -0,0 +1,490 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// Arrays are stored as their elements one after the other. Each
+// element starts with a tag, followed for non-NULL elements by the
+// length of the element and its bytes. An empty array has no bytes
+// but unlike NULL isn't nil.
+
+const (
+	arrayNullTag  byte = 0
+	arrayValueTag byte = 1
+)
+
+// encodeArray stores a list of elements, where NULLs are nil
+func encodeArray(elements []memoryCell) memoryCell {
+	mc := memoryCell{}
+	for _, element := range elements {
+		if element == nil {
+			mc = append(mc, arrayNullTag)
+			continue
+		}
+
+		length := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(length, uint64(len(element)))
+		mc = append(mc, arrayValueTag)
+		mc = append(mc, length[:n]...)
+		mc = append(mc, element...)
+	}
+
+	return mc
+}
+
+// decodeArray lists the elements of an array, with nil for NULLs
+func decodeArray(mc memoryCell) []memoryCell {
+	if mc == nil {
+		return nil
+	}
+
+	elements := []memoryCell{}
+	for i := 0; i < len(mc); {
+		if mc[i] == arrayNullTag {
+			elements = append(elements, nullMemoryCell)
+			i++
+			continue
+		}
+
+		length, n := binary.Uvarint(mc[i+1:])
+		start := i + 1 + n
+		elements = append(elements, append(memoryCell{}, mc[start:start+int(length)]...))
+		i = start + int(length)
+	}
+
+	return elements
+}
+
+// AsArray returns the elements of an array
+func (mc memoryCell) AsArray() *[]Cell {
+	if mc == nil {
+		return nil
+	}
+
+	cells := []Cell{}
+	for _, element := range decodeArray(mc) {
+		cells = append(cells, element)
+	}
+
+	return &cells
+}
+
+// mapArray applies fn to each non-NULL element of an array
+func mapArray(value memoryCell, fn func(memoryCell) (memoryCell, error)) (memoryCell, error) {
+	elements := decodeArray(value)
+	for i, element := range elements {
+		if element == nil {
+			continue
+		}
+
+		var err error
+		elements[i], err = fn(element)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return encodeArray(elements), nil
+}
+
+// compareArrays orders two arrays of elements of type typ element by
+// element. NULL elements sort after other values and an array sorts
+// before the longer arrays it starts.
+func compareArrays(a, b memoryCell, typ ColumnType) int {
+	as, bs := decodeArray(a), decodeArray(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		switch {
+		case as[i] == nil && bs[i] == nil:
+			continue
+		case as[i] == nil:
+			return 1
+		case bs[i] == nil:
+			return -1
+		}
+
+		if c := compareCells(as[i], bs[i], typ); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+
+	return 0
+}
+
+// commonElementType is the type elements of types a and b are
+// converted to when arrays of them are combined or compared
+func commonElementType(a, b ColumnType) (ColumnType, bool) {
+	if a == b {
+		return a, true
+	}
+
+	if isNumericType(a) && isNumericType(b) {
+		return commonNumericType(a, b), true
+	}
+
+	return 0, false
+}
+
+// arrayElementsAs lists the elements of an array of type from
+// converted to the element type to
+func arrayElementsAs(value memoryCell, from, to ColumnType) ([]memoryCell, error) {
+	elements := decodeArray(value)
+	for i, element := range elements {
+		if element == nil || from == to {
+			continue
+		}
+
+		var err error
+		elements[i], err = castCell(element, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return elements, nil
+}
+
+// isArraySpace reports whether c is whitespace that may surround the
+// elements of an array written as text
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// parseTextArray reads an array of text written like {a,"b c",NULL},
+// where NULL elements are nil
+func parseTextArray(s string) ([]memoryCell, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, ErrInvalidCast
+	}
+
+	body := s[1 : len(s)-1]
+	elements := []memoryCell{}
+	if strings.TrimSpace(body) == "" {
+		return elements, nil
+	}
+
+	i := 0
+	for {
+		for i < len(body) && isArraySpace(body[i]) {
+			i++
+		}
+
+		if i < len(body) && body[i] == '"' {
+			element := memoryCell{}
+			for i++; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' && i+1 < len(body) {
+					i++
+				}
+
+				element = append(element, body[i])
+			}
+
+			if i == len(body) {
+				return nil, ErrInvalidCast
+			}
+
+			elements = append(elements, element)
+			i++
+			for i < len(body) && isArraySpace(body[i]) {
+				i++
+			}
+		} else {
+			end := strings.IndexByte(body[i:], ',')
+			if end == -1 {
+				end = len(body) - i
+			}
+
+			text := strings.TrimSpace(body[i : i+end])
+			if text == "" || strings.ContainsAny(text, "{}\"") {
+				return nil, ErrInvalidCast
+			}
+
+			if strings.EqualFold(text, "null") {
+				elements = append(elements, nullMemoryCell)
+			} else {
+				elements = append(elements, memoryCell(text))
+			}
+
+			i += end
+		}
+
+		if i == len(body) {
+			return elements, nil
+		}
+
+		if body[i] != ',' {
+			return nil, ErrInvalidCast
+		}
+
+		i++
+	}
+}
+
+// arrayToText writes an array of type typ like Postgres does, such as
+// {1,2,NULL} or {"a b",c}. Elements are quoted when they would
+// otherwise be read back differently.
+func arrayToText(value memoryCell, typ ColumnType) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, element := range decodeArray(value) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		if element == nil {
+			b.WriteString("NULL")
+			continue
+		}
+
+		text := arrayElementText(element, typ.ElementType())
+		if text != "" && !strings.EqualFold(text, "null") && !strings.ContainsAny(text, "{},\"\\ \t\n\r\v\f") {
+			b.WriteString(text)
+			continue
+		}
+
+		b.WriteByte('"')
+		for j := 0; j < len(text); j++ {
+			if text[j] == '"' || text[j] == '\\' {
+				b.WriteByte('\\')
+			}
+
+			b.WriteByte(text[j])
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// arrayElementText is the text of a non-NULL element, where booleans
+// are t or f
+func arrayElementText(element memoryCell, typ ColumnType) string {
+	if typ == BoolType {
+		if *element.AsBool() {
+			return "t"
+		}
+
+		return "f"
+	}
+
+	text, err := castCell(element, typ, TextType)
+	if err != nil {
+		return ""
+	}
+
+	return string(text)
+}
+
+// castArray converts a non-NULL value between types where one is an
+// array. Arrays are read from and written as text like {1,2,NULL}
+// and converted to arrays of other types element by element.
+func castArray(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	switch {
+	case from == TextType && to.IsArray():
+		elements, err := parseTextArray(*value.AsText())
+		if err != nil {
+			return nil, err
+		}
+
+		return mapArray(encodeArray(elements), func(element memoryCell) (memoryCell, error) {
+			return castCell(element, TextType, to.ElementType())
+		})
+	case from.IsArray() && to == TextType:
+		return memoryCell(arrayToText(value, from)), nil
+	case from.IsArray() && to.IsArray():
+		elements, err := arrayElementsAs(value, from.ElementType(), to.ElementType())
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeArray(elements), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// concatArrays implements || when either side is an array: arrays
+// are joined, and an element is added to the start or end of an
+// array. A NULL array is treated as an empty one.
+func concatArrays(l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, ColumnType, error) {
+	le, re := lt.ElementType(), rt.ElementType()
+	typ, ok := commonElementType(le, re)
+	if !ok {
+		return nil, 0, ErrInvalidOperands
+	}
+
+	elements := []memoryCell{}
+	for _, side := range []struct {
+		value memoryCell
+		typ   ColumnType
+	}{{l, lt}, {r, rt}} {
+		if !side.typ.IsArray() {
+			element := side.value
+			if element != nil && side.typ != typ {
+				var err error
+				element, err = castCell(element, side.typ, typ)
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+
+			elements = append(elements, element)
+			continue
+		}
+
+		sideElements, err := arrayElementsAs(side.value, side.typ.ElementType(), typ)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		elements = append(elements, sideElements...)
+	}
+
+	return encodeArray(elements), ArrayType | typ, nil
+}
+
+// arrayOperator applies @>, <@ or && to two non-NULL arrays. NULL
+// elements never match.
+func arrayOperator(op Symbol, l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, error) {
+	if !lt.IsArray() || !rt.IsArray() {
+		return nil, ErrInvalidOperands
+	}
+
+	typ, ok := commonElementType(lt.ElementType(), rt.ElementType())
+	if !ok {
+		return nil, ErrInvalidOperands
+	}
+
+	le, err := arrayElementsAs(l, lt.ElementType(), typ)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := arrayElementsAs(r, rt.ElementType(), typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == ContainedBySymbol {
+		le, re = re, le
+	}
+
+	has := func(elements []memoryCell, element memoryCell) bool {
+		for _, e := range elements {
+			if e != nil && compareCells(e, element, typ) == 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	result := op != OverlapSymbol
+	for _, element := range re {
+		if element == nil || !has(le, element) {
+			if op != OverlapSymbol {
+				result = false
+				break
+			}
+
+			continue
+		}
+
+		if op == OverlapSymbol {
+			result = true
+			break
+		}
+	}
+
+	if result {
+		return trueMemoryCell, nil
+	}
+
+	return falseMemoryCell, nil
+}
+
+// arrayEntries lists the entries a GIN index holds for an array: the
+// key of each distinct non-NULL element
+func arrayEntries(value memoryCell, typ ColumnType) [][]byte {
+	entries := [][]byte{}
+	for _, element := range decodeArray(value) {
+		if element == nil {
+			continue
+		}
+
+		entry := appendKey(nil, element, typ.ElementType())
+		duplicate := false
+		for _, e := range entries {
+			if bytes.Equal(e, entry) {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// arrayLength implements array_length(array, dimension). Arrays only
+// have one dimension, and empty arrays have none.
+func arrayLength(args []Cell) (interface{}, error) {
+	elements := *args[0].AsArray()
+	if *args[1].AsInt() != 1 || len(elements) == 0 {
+		return nil, nil
+	}
+
+	return len(elements), nil
+}
+
+// arrayToString joins the non-NULL elements of an array of type typ
+// with the separator given as second argument
+func arrayToString(typ ColumnType) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		texts := []string{}
+		for _, element := range decodeArray(args[0].(memoryCell)) {
+			if element != nil {
+				texts = append(texts, arrayElementText(element, typ))
+			}
+		}
+
+		return strings.Join(texts, *args[1].AsText()), nil
+	}
+}
+
+func init() {
+	elementTypes := []ColumnType{
+		TextType, BoolType, SmallIntType, IntType, BigIntType, RealType, DoubleType, NumericType,
+		DateType, TimeType, TimestampType, IntervalType, ByteaType, UUIDType, JSONType, JSONBType,
+	}
+	for _, typ := range elementTypes {
+		array := ArrayType | typ
+		mustRegisterFunction("cardinality", FunctionSignature{Args: []ColumnType{array}, Returns: IntType}, func(args []Cell) (interface{}, error) {
+			return len(*args[0].AsArray()), nil
+		})
+		mustRegisterFunction("array_length", FunctionSignature{Args: []ColumnType{array, IntType}, Returns: IntType}, arrayLength)
+		mustRegisterFunction("array_append", FunctionSignature{Args: []ColumnType{array, typ}, Returns: array}, func(args []Cell) (interface{}, error) {
+			elements := []interface{}{}
+			for _, element := range *args[0].AsArray() {
+				elements = append(elements, element)
+			}
+
+			return append(elements, args[1]), nil
+		})
+		mustRegisterFunction("array_to_string", FunctionSignature{Args: []ColumnType{array, TextType}, Returns: TextType}, arrayToString(typ))
+	}
+}
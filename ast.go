@@ -10,22 +10,313 @@ type ExpressionKind uint
 const (
 	LiteralKind ExpressionKind = iota
 	BinaryKind
+	QualifiedKind
+	CallKind
+	SubqueryKind
+	InKind
+	UnaryKind
+	IsKind
+	BetweenKind
+	CaseKind
+	ConditionalKind
+	CastKind
+	ArrayKind
+	SubscriptKind
 )
 
 type BinaryExpression struct {
 	A  Expression
 	B  Expression
 	Op Token
+
+	// Escape is the escape character of a LIKE or ILIKE pattern
+	// when it isn't the default backslash
+	Escape *Expression
+
+	// Quantifier is ANY or ALL when a comparison is made with each
+	// element of B, an array or a subquery
+	Quantifier *Token
 }
 
 func (be BinaryExpression) GenerateCode() string {
+	if be.Quantifier != nil {
+		b := be.B.GenerateCode()
+		if be.B.Kind != SubqueryKind {
+			b = "(" + b + ")"
+		}
+
+		return fmt.Sprintf("(%s %s %s%s)", be.A.GenerateCode(), be.Op.Value, strings.ToUpper(be.Quantifier.Value), b)
+	}
+
+	if be.Escape != nil {
+		return fmt.Sprintf("(%s %s %s ESCAPE %s)", be.A.GenerateCode(), be.Op.Value, be.B.GenerateCode(), be.Escape.GenerateCode())
+	}
+
 	return fmt.Sprintf("(%s %s %s)", be.A.GenerateCode(), be.Op.Value, be.B.GenerateCode())
 }
 
+// UnaryExpression is a prefix operator applied to an expression,
+// e.g. NOT a or -b
+type UnaryExpression struct {
+	Op  Token
+	Exp Expression
+}
+
+func (ue UnaryExpression) GenerateCode() string {
+	if ue.Op.Kind == KeywordKind {
+		return fmt.Sprintf("(%s %s)", ue.Op.Value, ue.Exp.GenerateCode())
+	}
+
+	return fmt.Sprintf("(%s%s)", ue.Op.Value, ue.Exp.GenerateCode())
+}
+
+// QualifiedColumn is a column reference prefixed by the table or
+// alias it belongs to, e.g. users.id
+type QualifiedColumn struct {
+	Table  Token
+	Column Token
+}
+
+func (qc QualifiedColumn) GenerateCode() string {
+	return fmt.Sprintf("\"%s\".\"%s\"", qc.Table.Value, qc.Column.Value)
+}
+
+// CallExpression is a function call such as lower(name) or count(*).
+// Calls named by a keyword, like CURRENT_DATE, have no parens.
+type CallExpression struct {
+	Name     Token
+	Args     *[]*Expression
+	Asterisk bool // for count(*)
+	Distinct bool // for count(DISTINCT x)
+}
+
+func (ce CallExpression) GenerateCode() string {
+	if ce.Asterisk {
+		return ce.Name.Value + "(*)"
+	}
+
+	if ce.Name.Kind == KeywordKind {
+		return strings.ToUpper(ce.Name.Value)
+	}
+
+	args := []string{}
+	for _, arg := range *ce.Args {
+		args = append(args, arg.GenerateCode())
+	}
+
+	distinct := ""
+	if ce.Distinct {
+		distinct = "DISTINCT "
+	}
+
+	return fmt.Sprintf("%s(%s%s)", ce.Name.Value, distinct, strings.Join(args, ", "))
+}
+
+// SubqueryExpression is a SELECT used as a value, which must return
+// at most one row of one column. With Exists it is instead whether
+// the SELECT returns any rows.
+type SubqueryExpression struct {
+	Select *SelectStatement
+	Exists bool
+}
+
+func (se SubqueryExpression) GenerateCode() string {
+	code := "(" + se.Select.selectCode() + ")"
+	if se.Exists {
+		return "EXISTS " + code
+	}
+
+	return code
+}
+
+// InExpression checks whether a value is among the rows returned by
+// a subquery or, when List is set instead, among a list of values
+type InExpression struct {
+	Exp      Expression
+	Not      bool
+	Subquery *SelectStatement
+	List     *[]*Expression
+}
+
+func (ie InExpression) GenerateCode() string {
+	op := "IN"
+	if ie.Not {
+		op = "NOT IN"
+	}
+
+	if ie.List != nil {
+		items := []string{}
+		for _, item := range *ie.List {
+			items = append(items, item.GenerateCode())
+		}
+
+		return fmt.Sprintf("(%s %s (%s))", ie.Exp.GenerateCode(), op, strings.Join(items, ", "))
+	}
+
+	return fmt.Sprintf("(%s %s (%s))", ie.Exp.GenerateCode(), op, ie.Subquery.selectCode())
+}
+
+// BetweenExpression checks whether a value is within an inclusive
+// range
+type BetweenExpression struct {
+	Exp  Expression
+	Not  bool
+	Low  Expression
+	High Expression
+}
+
+func (be BetweenExpression) GenerateCode() string {
+	op := "BETWEEN"
+	if be.Not {
+		op = "NOT BETWEEN"
+	}
+
+	return fmt.Sprintf("(%s %s %s AND %s)", be.Exp.GenerateCode(), op, be.Low.GenerateCode(), be.High.GenerateCode())
+}
+
+// IsExpression is IS [NOT] NULL or, with DistinctFrom set, IS [NOT]
+// DISTINCT FROM. Unlike = these never return NULL.
+type IsExpression struct {
+	Exp          Expression
+	Not          bool
+	DistinctFrom *Expression
+}
+
+func (ie IsExpression) GenerateCode() string {
+	op := "IS"
+	if ie.Not {
+		op = "IS NOT"
+	}
+
+	if ie.DistinctFrom != nil {
+		return fmt.Sprintf("(%s %s DISTINCT FROM %s)", ie.Exp.GenerateCode(), op, ie.DistinctFrom.GenerateCode())
+	}
+
+	return fmt.Sprintf("(%s %s NULL)", ie.Exp.GenerateCode(), op)
+}
+
+type CaseWhen struct {
+	When Expression
+	Then Expression
+}
+
+// CaseExpression is a searched CASE when Operand is nil, otherwise a
+// simple CASE comparing Operand to each WHEN value
+type CaseExpression struct {
+	Operand *Expression
+	Whens   *[]*CaseWhen
+	Else    *Expression
+}
+
+func (ce CaseExpression) GenerateCode() string {
+	code := "CASE"
+	if ce.Operand != nil {
+		code += " " + ce.Operand.GenerateCode()
+	}
+
+	for _, when := range *ce.Whens {
+		code += fmt.Sprintf(" WHEN %s THEN %s", when.When.GenerateCode(), when.Then.GenerateCode())
+	}
+
+	if ce.Else != nil {
+		code += " ELSE " + ce.Else.GenerateCode()
+	}
+
+	return code + " END"
+}
+
+// ConditionalExpression is one of COALESCE, NULLIF, GREATEST and
+// LEAST, which unlike functions only evaluate the arguments they need
+type ConditionalExpression struct {
+	Function Token
+	Args     *[]*Expression
+}
+
+func (ce ConditionalExpression) GenerateCode() string {
+	args := []string{}
+	for _, arg := range *ce.Args {
+		args = append(args, arg.GenerateCode())
+	}
+
+	return fmt.Sprintf("%s(%s)", strings.ToUpper(ce.Function.Value), strings.Join(args, ", "))
+}
+
+// CastExpression converts a value to another type, written either as
+// CAST(x AS type) or x::type. Array is set for an array of the type,
+// like int[].
+type CastExpression struct {
+	Exp       Expression
+	Datatype  Token
+	Modifiers *[]*Token
+	Array     bool
+}
+
+func (ce CastExpression) GenerateCode() string {
+	return fmt.Sprintf("CAST(%s AS %s)", ce.Exp.GenerateCode(), datatypeCode(ce.Datatype, ce.Modifiers, ce.Array))
+}
+
+// datatypeCode writes a type along with its modifiers, like the
+// precision and scale in NUMERIC(10, 2)
+func datatypeCode(datatype Token, modifiers *[]*Token, array bool) string {
+	code := strings.ToUpper(datatype.Value)
+	if modifiers != nil {
+		values := []string{}
+		for _, modifier := range *modifiers {
+			values = append(values, modifier.Value)
+		}
+
+		code = fmt.Sprintf("%s(%s)", code, strings.Join(values, ", "))
+	}
+
+	if array {
+		code += "[]"
+	}
+
+	return code
+}
+
+// ArrayExpression is an array built from a list of values, like
+// ARRAY[1, 2]
+type ArrayExpression struct {
+	Items *[]*Expression
+}
+
+func (ae ArrayExpression) GenerateCode() string {
+	items := []string{}
+	for _, item := range *ae.Items {
+		items = append(items, item.GenerateCode())
+	}
+
+	return fmt.Sprintf("ARRAY[%s]", strings.Join(items, ", "))
+}
+
+// SubscriptExpression is an element of an array, e.g. tags[1].
+// Positions start at 1.
+type SubscriptExpression struct {
+	Exp   Expression
+	Index Expression
+}
+
+func (se SubscriptExpression) GenerateCode() string {
+	return fmt.Sprintf("%s[%s]", se.Exp.GenerateCode(), se.Index.GenerateCode())
+}
+
 type Expression struct {
-	Literal *Token
-	Binary  *BinaryExpression
-	Kind    ExpressionKind
+	Literal     *Token
+	Binary      *BinaryExpression
+	Qualified   *QualifiedColumn
+	Call        *CallExpression
+	Subquery    *SubqueryExpression
+	In          *InExpression
+	Unary       *UnaryExpression
+	Is          *IsExpression
+	Between     *BetweenExpression
+	Case        *CaseExpression
+	Conditional *ConditionalExpression
+	Cast        *CastExpression
+	Array       *ArrayExpression
+	Subscript   *SubscriptExpression
+	Kind        ExpressionKind
 }
 
 func (e Expression) GenerateCode() string {
@@ -42,6 +333,30 @@ func (e Expression) GenerateCode() string {
 
 	case BinaryKind:
 		return e.Binary.GenerateCode()
+	case QualifiedKind:
+		return e.Qualified.GenerateCode()
+	case CallKind:
+		return e.Call.GenerateCode()
+	case SubqueryKind:
+		return e.Subquery.GenerateCode()
+	case InKind:
+		return e.In.GenerateCode()
+	case UnaryKind:
+		return e.Unary.GenerateCode()
+	case IsKind:
+		return e.Is.GenerateCode()
+	case BetweenKind:
+		return e.Between.GenerateCode()
+	case CaseKind:
+		return e.Case.GenerateCode()
+	case ConditionalKind:
+		return e.Conditional.GenerateCode()
+	case CastKind:
+		return e.Cast.GenerateCode()
+	case ArrayKind:
+		return e.Array.GenerateCode()
+	case SubscriptKind:
+		return e.Subscript.GenerateCode()
 	}
 
 	return ""
@@ -53,15 +368,191 @@ type SelectItem struct {
 	As       *Token
 }
 
+type JoinKind uint
+
+const (
+	InnerJoinKind JoinKind = iota
+	LeftJoinKind
+	RightJoinKind
+	FullJoinKind
+	CrossJoinKind
+)
+
+func (jk JoinKind) GenerateCode() string {
+	switch jk {
+	case LeftJoinKind:
+		return "LEFT JOIN"
+	case RightJoinKind:
+		return "RIGHT JOIN"
+	case FullJoinKind:
+		return "FULL JOIN"
+	case CrossJoinKind:
+		return "CROSS JOIN"
+	default:
+		return "INNER JOIN"
+	}
+}
+
+// FromItem is a table in the FROM clause, or when Function is set a
+// set-returning function like unnest(tags). Join and On describe how
+// it is combined with the items before it and are ignored on the
+// first item.
+type FromItem struct {
+	Table    Token
+	Function *CallExpression
+	As       *Token
+	Join     JoinKind
+	On       *Expression
+}
+
+func (fi FromItem) GenerateCode() string {
+	code := fmt.Sprintf("\"%s\"", fi.Table.Value)
+	if fi.Function != nil {
+		code = fi.Function.GenerateCode()
+	}
+
+	if fi.As != nil {
+		return fmt.Sprintf("%s AS \"%s\"", code, fi.As.Value)
+	}
+
+	return code
+}
+
+// NullsOrder is where NULLs sort in an ORDER BY item. By default
+// they sort as if larger than any other value.
+type NullsOrder uint
+
+const (
+	DefaultNullsOrder NullsOrder = iota
+	NullsFirstOrder
+	NullsLastOrder
+)
+
+type OrderByItem struct {
+	Exp   *Expression
+	Desc  bool
+	Nulls NullsOrder
+}
+
+func (obi OrderByItem) GenerateCode() string {
+	code := obi.Exp.GenerateCode()
+	if obi.Desc {
+		code += " DESC"
+	}
+
+	switch obi.Nulls {
+	case NullsFirstOrder:
+		code += " NULLS FIRST"
+	case NullsLastOrder:
+		code += " NULLS LAST"
+	}
+
+	return code
+}
+
+// NullsFirst reports whether NULLs sort before other values
+func (obi OrderByItem) NullsFirst() bool {
+	if obi.Nulls == DefaultNullsOrder {
+		return obi.Desc
+	}
+
+	return obi.Nulls == NullsFirstOrder
+}
+
+type SetOperator uint
+
+const (
+	UnionOperator SetOperator = iota
+	IntersectOperator
+	ExceptOperator
+)
+
+func (so SetOperator) GenerateCode() string {
+	switch so {
+	case IntersectOperator:
+		return "INTERSECT"
+	case ExceptOperator:
+		return "EXCEPT"
+	default:
+		return "UNION"
+	}
+}
+
+// SetOperation combines the rows of two SELECTs. Duplicate rows are
+// removed from the result unless All is set.
+type SetOperation struct {
+	Op    SetOperator
+	All   bool
+	Left  *SelectStatement
+	Right *SelectStatement
+}
+
+// columnNamesCode quotes and joins a list of column names
+func columnNamesCode(columns []*Token) string {
+	names := []string{}
+	for _, column := range columns {
+		names = append(names, fmt.Sprintf("\"%s\"", column.Value))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// CommonTableExpression is a named SELECT in a WITH clause, which
+// the statement it prefixes can read from like a table
+type CommonTableExpression struct {
+	Name    Token
+	Columns *[]*Token
+	Select  *SelectStatement
+}
+
+func (cte CommonTableExpression) GenerateCode() string {
+	code := fmt.Sprintf("\"%s\"", cte.Name.Value)
+	if cte.Columns != nil {
+		code += fmt.Sprintf(" (%s)", columnNamesCode(*cte.Columns))
+	}
+
+	return fmt.Sprintf("%s AS (%s)", code, cte.Select.selectCode())
+}
+
+// SelectStatement is either a single SELECT or, when SetOperation is
+// set, a combination of SELECTs. In the second case only OrderBy,
+// Limit and Offset are used and they apply to the combined rows.
 type SelectStatement struct {
-	Item   *[]*SelectItem
-	From   *Token
-	Where  *Expression
-	Limit  *Expression
-	Offset *Expression
+	// With lists the common table expressions the statement can
+	// read from. With Recursive they can also read from themselves.
+	With      *[]*CommonTableExpression
+	Recursive bool
+
+	SetOperation *SetOperation
+
+	// Distinct removes duplicate rows, or rows with the same values
+	// of DistinctOn when it is set
+	Distinct   bool
+	DistinctOn *[]*Expression
+
+	Item    *[]*SelectItem
+	From    *[]*FromItem
+	Where   *Expression
+	GroupBy *[]*Expression
+	Having  *Expression
+	OrderBy *[]*OrderByItem
+	Limit   *Expression
+	Offset  *Expression
 }
 
-func (ss SelectStatement) GenerateCode() string {
+// coreCode generates everything up to and including HAVING, or the
+// combined SELECTs of a set operation
+func (ss SelectStatement) coreCode() string {
+	if ss.SetOperation != nil {
+		so := ss.SetOperation
+		op := so.Op.GenerateCode()
+		if so.All {
+			op += " ALL"
+		}
+
+		return fmt.Sprintf("%s\n%s\n%s", so.Left.coreCode(), op, so.Right.coreCode())
+	}
+
 	item := []string{}
 	for _, i := range *ss.Item {
 		s := "\t*"
@@ -75,35 +566,199 @@ func (ss SelectStatement) GenerateCode() string {
 		item = append(item, s)
 	}
 
-	code := "SELECT\n" + strings.Join(item, ",\n")
+	code := "SELECT"
+	if ss.DistinctOn != nil {
+		on := []string{}
+		for _, exp := range *ss.DistinctOn {
+			on = append(on, exp.GenerateCode())
+		}
+		code += fmt.Sprintf(" DISTINCT ON (%s)", strings.Join(on, ", "))
+	} else if ss.Distinct {
+		code += " DISTINCT"
+	}
+
+	code += "\n" + strings.Join(item, ",\n")
 	if ss.From != nil {
-		code += fmt.Sprintf("\nFROM\n\t\"%s\"", ss.From.Value)
+		code += "\nFROM"
+		for i, fi := range *ss.From {
+			if i == 0 {
+				code += "\n\t" + fi.GenerateCode()
+				continue
+			}
+
+			code += fmt.Sprintf("\n\t%s %s", fi.Join.GenerateCode(), fi.GenerateCode())
+			if fi.On != nil {
+				code += " ON " + fi.On.GenerateCode()
+			}
+		}
 	}
 
 	if ss.Where != nil {
 		code += "\nWHERE\n\t" + ss.Where.GenerateCode()
 	}
 
+	if ss.GroupBy != nil {
+		groupBy := []string{}
+		for _, exp := range *ss.GroupBy {
+			groupBy = append(groupBy, "\t"+exp.GenerateCode())
+		}
+		code += "\nGROUP BY\n" + strings.Join(groupBy, ",\n")
+	}
+
+	if ss.Having != nil {
+		code += "\nHAVING\n\t" + ss.Having.GenerateCode()
+	}
+
+	return code
+}
+
+// selectCode generates the statement without a trailing semicolon
+func (ss SelectStatement) selectCode() string {
+	code := ""
+	if ss.With != nil {
+		code = "WITH "
+		if ss.Recursive {
+			code += "RECURSIVE "
+		}
+
+		with := []string{}
+		for _, cte := range *ss.With {
+			with = append(with, cte.GenerateCode())
+		}
+		code += strings.Join(with, ",\n") + "\n"
+	}
+
+	code += ss.coreCode()
+	if ss.OrderBy != nil {
+		orderBy := []string{}
+		for _, item := range *ss.OrderBy {
+			orderBy = append(orderBy, "\t"+item.GenerateCode())
+		}
+		code += "\nORDER BY\n" + strings.Join(orderBy, ",\n")
+	}
+
 	if ss.Limit != nil {
 		code += "\nLIMIT\n\t" + ss.Limit.GenerateCode()
 	}
 
 	if ss.Offset != nil {
-		code += "\nOFFSET\n\t" + ss.Limit.GenerateCode()
+		code += "\nOFFSET\n\t" + ss.Offset.GenerateCode()
 	}
 
-	return code + ";"
+	return code
+}
+
+func (ss SelectStatement) GenerateCode() string {
+	return ss.selectCode() + ";"
 }
 
 type ColumnDefinition struct {
 	Name       Token
 	Datatype   Token
+	Modifiers  *[]*Token
+	Array      bool
 	PrimaryKey bool
+	Unique     bool
+	NotNull    bool
+	// Default is the value of the column when an insert leaves it
+	// out, or nil for NULL
+	Default *Expression
+	// Check must not be false for any value of the column
+	Check *Expression
+	// References is a foreign key on the column
+	References *ForeignKeyDefinition
+}
+
+// ReferentialAction is what happens to the rows referencing a row
+// when it is deleted or its key is updated
+type ReferentialAction uint
+
+const (
+	NoActionReferentialAction ReferentialAction = iota
+	RestrictReferentialAction
+	CascadeReferentialAction
+	SetNullReferentialAction
+)
+
+func (ra ReferentialAction) GenerateCode() string {
+	switch ra {
+	case RestrictReferentialAction:
+		return "RESTRICT"
+	case CascadeReferentialAction:
+		return "CASCADE"
+	case SetNullReferentialAction:
+		return "SET NULL"
+	default:
+		return "NO ACTION"
+	}
+}
+
+// ForeignKeyDefinition is a REFERENCES constraint on a column or a
+// FOREIGN KEY constraint on columns of a table
+type ForeignKeyDefinition struct {
+	// Columns are the referencing columns, or nil for the column
+	// the constraint is on
+	Columns *[]*Token
+	Table   Token
+	// RefColumns are the referenced columns, or nil for the
+	// primary key of Table
+	RefColumns *[]*Token
+	OnDelete   ReferentialAction
+	OnUpdate   ReferentialAction
+}
+
+func (fk ForeignKeyDefinition) GenerateCode() string {
+	code := ""
+	if fk.Columns != nil {
+		code = fmt.Sprintf("FOREIGN KEY (%s) ", columnNamesCode(*fk.Columns))
+	}
+
+	code += fmt.Sprintf("REFERENCES \"%s\"", fk.Table.Value)
+	if fk.RefColumns != nil {
+		code += fmt.Sprintf(" (%s)", columnNamesCode(*fk.RefColumns))
+	}
+
+	if fk.OnDelete != NoActionReferentialAction {
+		code += " ON DELETE " + fk.OnDelete.GenerateCode()
+	}
+
+	if fk.OnUpdate != NoActionReferentialAction {
+		code += " ON UPDATE " + fk.OnUpdate.GenerateCode()
+	}
+
+	return code
+}
+
+// TableConstraint is a constraint given in the list of columns of a
+// table rather than on one column. One of Check, ForeignKey,
+// PrimaryKey and Unique is set.
+type TableConstraint struct {
+	Check      *Expression
+	ForeignKey *ForeignKeyDefinition
+	PrimaryKey *[]*Token
+	Unique     *[]*Token
+}
+
+func (tc TableConstraint) GenerateCode() string {
+	if tc.ForeignKey != nil {
+		return tc.ForeignKey.GenerateCode()
+	}
+
+	if tc.PrimaryKey != nil {
+		return fmt.Sprintf("PRIMARY KEY (%s)", columnNamesCode(*tc.PrimaryKey))
+	}
+
+	if tc.Unique != nil {
+		return fmt.Sprintf("UNIQUE (%s)", columnNamesCode(*tc.Unique))
+	}
+
+	return fmt.Sprintf("CHECK (%s)", tc.Check.GenerateCode())
 }
 
 type CreateTableStatement struct {
-	Name Token
-	Cols *[]*ColumnDefinition
+	Name        Token
+	Cols        *[]*ColumnDefinition
+	Constraints *[]*TableConstraint
 }
 
 func (cts CreateTableStatement) GenerateCode() string {
@@ -113,9 +768,29 @@ func (cts CreateTableStatement) GenerateCode() string {
 		if col.PrimaryKey {
 			modifiers += " " + "PRIMARY KEY"
 		}
-		spec := fmt.Sprintf("\t\"%s\" %s%s", col.Name.Value, strings.ToUpper(col.Datatype.Value), modifiers)
+		if col.Unique {
+			modifiers += " UNIQUE"
+		}
+		if col.NotNull {
+			modifiers += " NOT NULL"
+		}
+		if col.Default != nil {
+			modifiers += " DEFAULT " + col.Default.GenerateCode()
+		}
+		if col.Check != nil {
+			modifiers += fmt.Sprintf(" CHECK (%s)", col.Check.GenerateCode())
+		}
+		if col.References != nil {
+			modifiers += " " + col.References.GenerateCode()
+		}
+		spec := fmt.Sprintf("\t\"%s\" %s%s", col.Name.Value, datatypeCode(col.Datatype, col.Modifiers, col.Array), modifiers)
 		cols = append(cols, spec)
 	}
+	if cts.Constraints != nil {
+		for _, constraint := range *cts.Constraints {
+			cols = append(cols, "\t"+constraint.GenerateCode())
+		}
+	}
 	return fmt.Sprintf("CREATE TABLE \"%s\" (\n%s\n);", cts.Name.Value, strings.Join(cols, ",\n"))
 }
 
@@ -124,7 +799,10 @@ type CreateIndexStatement struct {
 	Unique     bool
 	PrimaryKey bool
 	Table      Token
-	Exp        Expression
+	// Method is the index method given with USING, like gin, or
+	// nil for the default
+	Method *Token
+	Exps   *[]*Expression
 }
 
 func (cis CreateIndexStatement) GenerateCode() string {
@@ -132,7 +810,15 @@ func (cis CreateIndexStatement) GenerateCode() string {
 	if cis.Unique {
 		unique = " UNIQUE"
 	}
-	return fmt.Sprintf("CREATE%s INDEX \"%s\" ON \"%s\" (%s);", unique, cis.Name.Value, cis.Table.Value, cis.Exp.GenerateCode())
+	using := ""
+	if cis.Method != nil {
+		using = " USING " + cis.Method.Value
+	}
+	exps := []string{}
+	for _, exp := range *cis.Exps {
+		exps = append(exps, exp.GenerateCode())
+	}
+	return fmt.Sprintf("CREATE%s INDEX \"%s\" ON \"%s\"%s (%s);", unique, cis.Name.Value, cis.Table.Value, using, strings.Join(exps, ", "))
 }
 
 type DropTableStatement struct {
@@ -144,16 +830,63 @@ func (dts DropTableStatement) GenerateCode() string {
 }
 
 type InsertStatement struct {
-	Table  Token
-	Values *[]*Expression
+	Table Token
+	// Columns are the columns given values, in order, or nil for
+	// every column of the table
+	Columns *[]*Token
+	Values  *[]*Expression
 }
 
 func (is InsertStatement) GenerateCode() string {
+	columns := ""
+	if is.Columns != nil {
+		columns = fmt.Sprintf(" (%s)", columnNamesCode(*is.Columns))
+	}
+
 	values := []string{}
 	for _, exp := range *is.Values {
 		values = append(values, exp.GenerateCode())
 	}
-	return fmt.Sprintf("INSERT INTO \"%s\" VALUES (%s);", is.Table.Value, strings.Join(values, ", "))
+	return fmt.Sprintf("INSERT INTO \"%s\"%s VALUES (%s);", is.Table.Value, columns, strings.Join(values, ", "))
+}
+
+type SetItem struct {
+	Column Token
+	Exp    Expression
+}
+
+type UpdateStatement struct {
+	Table Token
+	Set   *[]*SetItem
+	Where *Expression
+}
+
+func (us UpdateStatement) GenerateCode() string {
+	set := []string{}
+	for _, s := range *us.Set {
+		set = append(set, fmt.Sprintf("\t\"%s\" = %s", s.Column.Value, s.Exp.GenerateCode()))
+	}
+
+	code := fmt.Sprintf("UPDATE \"%s\"\nSET\n%s", us.Table.Value, strings.Join(set, ",\n"))
+	if us.Where != nil {
+		code += "\nWHERE\n\t" + us.Where.GenerateCode()
+	}
+
+	return code + ";"
+}
+
+type DeleteStatement struct {
+	Table Token
+	Where *Expression
+}
+
+func (ds DeleteStatement) GenerateCode() string {
+	code := fmt.Sprintf("DELETE FROM \"%s\"", ds.Table.Value)
+	if ds.Where != nil {
+		code += "\nWHERE\n\t" + ds.Where.GenerateCode()
+	}
+
+	return code + ";"
 }
 
 type AstKind uint
@@ -164,6 +897,8 @@ const (
 	CreateIndexKind
 	DropTableKind
 	InsertKind
+	UpdateKind
+	DeleteKind
 )
 
 type Statement struct {
@@ -172,6 +907,8 @@ type Statement struct {
 	CreateIndexStatement *CreateIndexStatement
 	DropTableStatement   *DropTableStatement
 	InsertStatement      *InsertStatement
+	UpdateStatement      *UpdateStatement
+	DeleteStatement      *DeleteStatement
 	Kind                 AstKind
 }
 
@@ -187,6 +924,10 @@ func (s Statement) GenerateCode() string {
 		return s.DropTableStatement.GenerateCode()
 	case InsertKind:
 		return s.InsertStatement.GenerateCode()
+	case UpdateKind:
+		return s.UpdateStatement.GenerateCode()
+	case DeleteKind:
+		return s.DeleteStatement.GenerateCode()
 	}
 
 	return "?unknown?"
@@ -50,7 +50,9 @@ func TestStatement_GenerateCode(t *testing.T) {
 					Name:   Token{Value: "age_idx"},
 					Unique: true,
 					Table:  Token{Value: "users"},
-					Exp:    Expression{Literal: &Token{Value: "age", Kind: IdentifierKind}, Kind: LiteralKind},
+					Exps: &[]*Expression{
+						{Literal: &Token{Value: "age", Kind: IdentifierKind}, Kind: LiteralKind},
+					},
 				},
 				Kind: CreateIndexKind,
 			},
@@ -69,6 +71,64 @@ func TestStatement_GenerateCode(t *testing.T) {
 				Kind: InsertKind,
 			},
 		},
+		{
+			`UPDATE "users"
+SET
+	"name" = 'Max',
+	"age" = ("age" + 1)
+WHERE
+	("id" = 2);`,
+			Statement{
+				UpdateStatement: &UpdateStatement{
+					Table: Token{Value: "users"},
+					Set: &[]*SetItem{
+						{
+							Column: Token{Value: "name"},
+							Exp:    Expression{Literal: &Token{Value: "Max", Kind: StringKind}, Kind: LiteralKind},
+						},
+						{
+							Column: Token{Value: "age"},
+							Exp: Expression{
+								Binary: &BinaryExpression{
+									A:  Expression{Literal: &Token{Value: "age", Kind: IdentifierKind}, Kind: LiteralKind},
+									B:  Expression{Literal: &Token{Value: "1", Kind: NumericKind}, Kind: LiteralKind},
+									Op: Token{Value: "+", Kind: SymbolKind},
+								},
+								Kind: BinaryKind,
+							},
+						},
+					},
+					Where: &Expression{
+						Binary: &BinaryExpression{
+							A:  Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind},
+							B:  Expression{Literal: &Token{Value: "2", Kind: NumericKind}, Kind: LiteralKind},
+							Op: Token{Value: "=", Kind: SymbolKind},
+						},
+						Kind: BinaryKind,
+					},
+				},
+				Kind: UpdateKind,
+			},
+		},
+		{
+			`DELETE FROM "users"
+WHERE
+	("id" = 2);`,
+			Statement{
+				DeleteStatement: &DeleteStatement{
+					Table: Token{Value: "users"},
+					Where: &Expression{
+						Binary: &BinaryExpression{
+							A:  Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind},
+							B:  Expression{Literal: &Token{Value: "2", Kind: NumericKind}, Kind: LiteralKind},
+							Op: Token{Value: "=", Kind: SymbolKind},
+						},
+						Kind: BinaryKind,
+					},
+				},
+				Kind: DeleteKind,
+			},
+		},
 		{
 			`SELECT
 	"id",
@@ -83,7 +143,7 @@ WHERE
 						{Exp: &Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind}},
 						{Exp: &Expression{Literal: &Token{Value: "name", Kind: IdentifierKind}, Kind: LiteralKind}},
 					},
-					From: &Token{Value: "users"},
+					From: &[]*FromItem{{Table: Token{Value: "users"}}},
 					Where: &Expression{
 						Binary: &BinaryExpression{
 							A:  Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind},
@@ -96,6 +156,36 @@ WHERE
 				Kind: SelectKind,
 			},
 		},
+		{
+			`SELECT
+	*
+FROM
+	"users"
+	LEFT JOIN "orders" ON ("id" = "user_id")
+	CROSS JOIN "colors";`,
+			Statement{
+				SelectStatement: &SelectStatement{
+					Item: &[]*SelectItem{{Asterisk: true}},
+					From: &[]*FromItem{
+						{Table: Token{Value: "users"}},
+						{
+							Table: Token{Value: "orders"},
+							Join:  LeftJoinKind,
+							On: &Expression{
+								Binary: &BinaryExpression{
+									A:  Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind},
+									B:  Expression{Literal: &Token{Value: "user_id", Kind: IdentifierKind}, Kind: LiteralKind},
+									Op: Token{Value: "=", Kind: SymbolKind},
+								},
+								Kind: BinaryKind,
+							},
+						},
+						{Table: Token{Value: "colors"}, Join: CrossJoinKind},
+					},
+				},
+				Kind: SelectKind,
+			},
+		},
 	}
 
 	for _, test := range tests {
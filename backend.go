@@ -1,6 +1,10 @@
 package gosql
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"time"
+)
 
 type ColumnType uint
 
@@ -8,9 +12,40 @@ const (
 	TextType ColumnType = iota
 	IntType
 	BoolType
+	SmallIntType
+	BigIntType
+	RealType
+	DoubleType
+	NumericType
+	DateType
+	TimeType
+	TimestampType
+	IntervalType
+	ByteaType
+	UUIDType
+	JSONType
+	JSONBType
 )
 
+// ArrayType is combined with the type of the elements to make the
+// type of an array, like ArrayType | IntType for int[]
+const ArrayType ColumnType = 1 << 8
+
+// IsArray reports whether c is the type of an array
+func (c ColumnType) IsArray() bool {
+	return c&ArrayType != 0
+}
+
+// ElementType is the type of the elements of an array type
+func (c ColumnType) ElementType() ColumnType {
+	return c &^ ArrayType
+}
+
 func (c ColumnType) String() string {
+	if c.IsArray() {
+		return strings.TrimSuffix(c.ElementType().String(), "Type") + "ArrayType"
+	}
+
 	switch c {
 	case TextType:
 		return "TextType"
@@ -18,6 +53,32 @@ func (c ColumnType) String() string {
 		return "IntType"
 	case BoolType:
 		return "BoolType"
+	case SmallIntType:
+		return "SmallIntType"
+	case BigIntType:
+		return "BigIntType"
+	case RealType:
+		return "RealType"
+	case DoubleType:
+		return "DoubleType"
+	case NumericType:
+		return "NumericType"
+	case DateType:
+		return "DateType"
+	case TimeType:
+		return "TimeType"
+	case TimestampType:
+		return "TimestampType"
+	case IntervalType:
+		return "IntervalType"
+	case ByteaType:
+		return "ByteaType"
+	case UUIDType:
+		return "UUIDType"
+	case JSONType:
+		return "JSONType"
+	case JSONBType:
+		return "JSONBType"
 	default:
 		return "Error"
 	}
@@ -27,6 +88,31 @@ type Cell interface {
 	AsText() *string
 	AsInt() *int32
 	AsBool() *bool
+	AsSmallInt() *int16
+	AsBigInt() *int64
+	AsReal() *float32
+	AsDouble() *float64
+	// AsNumeric returns an arbitrary precision number in decimal
+	// notation, like "-12.5"
+	AsNumeric() *string
+	// AsDate returns midnight UTC of the date
+	AsDate() *time.Time
+	AsTime() *time.Time
+	// AsTimestamp returns the timestamp in UTC, timestamps don't
+	// have a time zone
+	AsTimestamp() *time.Time
+	AsInterval() *Interval
+	AsBytea() *[]byte
+	// AsUUID returns the UUID in its canonical form, like
+	// a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11
+	AsUUID() *string
+	// AsJSON returns the text of a JSON or JSONB value. JSON keeps
+	// the text it was given while JSONB is normalized.
+	AsJSON() *string
+	// AsArray returns the elements of an array, which are read as
+	// the element type of the column. The As methods of NULL
+	// elements return nil.
+	AsArray() *[]Cell
 }
 
 type Results struct {
@@ -48,10 +134,27 @@ type Index struct {
 	PrimaryKey bool
 }
 
+// ForeignKey is a FOREIGN KEY constraint of the Columns of a table
+// referencing the RefColumns of Table
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	Table      string
+	RefColumns []string
+	OnDelete   ReferentialAction
+	OnUpdate   ReferentialAction
+}
+
 type TableMetadata struct {
 	Name    string
 	Columns []ResultColumn
 	Indexes []Index
+	// Defaults holds the code of the default of each column, or
+	// an empty string for columns without one
+	Defaults []string
+	// Checks holds the code of each CHECK constraint
+	Checks      []string
+	ForeignKeys []ForeignKey
 }
 
 type Backend interface {
@@ -59,6 +162,8 @@ type Backend interface {
 	DropTable(*DropTableStatement) error
 	CreateIndex(*CreateIndexStatement) error
 	Insert(*InsertStatement) error
+	Update(*UpdateStatement) error
+	Delete(*DeleteStatement) error
 	Select(*SelectStatement) (*Results, error)
 	GetTables() []TableMetadata
 }
@@ -82,6 +187,14 @@ func (eb EmptyBackend) Insert(_ *InsertStatement) error {
 	return errors.New("Insert not supported")
 }
 
+func (eb EmptyBackend) Update(_ *UpdateStatement) error {
+	return errors.New("Update not supported")
+}
+
+func (eb EmptyBackend) Delete(_ *DeleteStatement) error {
+	return errors.New("Delete not supported")
+}
+
 func (eb EmptyBackend) Select(_ *SelectStatement) (*Results, error) {
 	return nil, errors.New("Select not supported")
 }
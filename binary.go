@@ -0,0 +1,220 @@
+package gosql
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// BYTEA values are stored as their bytes and UUIDs as their 16 bytes,
+// so both order the same as their stored bytes.
+
+const uuidSize = 16
+
+// AsBytea returns the bytes of a BYTEA value
+func (mc memoryCell) AsBytea() *[]byte {
+	if mc == nil {
+		return nil
+	}
+
+	b := append([]byte{}, mc...)
+	return &b
+}
+
+// AsUUID returns a UUID in its canonical form, like
+// a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11
+func (mc memoryCell) AsUUID() *string {
+	if len(mc) != uuidSize {
+		return nil
+	}
+
+	s := formatUUID(mc)
+	return &s
+}
+
+func isBinaryType(typ ColumnType) bool {
+	return typ == ByteaType || typ == UUIDType
+}
+
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// parseUUID reads 32 hex digits, optionally in braces and with
+// hyphens between groups of digits
+func parseUUID(s string) (memoryCell, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+
+	if strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-") || strings.Contains(s, "--") {
+		return nil, ErrInvalidCast
+	}
+
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != uuidSize {
+		return nil, ErrInvalidCast
+	}
+
+	return b, nil
+}
+
+// formatBytea writes bytes in the hex format Postgres outputs, like
+// \xdeadbeef
+func formatBytea(b []byte) string {
+	return `\x` + hex.EncodeToString(b)
+}
+
+// parseBytea reads the hex format, or otherwise the escape format
+// where \\ is a backslash and \ooo an octal byte
+func parseBytea(s string) (memoryCell, error) {
+	if strings.HasPrefix(s, `\x`) {
+		digits := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' {
+				return -1
+			}
+
+			return r
+		}, s[2:])
+
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, ErrInvalidCast
+		}
+
+		return b, nil
+	}
+
+	b := memoryCell{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '\\' {
+			b = append(b, '\\')
+			i++
+			continue
+		}
+
+		if i+3 >= len(s) {
+			return nil, ErrInvalidCast
+		}
+
+		octal := s[i+1 : i+4]
+		if octal[0] < '0' || octal[0] > '3' || !isOctalDigit(octal[1]) || !isOctalDigit(octal[2]) {
+			return nil, ErrInvalidCast
+		}
+
+		b = append(b, (octal[0]-'0')<<6|(octal[1]-'0')<<3|(octal[2]-'0'))
+		i += 3
+	}
+
+	return b, nil
+}
+
+func isOctalDigit(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// escapeBytea writes bytes in the escape format, with backslashes and
+// unprintable bytes as octal escapes
+func escapeBytea(b []byte) string {
+	var s strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '\\':
+			s.WriteString(`\\`)
+		case c < 0x20 || c > 0x7e:
+			s.WriteByte('\\')
+			s.WriteByte('0' + c>>6)
+			s.WriteByte('0' + c>>3&7)
+			s.WriteByte('0' + c&7)
+		default:
+			s.WriteByte(c)
+		}
+	}
+
+	return s.String()
+}
+
+// castBinary converts a non-NULL value between types where one is a
+// BYTEA or UUID
+func castBinary(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	switch {
+	case from == TextType && to == ByteaType:
+		return parseBytea(*value.AsText())
+	case from == TextType && to == UUIDType:
+		return parseUUID(*value.AsText())
+	case from == ByteaType && to == TextType:
+		return memoryCell(formatBytea(value)), nil
+	case from == UUIDType && to == TextType:
+		return memoryCell(formatUUID(value)), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// encodeBytea writes bytes as text in the format named by the second
+// argument: hex, base64 or escape
+func encodeBytea(args []Cell) (interface{}, error) {
+	b := *args[0].AsBytea()
+	switch strings.ToLower(*args[1].AsText()) {
+	case "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	case "escape":
+		return escapeBytea(b), nil
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+// decodeBytea reads text written by encode
+func decodeBytea(args []Cell) (interface{}, error) {
+	s := *args[0].AsText()
+	var b []byte
+	var err error
+	switch strings.ToLower(*args[1].AsText()) {
+	case "hex":
+		b, err = hex.DecodeString(s)
+	case "base64":
+		b, err = base64.StdEncoding.DecodeString(s)
+	case "escape":
+		b, err = parseBytea(s)
+	default:
+		return nil, ErrInvalidArguments
+	}
+
+	if err != nil {
+		return nil, ErrInvalidArguments
+	}
+
+	return b, nil
+}
+
+// randomUUID makes a version 4 UUID
+func randomUUID(args []Cell) (interface{}, error) {
+	b := make([]byte, uuidSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+func init() {
+	mustRegisterFunction("length", FunctionSignature{Args: []ColumnType{ByteaType}, Returns: IntType}, func(args []Cell) (interface{}, error) {
+		return len(*args[0].AsBytea()), nil
+	})
+	mustRegisterFunction("encode", FunctionSignature{Args: []ColumnType{ByteaType, TextType}, Returns: TextType}, encodeBytea)
+	mustRegisterFunction("decode", FunctionSignature{Args: []ColumnType{TextType, TextType}, Returns: ByteaType}, decodeBytea)
+	mustRegisterFunction("gen_random_uuid", FunctionSignature{Returns: UUIDType}, randomUUID)
+}
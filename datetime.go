@@ -0,0 +1,1041 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Dates, times and timestamps are stored so that their bytes order
+// like their values: dates as the days since 1970-01-01 with the sign
+// bit flipped, times as the microseconds since midnight and
+// timestamps as the microseconds since 1970-01-01 00:00:00 with the
+// sign bit flipped. Timestamps have no time zone. Intervals are
+// stored as their months, days and microseconds, which don't order
+// as bytes, so index keys use intervalKey instead.
+
+const (
+	microsecondsPerSecond = 1000000
+	microsecondsPerMinute = 60 * microsecondsPerSecond
+	microsecondsPerHour   = 60 * microsecondsPerMinute
+	microsecondsPerDay    = 24 * microsecondsPerHour
+
+	// Intervals are compared as if every month had 30 days
+	daysPerMonth = 30
+)
+
+// Dates and timestamps are limited to the years 1 to 9999
+var (
+	minTimestamp = timeToMicroseconds(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC))
+	maxTimestamp = timeToMicroseconds(time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)) - 1
+)
+
+// Interval is a span of time. Months and days are kept apart from
+// the rest since they don't always have the same length.
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+// String writes the interval like Postgres, e.g. "1 year 2 mons 3
+// days 04:05:06"
+func (i Interval) String() string {
+	parts := []string{}
+	unit := func(n int64, singular, plural string) {
+		if n == 1 {
+			parts = append(parts, "1 "+singular)
+		} else if n != 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+
+	unit(int64(i.Months/12), "year", "years")
+	unit(int64(i.Months%12), "mon", "mons")
+	unit(int64(i.Days), "day", "days")
+
+	if i.Microseconds != 0 || len(parts) == 0 {
+		sign := ""
+		us := i.Microseconds
+		if us < 0 {
+			sign = "-"
+			us = -us
+		}
+
+		hours := us / microsecondsPerHour
+		clock := fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, us/microsecondsPerMinute%60, us/microsecondsPerSecond%60)
+		parts = append(parts, clock+formatFraction(us%microsecondsPerSecond))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatFraction writes microseconds as the fraction of a second,
+// without trailing zeros
+func formatFraction(us int64) string {
+	if us == 0 {
+		return ""
+	}
+
+	return strings.TrimRight(fmt.Sprintf(".%06d", us), "0")
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("15:04:05.999999")
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05.999999")
+}
+
+func timeToMicroseconds(t time.Time) int64 {
+	return t.Unix()*microsecondsPerSecond + int64(t.Nanosecond()/1000)
+}
+
+func microsecondsToTime(us int64) time.Time {
+	return time.Unix(floorDiv(us, microsecondsPerSecond), floorMod(us, microsecondsPerSecond)*1000).UTC()
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+
+	return q
+}
+
+func floorMod(a, b int64) int64 {
+	return a - floorDiv(a, b)*b
+}
+
+func (mc memoryCell) AsDate() *time.Time {
+	if len(mc) < 4 {
+		return nil
+	}
+
+	t := microsecondsToTime(cellDays(mc) * microsecondsPerDay)
+	return &t
+}
+
+// AsTime returns the time of day on January 1st of year 0
+func (mc memoryCell) AsTime() *time.Time {
+	if len(mc) < 8 {
+		return nil
+	}
+
+	t := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(cellTimeOfDay(mc)) * time.Microsecond)
+	return &t
+}
+
+func (mc memoryCell) AsTimestamp() *time.Time {
+	if len(mc) < 8 {
+		return nil
+	}
+
+	t := microsecondsToTime(cellTimestamp(mc))
+	return &t
+}
+
+func (mc memoryCell) AsInterval() *Interval {
+	if len(mc) < 16 {
+		return nil
+	}
+
+	i := cellInterval(mc)
+	return &i
+}
+
+func cellDays(mc memoryCell) int64 {
+	return int64(int32(binary.BigEndian.Uint32(mc) ^ 1<<31))
+}
+
+func cellTimeOfDay(mc memoryCell) int64 {
+	return int64(binary.BigEndian.Uint64(mc))
+}
+
+func cellTimestamp(mc memoryCell) int64 {
+	return int64(binary.BigEndian.Uint64(mc) ^ 1<<63)
+}
+
+func cellInterval(mc memoryCell) Interval {
+	return Interval{
+		Months:       int32(binary.BigEndian.Uint32(mc) ^ 1<<31),
+		Days:         int32(binary.BigEndian.Uint32(mc[4:]) ^ 1<<31),
+		Microseconds: int64(binary.BigEndian.Uint64(mc[8:]) ^ 1<<63),
+	}
+}
+
+func dateToMemoryCell(days int64) (memoryCell, error) {
+	if days < floorDiv(minTimestamp, microsecondsPerDay) || days > floorDiv(maxTimestamp, microsecondsPerDay) {
+		return nil, ErrDatetimeOutOfRange
+	}
+
+	mc := make(memoryCell, 4)
+	binary.BigEndian.PutUint32(mc, uint32(int32(days))^1<<31)
+	return mc, nil
+}
+
+// timeToMemoryCell stores a time of day, wrapping around midnight
+func timeToMemoryCell(us int64) memoryCell {
+	mc := make(memoryCell, 8)
+	binary.BigEndian.PutUint64(mc, uint64(floorMod(us, microsecondsPerDay)))
+	return mc
+}
+
+func timestampToMemoryCell(us int64) (memoryCell, error) {
+	if us < minTimestamp || us > maxTimestamp {
+		return nil, ErrDatetimeOutOfRange
+	}
+
+	return bigIntToMemoryCell(us), nil
+}
+
+func intervalToMemoryCell(i Interval) memoryCell {
+	mc := make(memoryCell, 16)
+	binary.BigEndian.PutUint32(mc, uint32(i.Months)^1<<31)
+	binary.BigEndian.PutUint32(mc[4:], uint32(i.Days)^1<<31)
+	binary.BigEndian.PutUint64(mc[8:], uint64(i.Microseconds)^1<<63)
+	return mc
+}
+
+func isDatetimeType(typ ColumnType) bool {
+	return typ == DateType || typ == TimeType || typ == TimestampType || typ == IntervalType
+}
+
+// intervalSpan is the length of an interval in microseconds, with 30
+// days in a month. Intervals of the same span are equal.
+func intervalSpan(i Interval) *big.Int {
+	days := big.NewInt(int64(i.Months)*daysPerMonth + int64(i.Days))
+	span := days.Mul(days, big.NewInt(microsecondsPerDay))
+	return span.Add(span, big.NewInt(i.Microseconds))
+}
+
+func compareIntervals(a, b memoryCell) int {
+	return intervalSpan(cellInterval(a)).Cmp(intervalSpan(cellInterval(b)))
+}
+
+// intervalKey encodes the span of an interval in 16 bytes that order
+// like it, so equal intervals share a key
+func intervalKey(mc memoryCell) []byte {
+	span := intervalSpan(cellInterval(mc))
+	span.Add(span, new(big.Int).Lsh(big.NewInt(1), 127))
+
+	key := make([]byte, 16)
+	b := span.Bytes()
+	copy(key[16-len(b):], b)
+	return key
+}
+
+// compareDatetimes orders two non-NULL dates, times, timestamps or
+// intervals. A date compared to a timestamp is taken as its midnight.
+func compareDatetimes(a memoryCell, at ColumnType, b memoryCell, bt ColumnType) (int, error) {
+	if at == DateType && bt == TimestampType {
+		a, at = bigIntToMemoryCell(cellDays(a)*microsecondsPerDay), TimestampType
+	} else if at == TimestampType && bt == DateType {
+		b, bt = bigIntToMemoryCell(cellDays(b)*microsecondsPerDay), TimestampType
+	}
+
+	if at != bt {
+		return 0, ErrInvalidOperands
+	}
+
+	return compareCells(a, b, at), nil
+}
+
+// parseTimestamp reads a timestamp in ISO 8601 format, with a space
+// or T between the date and the time. The time may be left out.
+func parseTimestamp(s string) (int64, error) {
+	layouts := []string{
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	}
+
+	s = strings.TrimSpace(s)
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			us := timeToMicroseconds(t.Round(time.Microsecond))
+			if us < minTimestamp || us > maxTimestamp {
+				return 0, ErrDatetimeOutOfRange
+			}
+
+			return us, nil
+		}
+	}
+
+	return 0, ErrInvalidCast
+}
+
+// parseDate reads a date, ignoring the time if there is one
+func parseDate(s string) (int64, error) {
+	us, err := parseTimestamp(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return floorDiv(us, microsecondsPerDay), nil
+}
+
+// parseTime reads a time of day like 13:45 or 13:45:30.25
+func parseTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return floorMod(timeToMicroseconds(t.Round(time.Microsecond)), microsecondsPerDay), nil
+		}
+	}
+
+	return 0, ErrInvalidCast
+}
+
+// datetimeUnits maps the names a unit can be written with, in
+// intervals, extract and date_trunc, to a single one
+var datetimeUnits = map[string]string{}
+
+func init() {
+	for unit, aliases := range map[string][]string{
+		"microsecond": {"microseconds", "us", "usec", "usecs"},
+		"millisecond": {"milliseconds", "ms", "msec", "msecs"},
+		"second":      {"seconds", "s", "sec", "secs"},
+		"minute":      {"minutes", "m", "min", "mins"},
+		"hour":        {"hours", "h", "hr", "hrs"},
+		"day":         {"days", "d"},
+		"week":        {"weeks", "w"},
+		"month":       {"months", "mon", "mons"},
+		"quarter":     {"quarters"},
+		"year":        {"years", "y", "yr", "yrs"},
+		"decade":      {"decades"},
+		"century":     {"centuries"},
+		"millennium":  {"millennia", "millenniums"},
+		"dow":         {},
+		"isodow":      {},
+		"doy":         {},
+		"isoyear":     {},
+		"epoch":       {},
+	} {
+		datetimeUnits[unit] = unit
+		for _, alias := range aliases {
+			datetimeUnits[alias] = unit
+		}
+	}
+}
+
+// intervalUnits is the length of each unit an interval can be
+// written with
+var intervalUnits = map[string]Interval{
+	"microsecond": {Microseconds: 1},
+	"millisecond": {Microseconds: 1000},
+	"second":      {Microseconds: microsecondsPerSecond},
+	"minute":      {Microseconds: microsecondsPerMinute},
+	"hour":        {Microseconds: microsecondsPerHour},
+	"day":         {Days: 1},
+	"week":        {Days: 7},
+	"month":       {Months: 1},
+	"year":        {Months: 12},
+	"decade":      {Months: 120},
+	"century":     {Months: 1200},
+	"millennium":  {Months: 12000},
+}
+
+// parseInterval reads an interval written like Postgres does, as
+// quantities followed by their unit and optionally a time, e.g.
+// "1 year 2 mons -3 days 04:05:06" or "1.5 hours ago". A number
+// without a unit is in seconds.
+func parseInterval(s string) (Interval, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	ago := len(fields) > 0 && fields[len(fields)-1] == "ago"
+	if ago {
+		fields = fields[:len(fields)-1]
+	}
+
+	if len(fields) == 0 {
+		return Interval{}, ErrInvalidCast
+	}
+
+	var result Interval
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		var part Interval
+		if strings.Contains(field, ":") {
+			us, err := parseClock(field)
+			if err != nil {
+				return Interval{}, err
+			}
+
+			part.Microseconds = us
+		} else {
+			// The unit may be written right after the number, as in
+			// 10days
+			end := strings.IndexFunc(field, unicode.IsLetter)
+			number, unit := field, ""
+			if end > 0 {
+				number, unit = field[:end], field[end:]
+			} else if i+1 < len(fields) {
+				if _, ok := datetimeUnits[fields[i+1]]; ok {
+					unit = fields[i+1]
+					i++
+				}
+			}
+
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return Interval{}, ErrInvalidCast
+			}
+
+			length := Interval{Microseconds: microsecondsPerSecond}
+			if unit != "" {
+				var ok bool
+				length, ok = intervalUnits[datetimeUnits[unit]]
+				if !ok {
+					return Interval{}, ErrInvalidCast
+				}
+			}
+
+			part, err = scaleInterval(length, n)
+			if err != nil {
+				return Interval{}, err
+			}
+		}
+
+		var err error
+		result, err = addIntervals(result, part)
+		if err != nil {
+			return Interval{}, err
+		}
+	}
+
+	if ago {
+		return negateInterval(result)
+	}
+
+	return result, nil
+}
+
+// parseClock reads a time of an interval, [-]hh:mm[:ss[.ffffff]],
+// where hours may go past 24
+func parseClock(s string) (int64, error) {
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, ErrInvalidCast
+	}
+
+	hours, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || hours < 0 {
+		return 0, ErrInvalidCast
+	}
+
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, ErrInvalidCast
+	}
+
+	seconds := float64(0)
+	if len(parts) == 3 {
+		seconds, err = strconv.ParseFloat(parts[2], 64)
+		if err != nil || seconds < 0 || seconds >= 60 {
+			return 0, ErrInvalidCast
+		}
+	}
+
+	us := hours*microsecondsPerHour + minutes*microsecondsPerMinute + int64(math.Round(seconds*microsecondsPerSecond))
+	return sign * us, nil
+}
+
+// fitsInt32 checks a whole number, as a float, before converting it
+func fitsInt32(f float64) bool {
+	return f >= math.MinInt32 && f <= math.MaxInt32
+}
+
+// scaleInterval multiplies an interval by f. Fractions of months
+// carry over to days and fractions of days to microseconds, with 30
+// days in a month and 24 hours in a day.
+func scaleInterval(i Interval, f float64) (Interval, error) {
+	months := float64(i.Months) * f
+	days := float64(i.Days)*f + (months-math.Trunc(months))*daysPerMonth
+	us := float64(i.Microseconds)*f + (days-math.Trunc(days))*microsecondsPerDay
+	us = math.Round(us)
+
+	if !fitsInt32(math.Trunc(months)) || !fitsInt32(math.Trunc(days)) || math.IsNaN(us) || us < math.MinInt64 || us >= math.MaxInt64 {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	return Interval{
+		Months:       int32(math.Trunc(months)),
+		Days:         int32(math.Trunc(days)),
+		Microseconds: int64(us),
+	}, nil
+}
+
+func addIntervals(a, b Interval) (Interval, error) {
+	months := int64(a.Months) + int64(b.Months)
+	days := int64(a.Days) + int64(b.Days)
+	us := new(big.Int).Add(big.NewInt(a.Microseconds), big.NewInt(b.Microseconds))
+	if !fitsInt32(float64(months)) || !fitsInt32(float64(days)) || !us.IsInt64() {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	return Interval{int32(months), int32(days), us.Int64()}, nil
+}
+
+func negateInterval(i Interval) (Interval, error) {
+	if i.Months == math.MinInt32 || i.Days == math.MinInt32 || i.Microseconds == math.MinInt64 {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	return Interval{-i.Months, -i.Days, -i.Microseconds}, nil
+}
+
+// addInterval adds an interval to a timestamp. Months are added
+// first, moving to the last day of the month if the day doesn't
+// exist in it, then days and then the rest.
+func addInterval(us int64, i Interval) (int64, error) {
+	t := microsecondsToTime(us)
+	if i.Months != 0 {
+		year, month, day := t.Date()
+		months := int64(year)*12 + int64(month-1) + int64(i.Months)
+		year, month = int(floorDiv(months, 12)), time.Month(floorMod(months, 12)+1)
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+			day = last
+		}
+
+		t = time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+
+	t = t.AddDate(0, 0, int(i.Days))
+	if t.Year() < 1 || t.Year() > 9999 {
+		return 0, ErrDatetimeOutOfRange
+	}
+
+	result := new(big.Int).Add(big.NewInt(timeToMicroseconds(t)), big.NewInt(i.Microseconds))
+	if !result.IsInt64() || result.Int64() < minTimestamp || result.Int64() > maxTimestamp {
+		return 0, ErrDatetimeOutOfRange
+	}
+
+	return result.Int64(), nil
+}
+
+// datetimeArithmetic applies an arithmetic operator where at least
+// one operand is a date, time, timestamp or interval, following the
+// operators Postgres defines for them
+func datetimeArithmetic(op Symbol, l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, ColumnType, error) {
+	plus, minus := op == PlusSymbol, op == MinusSymbol
+
+	// Addition is commutative, so keep the date or time on the left
+	if plus && (lt == IntervalType || isIntegerType(lt) || (lt == TimeType && rt == DateType)) && rt != IntervalType {
+		l, lt, r, rt = r, rt, l, lt
+	}
+
+	switch {
+	case lt == DateType && isIntegerType(rt) && (plus || minus):
+		days := cellInt64(r, rt)
+		if minus {
+			days = -days
+		}
+
+		value, err := dateToMemoryCell(cellDays(l) + days)
+		return value, DateType, err
+	case lt == DateType && rt == DateType && minus:
+		value, err := intToMemoryCell(cellDays(l) - cellDays(r))
+		return value, IntType, err
+	case lt == DateType && rt == TimeType && plus:
+		value, err := timestampToMemoryCell(cellDays(l)*microsecondsPerDay + cellTimeOfDay(r))
+		return value, TimestampType, err
+	case (lt == DateType || lt == TimestampType) && rt == IntervalType && (plus || minus):
+		var us int64
+		if lt == DateType {
+			us = cellDays(l) * microsecondsPerDay
+		} else {
+			us = cellTimestamp(l)
+		}
+
+		i := cellInterval(r)
+		var err error
+		if minus {
+			if i, err = negateInterval(i); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		us, err = addInterval(us, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value, err := timestampToMemoryCell(us)
+		return value, TimestampType, err
+	case lt == TimestampType && rt == TimestampType && minus:
+		// Whole days are kept apart from the rest, like Postgres
+		us := cellTimestamp(l) - cellTimestamp(r)
+		i := Interval{Days: int32(us / microsecondsPerDay), Microseconds: us % microsecondsPerDay}
+		return intervalToMemoryCell(i), IntervalType, nil
+	case lt == TimeType && rt == IntervalType && (plus || minus):
+		// Only the time of the interval matters, it wraps around
+		// midnight
+		us := cellInterval(r).Microseconds % microsecondsPerDay
+		if minus {
+			us = -us
+		}
+
+		return timeToMemoryCell(cellTimeOfDay(l) + us), TimeType, nil
+	case lt == TimeType && rt == TimeType && minus:
+		i := Interval{Microseconds: cellTimeOfDay(l) - cellTimeOfDay(r)}
+		return intervalToMemoryCell(i), IntervalType, nil
+	case lt == IntervalType && rt == IntervalType && (plus || minus):
+		i := cellInterval(r)
+		var err error
+		if minus {
+			if i, err = negateInterval(i); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		i, err = addIntervals(cellInterval(l), i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return intervalToMemoryCell(i), IntervalType, nil
+	case lt == IntervalType && isNumericType(rt) && (op == AsteriskSymbol || op == SlashSymbol),
+		isNumericType(lt) && rt == IntervalType && op == AsteriskSymbol:
+		if lt != IntervalType {
+			l, lt, r, rt = r, rt, l, lt
+		}
+
+		f := cellFloat(r, rt)
+		if op == SlashSymbol {
+			if f == 0 {
+				return nil, 0, ErrDivisionByZero
+			}
+
+			f = 1 / f
+		}
+
+		i, err := scaleInterval(cellInterval(l), f)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return intervalToMemoryCell(i), IntervalType, nil
+	}
+
+	return nil, 0, ErrInvalidOperands
+}
+
+// datetimeArithmeticType is the type of the result of
+// datetimeArithmetic, for when an operand is NULL
+func datetimeArithmeticType(lt, rt ColumnType) ColumnType {
+	switch {
+	case lt == DateType && rt == DateType:
+		return IntType
+	case lt == DateType && isIntegerType(rt), isIntegerType(lt) && rt == DateType:
+		return DateType
+	case lt == TimeType && rt == TimeType, lt == TimestampType && rt == TimestampType:
+		return IntervalType
+	case lt == TimeType || rt == TimeType:
+		if lt == DateType || rt == DateType {
+			return TimestampType
+		}
+
+		return TimeType
+	case lt == DateType || rt == DateType, lt == TimestampType || rt == TimestampType:
+		return TimestampType
+	}
+
+	return IntervalType
+}
+
+// datetimeToText writes a non-NULL date, time, timestamp or interval
+// like Postgres does
+func datetimeToText(value memoryCell, typ ColumnType) string {
+	switch typ {
+	case DateType:
+		return formatDate(*value.AsDate())
+	case TimeType:
+		return formatTime(*value.AsTime())
+	case TimestampType:
+		return formatTimestamp(*value.AsTimestamp())
+	}
+
+	return cellInterval(value).String()
+}
+
+// castDatetime converts a non-NULL value between types where one is
+// a date, time, timestamp or interval
+func castDatetime(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	if from == TextType {
+		s := *value.AsText()
+		switch to {
+		case DateType:
+			days, err := parseDate(s)
+			if err != nil {
+				return nil, err
+			}
+
+			return dateToMemoryCell(days)
+		case TimeType:
+			us, err := parseTime(s)
+			if err != nil {
+				return nil, err
+			}
+
+			return timeToMemoryCell(us), nil
+		case TimestampType:
+			us, err := parseTimestamp(s)
+			if err != nil {
+				return nil, err
+			}
+
+			return timestampToMemoryCell(us)
+		case IntervalType:
+			i, err := parseInterval(s)
+			if err != nil {
+				return nil, err
+			}
+
+			return intervalToMemoryCell(i), nil
+		}
+	}
+
+	switch {
+	case to == TextType:
+		return memoryCell(datetimeToText(value, from)), nil
+	case from == DateType && to == TimestampType:
+		return timestampToMemoryCell(cellDays(value) * microsecondsPerDay)
+	case from == TimestampType && to == DateType:
+		return dateToMemoryCell(floorDiv(cellTimestamp(value), microsecondsPerDay))
+	case from == TimestampType && to == TimeType:
+		return timeToMemoryCell(cellTimestamp(value)), nil
+	case from == TimeType && to == IntervalType:
+		return intervalToMemoryCell(Interval{Microseconds: cellTimeOfDay(value)}), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// datetimeValueToMemoryCell stores a time.Time returned by a
+// ScalarFunction as a date, time or timestamp
+func datetimeValueToMemoryCell(t time.Time, typ ColumnType) (memoryCell, error) {
+	t = t.Round(time.Microsecond)
+	// Only the wall clock matters, whatever the location
+	us := timeToMicroseconds(time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC))
+	switch typ {
+	case DateType:
+		return dateToMemoryCell(floorDiv(us, microsecondsPerDay))
+	case TimeType:
+		return timeToMemoryCell(us), nil
+	case TimestampType:
+		return timestampToMemoryCell(us)
+	}
+
+	return nil, ErrInvalidFunctionResult
+}
+
+// datetimeUnit finds the unit named by the first argument of extract
+// or date_trunc
+func datetimeUnit(arg Cell) (string, error) {
+	unit, ok := datetimeUnits[strings.ToLower(strings.TrimSpace(*arg.AsText()))]
+	if !ok {
+		return "", ErrInvalidArguments
+	}
+
+	return unit, nil
+}
+
+// timestampArg reads a date or timestamp argument as a timestamp
+func timestampArg(arg Cell, typ ColumnType) int64 {
+	if typ == DateType {
+		return cellDays(arg.(memoryCell)) * microsecondsPerDay
+	}
+
+	return cellTimestamp(arg.(memoryCell))
+}
+
+// extractTimestamp returns a field of a timestamp, as a NUMERIC
+func extractTimestamp(unit string, us int64) (decimal, error) {
+	t := microsecondsToTime(us)
+	year := int64(t.Year())
+	secondUs := int64(t.Second())*microsecondsPerSecond + int64(t.Nanosecond()/1000)
+
+	var n int64
+	switch unit {
+	case "microsecond":
+		n = secondUs
+	case "millisecond":
+		return decimal{big.NewInt(secondUs), 3}.normalize(), nil
+	case "second":
+		return decimal{big.NewInt(secondUs), 6}.normalize(), nil
+	case "minute":
+		n = int64(t.Minute())
+	case "hour":
+		n = int64(t.Hour())
+	case "day":
+		n = int64(t.Day())
+	case "dow":
+		n = int64(t.Weekday())
+	case "isodow":
+		n = int64(t.Weekday())
+		if n == 0 {
+			n = 7
+		}
+	case "doy":
+		n = int64(t.YearDay())
+	case "week":
+		_, week := t.ISOWeek()
+		n = int64(week)
+	case "isoyear":
+		isoYear, _ := t.ISOWeek()
+		n = int64(isoYear)
+	case "month":
+		n = int64(t.Month())
+	case "quarter":
+		n = int64(t.Month()-1)/3 + 1
+	case "year":
+		n = year
+	case "decade":
+		n = year / 10
+	case "century":
+		n = (year + 99) / 100
+	case "millennium":
+		n = (year + 999) / 1000
+	case "epoch":
+		return decimal{big.NewInt(us), 6}.normalize(), nil
+	default:
+		return decimal{}, ErrInvalidArguments
+	}
+
+	return decimal{big.NewInt(n), 0}, nil
+}
+
+// extractTime returns a field of a time of day
+func extractTime(unit string, us int64) (decimal, error) {
+	switch unit {
+	case "microsecond", "millisecond", "second", "minute", "hour":
+		return extractTimestamp(unit, us)
+	case "epoch":
+		return decimal{big.NewInt(us), 6}.normalize(), nil
+	}
+
+	return decimal{}, ErrInvalidArguments
+}
+
+// extractInterval returns a field of an interval. Fields are taken
+// from the part of the interval holding them, so the hours of 1 day
+// are 0.
+func extractInterval(unit string, i Interval) (decimal, error) {
+	months := int64(i.Months)
+	secondUs := i.Microseconds % microsecondsPerMinute
+
+	var n int64
+	switch unit {
+	case "microsecond":
+		n = secondUs
+	case "millisecond":
+		return decimal{big.NewInt(secondUs), 3}.normalize(), nil
+	case "second":
+		return decimal{big.NewInt(secondUs), 6}.normalize(), nil
+	case "minute":
+		n = i.Microseconds / microsecondsPerMinute % 60
+	case "hour":
+		n = i.Microseconds / microsecondsPerHour
+	case "day":
+		n = int64(i.Days)
+	case "month":
+		n = months % 12
+	case "quarter":
+		n = months%12/3 + 1
+	case "year":
+		n = months / 12
+	case "decade":
+		n = months / 120
+	case "century":
+		n = months / 1200
+	case "millennium":
+		n = months / 12000
+	case "epoch":
+		// Years have 365.25 days, the remaining months 30
+		days := decimal{big.NewInt(months / 12 * 36525), 2}
+		days, _ = decimalArithmetic(PlusSymbol, days, decimal{big.NewInt(months%12*daysPerMonth + int64(i.Days)), 0})
+		seconds, _ := decimalArithmetic(AsteriskSymbol, days, decimal{big.NewInt(86400), 0})
+		seconds, _ = decimalArithmetic(PlusSymbol, seconds, decimal{big.NewInt(i.Microseconds), 6})
+		return seconds.normalize(), nil
+	default:
+		return decimal{}, ErrInvalidArguments
+	}
+
+	return decimal{big.NewInt(n), 0}, nil
+}
+
+// extract implements extract(unit FROM value) for a value of type
+// typ. The field is returned as NUMERIC, or as a double for date_part.
+func extract(typ ColumnType, double bool) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		unit, err := datetimeUnit(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		var d decimal
+		switch typ {
+		case TimeType:
+			d, err = extractTime(unit, cellTimeOfDay(args[1].(memoryCell)))
+		case IntervalType:
+			d, err = extractInterval(unit, cellInterval(args[1].(memoryCell)))
+		default:
+			d, err = extractTimestamp(unit, timestampArg(args[1], typ))
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if double {
+			f, _ := strconv.ParseFloat(d.String(), 64)
+			return f, nil
+		}
+
+		return d.String(), nil
+	}
+}
+
+// truncateTimestamp drops the fields of a timestamp smaller than
+// unit. Weeks start on Monday.
+func truncateTimestamp(unit string, us int64) (int64, error) {
+	t := microsecondsToTime(us)
+	year, month, day := t.Date()
+	switch unit {
+	case "microsecond":
+		return us, nil
+	case "millisecond":
+		return us - floorMod(us, 1000), nil
+	case "second":
+		return us - floorMod(us, microsecondsPerSecond), nil
+	case "minute":
+		return us - floorMod(us, microsecondsPerMinute), nil
+	case "hour":
+		return us - floorMod(us, microsecondsPerHour), nil
+	case "day":
+	case "week":
+		day -= (int(t.Weekday()) + 6) % 7
+	case "month":
+		day = 1
+	case "quarter":
+		month, day = (month-1)/3*3+1, 1
+	case "year":
+		month, day = 1, 1
+	case "decade":
+		year, month, day = year-year%10, 1, 1
+	case "century":
+		year, month, day = (year-1)/100*100+1, 1, 1
+	case "millennium":
+		year, month, day = (year-1)/1000*1000+1, 1, 1
+	default:
+		return 0, ErrInvalidArguments
+	}
+
+	truncated := timeToMicroseconds(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	if truncated < minTimestamp {
+		return 0, ErrDatetimeOutOfRange
+	}
+
+	return truncated, nil
+}
+
+// truncateInterval drops the fields of an interval smaller than unit
+func truncateInterval(unit string, i Interval) (Interval, error) {
+	us := i.Microseconds
+	switch unit {
+	case "microsecond":
+		return i, nil
+	case "millisecond":
+		return Interval{i.Months, i.Days, us - us%1000}, nil
+	case "second":
+		return Interval{i.Months, i.Days, us - us%microsecondsPerSecond}, nil
+	case "minute":
+		return Interval{i.Months, i.Days, us - us%microsecondsPerMinute}, nil
+	case "hour":
+		return Interval{i.Months, i.Days, us - us%microsecondsPerHour}, nil
+	case "day":
+		return Interval{i.Months, i.Days, 0}, nil
+	case "month":
+		return Interval{Months: i.Months}, nil
+	case "quarter":
+		return Interval{Months: i.Months - i.Months%3}, nil
+	case "year":
+		return Interval{Months: i.Months - i.Months%12}, nil
+	case "decade":
+		return Interval{Months: i.Months - i.Months%120}, nil
+	case "century":
+		return Interval{Months: i.Months - i.Months%1200}, nil
+	case "millennium":
+		return Interval{Months: i.Months - i.Months%12000}, nil
+	}
+
+	return Interval{}, ErrInvalidArguments
+}
+
+// dateTrunc implements date_trunc for a date or timestamp
+func dateTrunc(typ ColumnType) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		unit, err := datetimeUnit(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		us, err := truncateTimestamp(unit, timestampArg(args[1], typ))
+		if err != nil {
+			return nil, err
+		}
+
+		return microsecondsToTime(us), nil
+	}
+}
+
+// currentTimestamp is the time now in UTC, since timestamps have no
+// time zone
+func currentTimestamp(args []Cell) (interface{}, error) {
+	return time.Now().UTC(), nil
+}
+
+func init() {
+	mustRegisterFunction("now", FunctionSignature{Returns: TimestampType}, currentTimestamp)
+	mustRegisterFunction("current_timestamp", FunctionSignature{Returns: TimestampType}, currentTimestamp)
+	mustRegisterFunction("current_date", FunctionSignature{Returns: DateType}, currentTimestamp)
+
+	for _, typ := range []ColumnType{DateType, TimeType, TimestampType, IntervalType} {
+		args := []ColumnType{TextType, typ}
+		mustRegisterFunction("extract", FunctionSignature{Args: args, Returns: NumericType}, extract(typ, false))
+		mustRegisterFunction("date_part", FunctionSignature{Args: args, Returns: DoubleType}, extract(typ, true))
+	}
+
+	mustRegisterFunction("date_trunc", FunctionSignature{Args: []ColumnType{TextType, TimestampType}, Returns: TimestampType}, dateTrunc(TimestampType))
+	mustRegisterFunction("date_trunc", FunctionSignature{Args: []ColumnType{TextType, DateType}, Returns: TimestampType}, dateTrunc(DateType))
+	mustRegisterFunction("date_trunc", FunctionSignature{Args: []ColumnType{TextType, IntervalType}, Returns: IntervalType}, func(args []Cell) (interface{}, error) {
+		unit, err := datetimeUnit(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return truncateInterval(unit, cellInterval(args[1].(memoryCell)))
+	})
+}
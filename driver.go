@@ -58,6 +58,102 @@ func (r *Rows) Next(dest []driver.Value) error {
 			} else {
 				dest[idx] = b
 			}
+		case SmallIntType:
+			i := cell.AsSmallInt()
+			if i == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = int64(*i)
+			}
+		case BigIntType:
+			i := cell.AsBigInt()
+			if i == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *i
+			}
+		case RealType:
+			f := cell.AsReal()
+			if f == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = float64(*f)
+			}
+		case DoubleType:
+			f := cell.AsDouble()
+			if f == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *f
+			}
+		case NumericType:
+			// Like other drivers, exact numbers are returned as
+			// text so no precision is lost
+			s := cell.AsNumeric()
+			if s == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *s
+			}
+		case DateType:
+			t := cell.AsDate()
+			if t == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *t
+			}
+		case TimeType:
+			t := cell.AsTime()
+			if t == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *t
+			}
+		case TimestampType:
+			t := cell.AsTimestamp()
+			if t == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *t
+			}
+		case IntervalType:
+			// Intervals don't fit a time.Duration since months and
+			// days vary in length
+			i := cell.AsInterval()
+			if i == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = i.String()
+			}
+		case ByteaType:
+			b := cell.AsBytea()
+			if b == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *b
+			}
+		case UUIDType:
+			s := cell.AsUUID()
+			if s == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *s
+			}
+		case JSONType, JSONBType:
+			s := cell.AsJSON()
+			if s == nil {
+				dest[idx] = nil
+			} else {
+				dest[idx] = *s
+			}
+		default:
+			// Arrays are returned in the text form Postgres uses,
+			// like {1,2}
+			if typ.IsArray() && cell.AsArray() != nil {
+				dest[idx] = arrayToText(cell.(memoryCell), typ)
+			} else {
+				dest[idx] = nil
+			}
 		}
 	}
 
@@ -104,6 +200,16 @@ func (dc *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Error inserting values: %s", err)
 		}
+	case UpdateKind:
+		err = dc.bkd.Update(stmt.UpdateStatement)
+		if err != nil {
+			return nil, fmt.Errorf("Error updating values: %s", err)
+		}
+	case DeleteKind:
+		err = dc.bkd.Delete(stmt.DeleteStatement)
+		if err != nil {
+			return nil, fmt.Errorf("Error deleting values: %s", err)
+		}
 	case SelectKind:
 		results, err := dc.bkd.Select(stmt.SelectStatement)
 		if err != nil {
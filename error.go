@@ -3,16 +3,49 @@ package gosql
 import "errors"
 
 var (
-	ErrTableDoesNotExist         = errors.New("Table does not exist")
-	ErrTableAlreadyExists        = errors.New("Table already exists")
-	ErrIndexAlreadyExists        = errors.New("Index already exists")
-	ErrViolatesUniqueConstraint  = errors.New("Duplicate key value violates unique constraint")
-	ErrViolatesNotNullConstraint = errors.New("Value violates not null constraint")
-	ErrColumnDoesNotExist        = errors.New("Column does not exist")
-	ErrInvalidSelectItem         = errors.New("Select item is not valid")
-	ErrInvalidDatatype           = errors.New("Invalid datatype")
-	ErrMissingValues             = errors.New("Missing values")
-	ErrInvalidCell               = errors.New("Cell is invalid")
-	ErrInvalidOperands           = errors.New("Operands are invalid")
-	ErrPrimaryKeyAlreadyExists   = errors.New("Primary key already exists")
+	ErrTableDoesNotExist            = errors.New("Table does not exist")
+	ErrTableAlreadyExists           = errors.New("Table already exists")
+	ErrIndexAlreadyExists           = errors.New("Index already exists")
+	ErrViolatesUniqueConstraint     = errors.New("Duplicate key value violates unique constraint")
+	ErrViolatesNotNullConstraint    = errors.New("Value violates not null constraint")
+	ErrViolatesCheckConstraint      = errors.New("New row violates check constraint")
+	ErrDuplicateColumn              = errors.New("Column specified more than once")
+	ErrViolatesForeignKeyConstraint = errors.New("Value violates foreign key constraint")
+	ErrInvalidForeignKey            = errors.New("Foreign key does not match a unique key of the referenced table")
+	ErrReferencedByForeignKey       = errors.New("Table is referenced by a foreign key")
+	ErrColumnDoesNotExist           = errors.New("Column does not exist")
+	ErrAmbiguousColumn              = errors.New("Column reference is ambiguous")
+	ErrInvalidSelectItem            = errors.New("Select item is not valid")
+	ErrInvalidDatatype              = errors.New("Invalid datatype")
+	ErrMissingValues                = errors.New("Missing values")
+	ErrInvalidCell                  = errors.New("Cell is invalid")
+	ErrInvalidOperands              = errors.New("Operands are invalid")
+	ErrPrimaryKeyAlreadyExists      = errors.New("Primary key already exists")
+	ErrFunctionDoesNotExist         = errors.New("Function does not exist")
+	ErrInvalidArguments             = errors.New("Invalid function arguments")
+	ErrAggregateNotAllowed          = errors.New("Aggregate functions are not allowed here")
+	ErrInvalidOrderByPosition       = errors.New("ORDER BY position is not in select list")
+	ErrInvalidGroupByPosition       = errors.New("GROUP BY position is not in select list")
+	ErrDistinctOrderBy              = errors.New("For SELECT DISTINCT, ORDER BY expressions must appear in select list")
+	ErrDistinctOnOrderBy            = errors.New("SELECT DISTINCT ON expressions must match initial ORDER BY expressions")
+	ErrSetOperationColumnCount      = errors.New("Each SELECT of a set operation must have the same number of columns")
+	ErrSetOperationColumnType       = errors.New("Column types of a set operation must match")
+	ErrSubqueryNotAllowed           = errors.New("Subqueries are not allowed here")
+	ErrSubqueryColumnCount          = errors.New("Subquery must return only one column")
+	ErrSubqueryTooManyRows          = errors.New("More than one row returned by a subquery used as an expression")
+	ErrCTEColumnCount               = errors.New("WITH query has fewer columns than column names given")
+	ErrDuplicateCTE                 = errors.New("WITH query name specified more than once")
+	ErrRecursionLimit               = errors.New("Recursive query produced too many rows")
+	ErrColumnNotGrouped             = errors.New("Column must appear in the GROUP BY clause or be used in an aggregate function")
+	ErrDivisionByZero               = errors.New("Division by zero")
+	ErrIntegerOutOfRange            = errors.New("Integer out of range")
+	ErrInvalidEscape                = errors.New("Invalid escape string")
+	ErrInvalidRegularExpression     = errors.New("Invalid regular expression")
+	ErrInvalidFunction              = errors.New("Invalid function")
+	ErrFunctionAlreadyExists        = errors.New("Function already exists")
+	ErrInvalidFunctionResult        = errors.New("Function result does not match its return type")
+	ErrInvalidCast                  = errors.New("Value cannot be converted to the type")
+	ErrNumericOutOfRange            = errors.New("Numeric value out of range")
+	ErrDatetimeOutOfRange           = errors.New("Date or time value out of range")
+	ErrInvalidIndexMethod           = errors.New("Invalid index method")
 )
@@ -0,0 +1,371 @@
+package gosql
+
+import (
+	"sort"
+	"strings"
+)
+
+// foreignKey is a FOREIGN KEY constraint of a stored table. The
+// values of its columns in each row must either contain a NULL or
+// be the key of a row of parent.
+type foreignKey struct {
+	name    string
+	child   *table
+	columns []int
+	parent  *table
+	// parentColumns are the referenced columns, which index is a
+	// unique index of. indexOrder holds the position in the key of
+	// each expression of the index.
+	parentColumns []int
+	index         *index
+	indexOrder    []int
+	onDelete      ReferentialAction
+	onUpdate      ReferentialAction
+}
+
+// columnIndexes returns the positions of columns of t
+func (t *table) columnIndexes(names []*Token) ([]int, error) {
+	columns := []int{}
+	seen := map[int]bool{}
+	for _, name := range names {
+		i, err := t.columnIndex("", name.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[i] {
+			return nil, ErrDuplicateColumn
+		}
+		seen[i] = true
+
+		columns = append(columns, i)
+	}
+
+	return columns, nil
+}
+
+// uniqueIndex finds a unique index of t on exactly columns, in any
+// order. order holds the position in columns of each expression of
+// the index.
+func (t *table) uniqueIndex(columns []int) (*index, []int) {
+	for _, i := range t.indexes {
+		if !i.unique || i.typ != btreeIndexType || len(i.exps) != len(columns) {
+			continue
+		}
+
+		order := make([]int, len(columns))
+		for j, c := range columns {
+			p := i.columnPosition(t.columns[c])
+			if p == -1 {
+				order = nil
+				break
+			}
+
+			order[p] = j
+		}
+
+		if order != nil {
+			return i, order
+		}
+	}
+
+	return nil, nil
+}
+
+// primaryKeyColumns returns the positions of the columns of the
+// primary key of t, or nil if it has none
+func (t *table) primaryKeyColumns() []int {
+	for _, i := range t.indexes {
+		if !i.primaryKey {
+			continue
+		}
+
+		columns := []int{}
+		for _, exp := range i.exps {
+			column, _ := t.columnIndex("", exp.Literal.Value)
+			columns = append(columns, column)
+		}
+
+		return columns
+	}
+
+	return nil
+}
+
+// addForeignKey adds a foreign key on columns of t, or on the column
+// at position column when fkd has no columns of its own
+func (mb *MemoryBackend) addForeignKey(t *table, fkd *ForeignKeyDefinition, column int) error {
+	parent, ok := mb.tables[fkd.Table.Value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	fk := &foreignKey{
+		child:    t,
+		columns:  []int{column},
+		parent:   parent,
+		onDelete: fkd.OnDelete,
+		onUpdate: fkd.OnUpdate,
+	}
+
+	var err error
+	if fkd.Columns != nil {
+		fk.columns, err = t.columnIndexes(*fkd.Columns)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fkd.RefColumns != nil {
+		fk.parentColumns, err = parent.columnIndexes(*fkd.RefColumns)
+		if err != nil {
+			return err
+		}
+	} else {
+		fk.parentColumns = parent.primaryKeyColumns()
+	}
+
+	if len(fk.columns) != len(fk.parentColumns) {
+		return ErrInvalidForeignKey
+	}
+
+	fk.index, fk.indexOrder = parent.uniqueIndex(fk.parentColumns)
+	if fk.index == nil {
+		return ErrInvalidForeignKey
+	}
+
+	names := []string{t.name}
+	for j, c := range fk.columns {
+		childType := t.columnTypes[c]
+		parentType := parent.columnTypes[fk.parentColumns[j]]
+		if childType != parentType && !(isNumericType(childType) && isNumericType(parentType)) {
+			return ErrInvalidDatatype
+		}
+
+		names = append(names, t.columns[c])
+	}
+	fk.name = strings.Join(append(names, "fkey"), "_")
+
+	t.foreignKeys = append(t.foreignKeys, fk)
+	return nil
+}
+
+// referencingKeys lists the foreign keys referencing parent
+func (mb *MemoryBackend) referencingKeys(parent *table) []*foreignKey {
+	names := []string{}
+	for name := range mb.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fks := []*foreignKey{}
+	for _, name := range names {
+		for _, fk := range mb.tables[name].foreignKeys {
+			if fk.parent == parent {
+				fks = append(fks, fk)
+			}
+		}
+	}
+
+	return fks
+}
+
+// parentKey returns the values of the referenced columns of a row of
+// the parent table, or nil if any is NULL
+func (fk *foreignKey) parentKey(row []memoryCell) []memoryCell {
+	key := []memoryCell{}
+	for _, c := range fk.parentColumns {
+		if row[c] == nil {
+			return nil
+		}
+
+		key = append(key, row[c])
+	}
+
+	return key
+}
+
+// childKey returns the values of the referencing columns of a row of
+// the child table converted to the types of the referenced columns,
+// or nil if any is NULL
+func (fk *foreignKey) childKey(row []memoryCell) ([]memoryCell, error) {
+	key := []memoryCell{}
+	for j, c := range fk.columns {
+		if row[c] == nil {
+			return nil, nil
+		}
+
+		value, err := castCell(row[c], fk.child.columnTypes[c], fk.parent.columnTypes[fk.parentColumns[j]])
+		if err != nil {
+			return nil, err
+		}
+
+		key = append(key, value)
+	}
+
+	return key, nil
+}
+
+// equalKeys reports whether two keys of the parent table are equal
+func (fk *foreignKey) equalKeys(a, b []memoryCell) bool {
+	for j, c := range fk.parentColumns {
+		if compareCells(a[j], b[j], fk.parent.columnTypes[c]) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// references reports whether a row of the child table references
+// the row of the parent table with key
+func (fk *foreignKey) references(row []memoryCell, key []memoryCell) bool {
+	childKey, err := fk.childKey(row)
+	return err == nil && childKey != nil && fk.equalKeys(childKey, key)
+}
+
+// withKey returns a copy of a row of the child table referencing key
+// instead, or referencing nothing if key is nil
+func (fk *foreignKey) withKey(row []memoryCell, key []memoryCell) ([]memoryCell, error) {
+	row = append([]memoryCell{}, row...)
+	for j, c := range fk.columns {
+		if key == nil {
+			row[c] = nil
+			continue
+		}
+
+		value, err := castCell(key[j], fk.parent.columnTypes[fk.parentColumns[j]], fk.child.columnTypes[c])
+		if err != nil {
+			return nil, err
+		}
+
+		row[c] = value
+	}
+
+	return row, nil
+}
+
+// indexKey encodes a key of the parent table for its unique index
+func (fk *foreignKey) indexKey(key []memoryCell) []byte {
+	values := []memoryCell{}
+	for _, j := range fk.indexOrder {
+		values = append(values, key[j])
+	}
+
+	return encodeKey(values, fk.index.valueTypes)
+}
+
+// keyExists reports whether a row of the parent table will have key
+// after the changes in cs. The unique index of the parent finds the
+// rows with the key before the changes.
+func (cs *changeSet) keyExists(fk *foreignKey, key []memoryCell) bool {
+	tc := cs.changes[fk.parent]
+	if tc != nil {
+		for _, row := range tc.rows {
+			if row == nil {
+				continue
+			}
+
+			if parentKey := fk.parentKey(row); parentKey != nil && fk.equalKeys(parentKey, key) {
+				return true
+			}
+		}
+	}
+
+	for _, rowIndex := range fk.index.rowIndexesWithKey(fk.indexKey(key)) {
+		if tc == nil {
+			return true
+		}
+
+		if _, changed := tc.rows[rowIndex]; !changed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// referencingRows returns the positions of the rows of the child
+// table that will reference key after the changes in cs
+func (cs *changeSet) referencingRows(fk *foreignKey, key []memoryCell) []uint {
+	rowIndexes := []uint{}
+	for i := range fk.child.rows {
+		if row := cs.row(fk.child, uint(i)); row != nil && fk.references(row, key) {
+			rowIndexes = append(rowIndexes, uint(i))
+		}
+	}
+
+	return rowIndexes
+}
+
+// checkReferences returns an error if a row of t will reference a
+// key that doesn't exist after the changes in cs
+func (cs *changeSet) checkReferences(t *table, row []memoryCell) error {
+	for _, fk := range t.foreignKeys {
+		key, err := fk.childKey(row)
+		if err != nil || (key != nil && !cs.keyExists(fk, key)) {
+			return ErrViolatesForeignKeyConstraint
+		}
+	}
+
+	return nil
+}
+
+// changeKeys applies the actions of the foreign keys referencing t
+// when one of its rows changes from old to row, or is deleted when
+// row is nil
+func (mb *MemoryBackend) changeKeys(cs *changeSet, t *table, old, row []memoryCell) error {
+	for _, fk := range mb.referencingKeys(t) {
+		key := fk.parentKey(old)
+		// NULL keys are never referenced
+		if key == nil {
+			continue
+		}
+
+		action := fk.onDelete
+		var newKey []memoryCell
+		if row != nil {
+			newKey = fk.parentKey(row)
+			if newKey != nil && fk.equalKeys(key, newKey) {
+				continue
+			}
+
+			action = fk.onUpdate
+		}
+
+		for _, rowIndex := range cs.referencingRows(fk, key) {
+			var err error
+			switch action {
+			case RestrictReferentialAction:
+				return ErrViolatesForeignKeyConstraint
+			case NoActionReferentialAction:
+				// The row may be changed again or the key come
+				// back before the statement ends
+				cs.recheck(fk.child, rowIndex)
+			case CascadeReferentialAction:
+				if row == nil {
+					err = mb.deleteRow(cs, fk.child, rowIndex)
+					break
+				}
+
+				var childRow []memoryCell
+				childRow, err = fk.withKey(cs.row(fk.child, rowIndex), newKey)
+				if err == nil {
+					err = mb.updateRow(cs, fk.child, rowIndex, childRow)
+				}
+			case SetNullReferentialAction:
+				var childRow []memoryCell
+				childRow, err = fk.withKey(cs.row(fk.child, rowIndex), nil)
+				if err == nil {
+					err = mb.updateRow(cs, fk.child, rowIndex, childRow)
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
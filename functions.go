@@ -0,0 +1,494 @@
+package gosql
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FunctionSignature describes the arguments a scalar function takes
+// and the type of its result
+type FunctionSignature struct {
+	// Args are the argument types. When Variadic is set the last
+	// one may be repeated any number of times, including none.
+	Args     []ColumnType
+	Variadic bool
+	Returns  ColumnType
+}
+
+// argType is the type expected for the argument at position i
+func (fs FunctionSignature) argType(i int) ColumnType {
+	if i < len(fs.Args) {
+		return fs.Args[i]
+	}
+
+	return fs.Args[len(fs.Args)-1]
+}
+
+// accepts reports whether arguments of the given types can be
+// passed. With promote set numbers are also accepted where a numeric
+// type of a higher rank is expected, like an int for a bigint.
+func (fs FunctionSignature) accepts(types []ColumnType, untyped []bool, promote bool) bool {
+	if len(types) < len(fs.Args) && !(fs.Variadic && len(types) == len(fs.Args)-1) {
+		return false
+	}
+
+	if len(types) > len(fs.Args) && !fs.Variadic {
+		return false
+	}
+
+	for i, typ := range types {
+		expected := fs.argType(i)
+		if untyped[i] || typ == expected {
+			continue
+		}
+
+		if !promote || !isNumericType(typ) || numericRank(typ) > numericRank(expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ScalarFunction computes the result of a function call. Functions
+// are only called when none of their arguments is NULL, the result
+// is then NULL otherwise. The returned value must match the
+// signature: an int16, int32, int64, float32, float64, bool, a
+// string for text, a NUMERIC in decimal notation, a UUID or JSON, a
+// []byte for a BYTEA, a time.Time for a date, time or timestamp, an
+// Interval, a []interface{} of elements for an array, one of the
+// argument Cells, or nil for NULL. Any Go integer or float is
+// accepted for a numeric result as long as it fits.
+type ScalarFunction func(args []Cell) (interface{}, error)
+
+type function struct {
+	signature FunctionSignature
+	impl      ScalarFunction
+}
+
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string][]function{}
+)
+
+// RegisterFunction makes a scalar function available to queries. A
+// name may be registered several times with different argument
+// types, the overload matching the arguments of a call is used.
+func RegisterFunction(name string, signature FunctionSignature, impl ScalarFunction) error {
+	name = strings.ToLower(name)
+	if name == "" || impl == nil || (len(signature.Args) == 0 && signature.Variadic) {
+		return ErrInvalidFunction
+	}
+
+	if aggregateFunctions[name] {
+		return ErrFunctionAlreadyExists
+	}
+
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+
+	for _, f := range functions[name] {
+		if f.signature.Variadic == signature.Variadic && columnTypesEqual(f.signature.Args, signature.Args) {
+			return ErrFunctionAlreadyExists
+		}
+	}
+
+	functions[name] = append(functions[name], function{signature, impl})
+	return nil
+}
+
+func columnTypesEqual(a, b []ColumnType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookupFunction finds the overload of name accepting the argument
+// types. Untyped arguments, i.e. NULL literals, match any type. An
+// overload taking the exact types is preferred, otherwise the first
+// registered one the arguments can be promoted to is used.
+func lookupFunction(name string, types []ColumnType, untyped []bool) (*function, error) {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	overloads, ok := functions[name]
+	if !ok {
+		return nil, ErrFunctionDoesNotExist
+	}
+
+	for _, promote := range []bool{false, true} {
+		for _, f := range overloads {
+			if f.signature.accepts(types, untyped, promote) {
+				return &f, nil
+			}
+		}
+	}
+
+	return nil, ErrInvalidArguments
+}
+
+// valueToMemoryCell stores a value returned by a ScalarFunction
+func valueToMemoryCell(value interface{}, typ ColumnType) (memoryCell, error) {
+	if value == nil {
+		return nullMemoryCell, nil
+	}
+
+	switch v := value.(type) {
+	case memoryCell:
+		// Cells were given as arguments so they are stored already
+		return v, nil
+	case []interface{}:
+		if typ.IsArray() {
+			elements := []memoryCell{}
+			for _, element := range v {
+				mc, err := valueToMemoryCell(element, typ.ElementType())
+				if err != nil {
+					return nil, err
+				}
+
+				elements = append(elements, mc)
+			}
+
+			return encodeArray(elements), nil
+		}
+	case int16:
+		return numberToMemoryCell(bigIntToMemoryCell(int64(v)), BigIntType, typ)
+	case int32:
+		return numberToMemoryCell(bigIntToMemoryCell(int64(v)), BigIntType, typ)
+	case int:
+		return numberToMemoryCell(bigIntToMemoryCell(int64(v)), BigIntType, typ)
+	case int64:
+		return numberToMemoryCell(bigIntToMemoryCell(v), BigIntType, typ)
+	case float32:
+		return numberToMemoryCell(realToMemoryCell(v), RealType, typ)
+	case float64:
+		return numberToMemoryCell(doubleToMemoryCell(v), DoubleType, typ)
+	case string:
+		if typ == TextType {
+			return memoryCell(v), nil
+		}
+
+		if typ == NumericType {
+			d, ok := parseDecimal(v)
+			if ok {
+				return decimalToMemoryCell(d), nil
+			}
+		}
+
+		if typ == UUIDType {
+			if mc, err := parseUUID(v); err == nil {
+				return mc, nil
+			}
+		}
+
+		if isJSONType(typ) {
+			if mc, err := jsonToMemoryCell([]byte(v), typ); err == nil {
+				return mc, nil
+			}
+		}
+	case []byte:
+		if typ == ByteaType {
+			return append(memoryCell{}, v...), nil
+		}
+	case bool:
+		if typ == BoolType {
+			if v {
+				return trueMemoryCell, nil
+			}
+
+			return falseMemoryCell, nil
+		}
+	case time.Time:
+		return datetimeValueToMemoryCell(v, typ)
+	case Interval:
+		if typ == IntervalType {
+			return intervalToMemoryCell(v), nil
+		}
+	}
+
+	return nil, ErrInvalidFunctionResult
+}
+
+// numberToMemoryCell converts a number returned by a function to
+// the numeric type it is declared to return
+func numberToMemoryCell(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	if !isNumericType(to) {
+		return nil, ErrInvalidFunctionResult
+	}
+
+	return castNumber(value, from, to)
+}
+
+func intToMemoryCell(i int64) (memoryCell, error) {
+	return integerToMemoryCell(i, IntType)
+}
+
+func mustRegisterFunction(name string, signature FunctionSignature, impl ScalarFunction) {
+	if err := RegisterFunction(name, signature, impl); err != nil {
+		panic(err)
+	}
+}
+
+func textFunction(fn func(string) string) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		return fn(*args[0].AsText()), nil
+	}
+}
+
+// substr follows Postgres: positions start at 1, and characters
+// before the start of the string count towards the length
+func substr(args []Cell) (interface{}, error) {
+	s := []rune(*args[0].AsText())
+	start := int64(*args[1].AsInt()) - 1
+	end := int64(len(s))
+	if len(args) > 2 {
+		length := int64(*args[2].AsInt())
+		if length < 0 {
+			return nil, ErrInvalidArguments
+		}
+
+		end = start + length
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end > int64(len(s)) {
+		end = int64(len(s))
+	}
+
+	if start >= end {
+		return "", nil
+	}
+
+	return string(s[start:end]), nil
+}
+
+// position returns where substring first occurs in s counting from 1,
+// or 0 if it doesn't
+func position(args []Cell) (interface{}, error) {
+	substring, s := *args[0].AsText(), *args[1].AsText()
+	i := strings.Index(s, substring)
+	if i == -1 {
+		return int32(0), nil
+	}
+
+	return int32(utf8.RuneCountInString(s[:i]) + 1), nil
+}
+
+// roundInt rounds to a number of decimal digits, which for integers
+// only has an effect when negative
+func roundInt(args []Cell) (interface{}, error) {
+	i := int64(*args[0].AsInt())
+	digits := int64(0)
+	if len(args) > 1 {
+		digits = int64(*args[1].AsInt())
+	}
+
+	if digits >= 0 {
+		return int32(i), nil
+	}
+
+	if digits < -10 {
+		return int32(0), nil
+	}
+
+	unit := int64(math.Pow10(int(-digits)))
+	rounded := (abs64(i) + unit/2) / unit * unit
+	if i < 0 {
+		rounded = -rounded
+	}
+
+	if rounded < math.MinInt32 || rounded > math.MaxInt32 {
+		return nil, ErrIntegerOutOfRange
+	}
+
+	return int32(rounded), nil
+}
+
+func abs64(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+
+	return i
+}
+
+// roundNumeric rounds half away from zero to a number of digits
+// after the decimal point
+func roundNumeric(args []Cell) (interface{}, error) {
+	d, _ := parseDecimal(*args[0].AsNumeric())
+	digits := 0
+	if len(args) > 1 {
+		digits = int(*args[1].AsInt())
+	}
+
+	// Rounding to more digits than there are leaves nothing
+	if digits < -d.integerDigits() {
+		return "0", nil
+	}
+
+	return d.round(digits).String(), nil
+}
+
+// numberValue reads a non-NULL number as the Go value a
+// ScalarFunction returns for its type
+func numberValue(value memoryCell, typ ColumnType) interface{} {
+	switch typ {
+	case SmallIntType:
+		return *value.AsSmallInt()
+	case IntType:
+		return *value.AsInt()
+	case BigIntType:
+		return *value.AsBigInt()
+	case RealType:
+		return *value.AsReal()
+	case DoubleType:
+		return *value.AsDouble()
+	}
+
+	return *value.AsNumeric()
+}
+
+// numericFunction applies an arithmetic operator to the arguments of
+// a function, which are numbers of type typ
+func numericFunction(fn func(args []memoryCell) (memoryCell, error), typ ColumnType) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		values := []memoryCell{}
+		for _, arg := range args {
+			values = append(values, arg.(memoryCell))
+		}
+
+		value, err := fn(values)
+		if err != nil {
+			return nil, err
+		}
+
+		return numberValue(value, typ), nil
+	}
+}
+
+// columnTypeName is the SQL name of a column type
+func columnTypeName(typ ColumnType) string {
+	if typ.IsArray() {
+		return columnTypeName(typ.ElementType()) + "[]"
+	}
+
+	switch typ {
+	case IntType:
+		return "integer"
+	case TextType:
+		return "text"
+	case BoolType:
+		return "boolean"
+	case SmallIntType:
+		return "smallint"
+	case BigIntType:
+		return "bigint"
+	case RealType:
+		return "real"
+	case DoubleType:
+		return "double precision"
+	case NumericType:
+		return "numeric"
+	case DateType:
+		return "date"
+	case TimeType:
+		return "time without time zone"
+	case TimestampType:
+		return "timestamp without time zone"
+	case IntervalType:
+		return "interval"
+	case ByteaType:
+		return "bytea"
+	case UUIDType:
+		return "uuid"
+	case JSONType:
+		return "json"
+	case JSONBType:
+		return "jsonb"
+	}
+
+	return "unknown"
+}
+
+func init() {
+	text := []ColumnType{TextType}
+
+	mustRegisterFunction("lower", FunctionSignature{Args: text, Returns: TextType}, textFunction(strings.ToLower))
+	mustRegisterFunction("upper", FunctionSignature{Args: text, Returns: TextType}, textFunction(strings.ToUpper))
+	mustRegisterFunction("trim", FunctionSignature{Args: text, Returns: TextType}, textFunction(strings.TrimSpace))
+	mustRegisterFunction("ltrim", FunctionSignature{Args: text, Returns: TextType}, textFunction(func(s string) string {
+		return strings.TrimLeftFunc(s, unicode.IsSpace)
+	}))
+	mustRegisterFunction("rtrim", FunctionSignature{Args: text, Returns: TextType}, textFunction(func(s string) string {
+		return strings.TrimRightFunc(s, unicode.IsSpace)
+	}))
+	mustRegisterFunction("trim", FunctionSignature{Args: []ColumnType{TextType, TextType}, Returns: TextType}, func(args []Cell) (interface{}, error) {
+		return strings.Trim(*args[0].AsText(), *args[1].AsText()), nil
+	})
+	mustRegisterFunction("length", FunctionSignature{Args: text, Returns: IntType}, func(args []Cell) (interface{}, error) {
+		return utf8.RuneCountInString(*args[0].AsText()), nil
+	})
+	mustRegisterFunction("substr", FunctionSignature{Args: []ColumnType{TextType, IntType}, Returns: TextType}, substr)
+	mustRegisterFunction("substr", FunctionSignature{Args: []ColumnType{TextType, IntType, IntType}, Returns: TextType}, substr)
+	mustRegisterFunction("replace", FunctionSignature{Args: []ColumnType{TextType, TextType, TextType}, Returns: TextType}, func(args []Cell) (interface{}, error) {
+		return strings.ReplaceAll(*args[0].AsText(), *args[1].AsText(), *args[2].AsText()), nil
+	})
+	mustRegisterFunction("position", FunctionSignature{Args: []ColumnType{TextType, TextType}, Returns: IntType}, position)
+
+	numericTypes := []ColumnType{SmallIntType, IntType, BigIntType, NumericType, RealType, DoubleType}
+	for _, typ := range numericTypes {
+		typ := typ
+		zero, _ := castNumber(literalToMemoryCell(&Token{Kind: NumericKind, Value: "0"}), IntType, typ)
+		mustRegisterFunction("abs", FunctionSignature{Args: []ColumnType{typ}, Returns: typ}, numericFunction(func(args []memoryCell) (memoryCell, error) {
+			if compareCells(args[0], zero, typ) >= 0 {
+				return args[0], nil
+			}
+
+			value, _, err := numericArithmetic(MinusSymbol, zero, typ, args[0], typ)
+			return value, err
+		}, typ))
+
+		if isFloatType(typ) {
+			continue
+		}
+
+		mustRegisterFunction("mod", FunctionSignature{Args: []ColumnType{typ, typ}, Returns: typ}, numericFunction(func(args []memoryCell) (memoryCell, error) {
+			value, _, err := numericArithmetic(PercentSymbol, args[0], typ, args[1], typ)
+			return value, err
+		}, typ))
+	}
+
+	mustRegisterFunction("round", FunctionSignature{Args: []ColumnType{IntType}, Returns: IntType}, roundInt)
+	mustRegisterFunction("round", FunctionSignature{Args: []ColumnType{IntType, IntType}, Returns: IntType}, roundInt)
+	mustRegisterFunction("round", FunctionSignature{Args: []ColumnType{NumericType}, Returns: NumericType}, roundNumeric)
+	mustRegisterFunction("round", FunctionSignature{Args: []ColumnType{NumericType, IntType}, Returns: NumericType}, roundNumeric)
+	mustRegisterFunction("round", FunctionSignature{Args: []ColumnType{DoubleType}, Returns: DoubleType}, func(args []Cell) (interface{}, error) {
+		return math.RoundToEven(*args[0].AsDouble()), nil
+	})
+
+	datetimeTypes := []ColumnType{DateType, TimeType, TimestampType, IntervalType}
+	otherTypes := []ColumnType{ByteaType, UUIDType, JSONType, JSONBType}
+	for _, typ := range append(append(append([]ColumnType{TextType, BoolType}, numericTypes...), datetimeTypes...), otherTypes...) {
+		for _, typ := range []ColumnType{typ, ArrayType | typ} {
+			name := columnTypeName(typ)
+			mustRegisterFunction("pg_typeof", FunctionSignature{Args: []ColumnType{typ}, Returns: TextType}, func(args []Cell) (interface{}, error) {
+				return name, nil
+			})
+		}
+	}
+}
@@ -0,0 +1,667 @@
+package gosql
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON values are stored as text. JSON keeps the text it was given
+// while JSONB is normalized so that equal documents have the same
+// bytes: whitespace between tokens is dropped, object keys are sorted
+// and unique, keeping the last value given for a key, and numbers are
+// written without exponents or trailing zeros.
+
+const (
+	// Entries of GIN indexes are the tagged object keys leading to a
+	// scalar followed by the tagged scalar
+	jsonKeyEntryTag   byte = 1
+	jsonValueEntryTag byte = 2
+)
+
+// AsJSON returns the text of a JSON or JSONB value
+func (mc memoryCell) AsJSON() *string {
+	return mc.AsText()
+}
+
+func isJSONType(typ ColumnType) bool {
+	return typ == JSONType || typ == JSONBType
+}
+
+// decodeJSON reads a document into maps, slices, strings,
+// json.Numbers, bools and nils
+func decodeJSON(doc []byte) (interface{}, error) {
+	if !json.Valid(doc) {
+		return nil, ErrInvalidCast
+	}
+
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, ErrInvalidCast
+	}
+
+	return v, nil
+}
+
+// jsonValue decodes a stored document, which is always valid
+func jsonValue(mc memoryCell) interface{} {
+	v, _ := decodeJSON(mc)
+	return v
+}
+
+// jsonToMemoryCell checks a document and normalizes it if it is
+// stored as JSONB
+func jsonToMemoryCell(doc []byte, typ ColumnType) (memoryCell, error) {
+	v, err := decodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == JSONType {
+		return memoryCell(doc), nil
+	}
+
+	return encodeJSON(v), nil
+}
+
+// encodeJSON writes a decoded document in its JSONB form
+func encodeJSON(v interface{}) memoryCell {
+	var b strings.Builder
+	writeJSON(&b, v, false, 0)
+	return memoryCell(b.String())
+}
+
+// jsonKeys sorts the keys of an object like JSONB does, shorter keys
+// first
+func jsonKeys(object map[string]interface{}) []string {
+	keys := []string{}
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// writeJSON writes a decoded document like Postgres outputs JSONB,
+// e.g. {"a": 1, "b": [true, null]}. When pretty is set members and
+// elements go on their own lines, indented four spaces per level.
+func writeJSON(b *strings.Builder, v interface{}, pretty bool, depth int) {
+	separate := func(i, depth int) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		if pretty {
+			b.WriteString("\n" + strings.Repeat("    ", depth))
+		} else if i > 0 {
+			b.WriteByte(' ')
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString("{}")
+			return
+		}
+
+		b.WriteByte('{')
+		for i, key := range jsonKeys(v) {
+			separate(i, depth+1)
+			writeJSONString(b, key)
+			b.WriteString(": ")
+			writeJSON(b, v[key], pretty, depth+1)
+		}
+		separate(0, depth)
+		b.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+
+		b.WriteByte('[')
+		for i, element := range v {
+			separate(i, depth+1)
+			writeJSON(b, element, pretty, depth+1)
+		}
+		separate(0, depth)
+		b.WriteByte(']')
+	case string:
+		writeJSONString(b, v)
+	case json.Number:
+		b.WriteString(jsonNumberText(v))
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	default:
+		b.WriteString("null")
+	}
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	// Strings can always be encoded
+	_ = e.Encode(s)
+	b.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// jsonNumberText writes a number in its shortest form, keeping
+// numbers too large to expand as they are
+func jsonNumberText(n json.Number) string {
+	if d, ok := parseDecimal(string(n)); ok {
+		return d.trim().String()
+	}
+
+	return string(n)
+}
+
+// jsonKind is the first character of a document, which tells
+// objects, arrays, strings, booleans, null and numbers apart
+func jsonKind(doc []byte) byte {
+	doc = bytes.TrimLeft(doc, " \t\r\n")
+	if len(doc) == 0 {
+		return 0
+	}
+
+	return doc[0]
+}
+
+// jsonMember returns the value of an object member, if doc is an
+// object that has it
+func jsonMember(doc []byte, key string) ([]byte, bool) {
+	var members map[string]json.RawMessage
+	if jsonKind(doc) != '{' || json.Unmarshal(doc, &members) != nil {
+		return nil, false
+	}
+
+	value, ok := members[key]
+	return value, ok
+}
+
+// jsonElement returns an array element if doc is an array that has
+// it. Negative positions count from the end.
+func jsonElement(doc []byte, i int64) ([]byte, bool) {
+	var elements []json.RawMessage
+	if jsonKind(doc) != '[' || json.Unmarshal(doc, &elements) != nil {
+		return nil, false
+	}
+
+	if i < 0 {
+		i += int64(len(elements))
+	}
+
+	if i < 0 || i >= int64(len(elements)) {
+		return nil, false
+	}
+
+	return elements[i], true
+}
+
+// jsonPath follows a path through a document, naming object members
+// and the positions of array elements
+func jsonPath(doc []byte, path []memoryCell) ([]byte, bool) {
+	for _, step := range path {
+		if step == nil {
+			return nil, false
+		}
+
+		var ok bool
+		if jsonKind(doc) == '[' {
+			i, err := strconv.ParseInt(strings.TrimSpace(string(step)), 10, 64)
+			if err != nil {
+				return nil, false
+			}
+
+			doc, ok = jsonElement(doc, i)
+		} else {
+			doc, ok = jsonMember(doc, string(step))
+		}
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+// jsonText is a value as text, for ->> and #>>: strings without
+// their quotes and JSON nulls as NULL
+func jsonText(doc []byte) memoryCell {
+	switch jsonKind(doc) {
+	case '"':
+		var s string
+		_ = json.Unmarshal(doc, &s)
+		return memoryCell(s)
+	case 'n':
+		return nullMemoryCell
+	}
+
+	return memoryCell(bytes.TrimSpace(doc))
+}
+
+// jsonContains reports whether document a contains document b.
+// Objects contain the objects whose members they contain, arrays
+// contain the arrays whose elements each match one of theirs and
+// other values only contain equal values. At the top level an array
+// also contains its scalar elements.
+func jsonContains(a, b interface{}, top bool) bool {
+	switch b := b.(type) {
+	case map[string]interface{}:
+		a, ok := a.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for key, value := range b {
+			member, ok := a[key]
+			if !ok || !jsonContains(member, value, false) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		a, ok := a.([]interface{})
+		if !ok {
+			return false
+		}
+
+	elements:
+		for _, value := range b {
+			for _, element := range a {
+				if jsonContains(element, value, false) {
+					continue elements
+				}
+			}
+
+			return false
+		}
+
+		return true
+	}
+
+	if elements, ok := a.([]interface{}); ok && top {
+		for _, element := range elements {
+			if jsonScalarsEqual(element, b) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return jsonScalarsEqual(a, b)
+}
+
+func jsonScalarsEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case json.Number:
+		b, ok := b.(json.Number)
+		return ok && jsonNumberText(a) == jsonNumberText(b)
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case nil:
+		return b == nil
+	}
+
+	return false
+}
+
+// jsonEntries lists the entries a GIN index holds for a document:
+// one for each scalar, made of the object keys leading to it. Array
+// positions are left out since containment ignores them, so the
+// entries of a document are also entries of every document
+// containing it.
+func jsonEntries(v interface{}) [][]byte {
+	entries := [][]byte{}
+
+	var walk func(v interface{}, path []byte)
+	walk = func(v interface{}, path []byte) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, member := range v {
+				entry := append(append([]byte{}, path...), jsonKeyEntryTag)
+				walk(member, appendTextKey(entry, memoryCell(key), true))
+			}
+		case []interface{}:
+			for _, element := range v {
+				walk(element, path)
+			}
+		default:
+			entry := append(append([]byte{}, path...), jsonValueEntryTag)
+			entries = append(entries, append(entry, encodeJSON(v)...))
+		}
+	}
+
+	walk(v, nil)
+	return entries
+}
+
+// jsonOperatorType is the type of the result of a JSON operator
+// whose left operand has type lt
+func jsonOperatorType(op Symbol, lt ColumnType) ColumnType {
+	switch op {
+	case ArrowTextSymbol, PathTextSymbol:
+		return TextType
+	case ContainsSymbol, ContainedBySymbol:
+		return BoolType
+	}
+
+	if isJSONType(lt) {
+		return lt
+	}
+
+	return JSONBType
+}
+
+// jsonOperator applies ->, ->>, #>, #>>, @> or <@ to non-NULL
+// operands. Members and elements that don't exist are NULL.
+func jsonOperator(op Symbol, l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, error) {
+	if op == ContainsSymbol || op == ContainedBySymbol {
+		if lt != JSONBType || rt != JSONBType {
+			return nil, ErrInvalidOperands
+		}
+
+		if op == ContainedBySymbol {
+			l, r = r, l
+		}
+
+		if jsonContains(jsonValue(l), jsonValue(r), true) {
+			return trueMemoryCell, nil
+		}
+
+		return falseMemoryCell, nil
+	}
+
+	if !isJSONType(lt) {
+		return nil, ErrInvalidOperands
+	}
+
+	var value []byte
+	var ok bool
+	switch {
+	case (op == ArrowSymbol || op == ArrowTextSymbol) && rt == TextType:
+		value, ok = jsonMember(l, *r.AsText())
+	case (op == ArrowSymbol || op == ArrowTextSymbol) && isIntegerType(rt):
+		value, ok = jsonElement(l, cellInt64(r, rt))
+	case (op == PathSymbol || op == PathTextSymbol) && (rt == TextType || rt == ArrayType|TextType):
+		path, err := jsonPathSteps(r, rt)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok = jsonPath(l, path)
+	default:
+		return nil, ErrInvalidOperands
+	}
+
+	if !ok {
+		return nullMemoryCell, nil
+	}
+
+	if op == ArrowTextSymbol || op == PathTextSymbol {
+		return jsonText(value), nil
+	}
+
+	return memoryCell(value), nil
+}
+
+// castJSON converts a non-NULL value between types where one is a
+// JSON or JSONB. JSONB numbers and booleans can also be converted to
+// numbers and booleans.
+func castJSON(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	switch {
+	case (from == TextType || isJSONType(from)) && isJSONType(to):
+		return jsonToMemoryCell(value, to)
+	case isJSONType(from) && to == TextType:
+		return value, nil
+	case from == JSONBType && isNumericType(to):
+		if n, ok := jsonValue(value).(json.Number); ok {
+			return textToNumber(string(n), to)
+		}
+	case from == JSONBType && to == BoolType:
+		if b, ok := jsonValue(value).(bool); ok && b {
+			return trueMemoryCell, nil
+		} else if ok {
+			return falseMemoryCell, nil
+		}
+	}
+
+	return nil, ErrInvalidCast
+}
+
+func jsonTypeof(args []Cell) (interface{}, error) {
+	switch jsonKind(args[0].(memoryCell)) {
+	case '{':
+		return "object", nil
+	case '[':
+		return "array", nil
+	case '"':
+		return "string", nil
+	case 't', 'f':
+		return "boolean", nil
+	case 'n':
+		return "null", nil
+	}
+
+	return "number", nil
+}
+
+func jsonArrayLength(args []Cell) (interface{}, error) {
+	var elements []json.RawMessage
+	doc := args[0].(memoryCell)
+	if jsonKind(doc) != '[' || json.Unmarshal(doc, &elements) != nil {
+		return nil, ErrInvalidArguments
+	}
+
+	return len(elements), nil
+}
+
+// jsonExtractPath follows the path given by the arguments after the
+// document, like #> or #>> when text is set
+func jsonExtractPath(text bool) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		path := []memoryCell{}
+		for _, arg := range args[1:] {
+			path = append(path, arg.(memoryCell))
+		}
+
+		value, ok := jsonPath(args[0].(memoryCell), path)
+		if !ok {
+			return nil, nil
+		}
+
+		if !text {
+			return string(value), nil
+		}
+
+		if s := jsonText(value); s != nil {
+			return string(s), nil
+		}
+
+		return nil, nil
+	}
+}
+
+// setJSONPath replaces the value at the end of a path. When create
+// is set a missing last step is added, to the start of an array for
+// negative positions and to its end otherwise.
+func setJSONPath(v interface{}, path []memoryCell, value interface{}, create bool) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	if path[0] == nil {
+		return nil, ErrInvalidArguments
+	}
+
+	last := len(path) == 1
+	switch v := v.(type) {
+	case map[string]interface{}:
+		key := string(path[0])
+		member, ok := v[key]
+		if !ok {
+			if create && last {
+				v[key] = value
+			}
+
+			return v, nil
+		}
+
+		member, err := setJSONPath(member, path[1:], value, create)
+		if err != nil {
+			return nil, err
+		}
+
+		v[key] = member
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(strings.TrimSpace(string(path[0])))
+		if err != nil {
+			return nil, ErrInvalidArguments
+		}
+
+		if i < 0 {
+			i += len(v)
+		}
+
+		if i >= 0 && i < len(v) {
+			v[i], err = setJSONPath(v[i], path[1:], value, create)
+			return v, err
+		}
+
+		if !create || !last {
+			return v, nil
+		}
+
+		if i < 0 {
+			return append([]interface{}{value}, v...), nil
+		}
+
+		return append(v, value), nil
+	}
+
+	// Scalars have nothing to set
+	return v, nil
+}
+
+// jsonPathSteps reads a path given as a text[] or as text like
+// {a,b}
+func jsonPathSteps(path memoryCell, typ ColumnType) ([]memoryCell, error) {
+	if typ.IsArray() {
+		return decodeArray(path), nil
+	}
+
+	return parseTextArray(*path.AsText())
+}
+
+// jsonbSet implements jsonb_set(target, path, value[, create_missing])
+// for a path of type pathType
+func jsonbSet(pathType ColumnType) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		path, err := jsonPathSteps(args[1].(memoryCell), pathType)
+		if err != nil {
+			return nil, ErrInvalidArguments
+		}
+
+		create := len(args) < 4 || *args[3].AsBool()
+		v, err := setJSONPath(jsonValue(args[0].(memoryCell)), path, jsonValue(args[2].(memoryCell)), create)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(encodeJSON(v)), nil
+	}
+}
+
+// stripJSONNulls drops object members that are null, at any depth.
+// Nulls in arrays are kept.
+func stripJSONNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			if member == nil {
+				delete(v, key)
+			} else {
+				v[key] = stripJSONNulls(member)
+			}
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = stripJSONNulls(element)
+		}
+	}
+
+	return v
+}
+
+// toJSON makes a JSON scalar of a value of type typ
+func toJSON(typ ColumnType) ScalarFunction {
+	return func(args []Cell) (interface{}, error) {
+		value := args[0].(memoryCell)
+		if typ == BoolType {
+			return strconv.FormatBool(*value.AsBool()), nil
+		}
+
+		text := *value.AsText()
+		if isNumericType(typ) {
+			text = numberToText(value, typ)
+			// NaN and infinities aren't JSON numbers
+			if _, ok := parseDecimal(text); ok {
+				return text, nil
+			}
+		}
+
+		var b strings.Builder
+		writeJSONString(&b, text)
+		return b.String(), nil
+	}
+}
+
+func init() {
+	for _, typ := range []ColumnType{JSONType, JSONBType} {
+		name := columnTypeName(typ)
+		mustRegisterFunction(name+"_typeof", FunctionSignature{Args: []ColumnType{typ}, Returns: TextType}, jsonTypeof)
+		mustRegisterFunction(name+"_array_length", FunctionSignature{Args: []ColumnType{typ}, Returns: IntType}, jsonArrayLength)
+		mustRegisterFunction(name+"_extract_path", FunctionSignature{Args: []ColumnType{typ, TextType}, Variadic: true, Returns: typ}, jsonExtractPath(false))
+		mustRegisterFunction(name+"_extract_path_text", FunctionSignature{Args: []ColumnType{typ, TextType}, Variadic: true, Returns: TextType}, jsonExtractPath(true))
+
+		scalarTypes := []ColumnType{TextType, BoolType, SmallIntType, IntType, BigIntType, NumericType, RealType, DoubleType}
+		for _, scalarType := range scalarTypes {
+			mustRegisterFunction("to_"+name, FunctionSignature{Args: []ColumnType{scalarType}, Returns: typ}, toJSON(scalarType))
+		}
+	}
+
+	jsonb := []ColumnType{JSONBType}
+	for _, pathType := range []ColumnType{TextType, ArrayType | TextType} {
+		mustRegisterFunction("jsonb_set", FunctionSignature{Args: []ColumnType{JSONBType, pathType, JSONBType}, Returns: JSONBType}, jsonbSet(pathType))
+		mustRegisterFunction("jsonb_set", FunctionSignature{Args: []ColumnType{JSONBType, pathType, JSONBType, BoolType}, Returns: JSONBType}, jsonbSet(pathType))
+	}
+	mustRegisterFunction("jsonb_strip_nulls", FunctionSignature{Args: jsonb, Returns: JSONBType}, func(args []Cell) (interface{}, error) {
+		return string(encodeJSON(stripJSONNulls(jsonValue(args[0].(memoryCell))))), nil
+	})
+	mustRegisterFunction("jsonb_pretty", FunctionSignature{Args: jsonb, Returns: TextType}, func(args []Cell) (interface{}, error) {
+		var b strings.Builder
+		writeJSON(&b, jsonValue(args[0].(memoryCell)), true, 0)
+		return b.String(), nil
+	})
+}
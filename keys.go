@@ -0,0 +1,100 @@
+package gosql
+
+// Index trees order their entries by comparing keys as bytes. Keys
+// are encoded so that this matches the order of the values for every
+// column type, and so that the key of a tuple of values orders by the
+// first value, then by the second and so on.
+
+const (
+	// Every value in a key starts with a tag. NULLs sort after all
+	// other values, like they do in an ascending ORDER BY.
+	keyValueTag byte = 1
+	keyNullTag  byte = 2
+
+	// Text can't contain its terminator: 0x00 bytes are escaped as
+	// 0x00 0xFF and text ends with 0x00 0x01
+	keyTextEscape     byte = 0x00
+	keyTextEscaped    byte = 0xFF
+	keyTextTerminator byte = 0x01
+
+	// keyNumericEnd ends positive NUMERIC values, which otherwise end
+	// with any digit. Negative ones already end with 0xFF.
+	keyNumericEnd byte = 0x00
+
+	// keyArrayEnd ends the elements of an array
+	keyArrayEnd byte = 0x00
+)
+
+// appendKey adds the key of a value of type typ to key
+func appendKey(key []byte, value memoryCell, typ ColumnType) []byte {
+	if value == nil {
+		return append(key, keyNullTag)
+	}
+
+	key = append(key, keyValueTag)
+	switch typ {
+	case IntType:
+		// Flipping the sign bit puts negative numbers first
+		return append(key, value[0]^0x80, value[1], value[2], value[3])
+	case TextType, ByteaType, JSONType, JSONBType:
+		return appendTextKey(key, value, true)
+	case NumericType:
+		// The scale is left out, 1.5 and 1.50 are equal
+		value = numericValue(value)
+		key = append(key, value...)
+		// The sign byte of positive values is 2
+		if value[0] == 2 {
+			return append(key, keyNumericEnd)
+		}
+
+		return key
+	case IntervalType:
+		return append(key, intervalKey(value)...)
+	}
+
+	// Arrays are their elements followed by a terminator that sorts
+	// before any element, so that shorter arrays come first
+	if typ.IsArray() {
+		for _, element := range decodeArray(value) {
+			key = appendKey(key, element, typ.ElementType())
+		}
+
+		return append(key, keyArrayEnd)
+	}
+
+	// Values of the other types have a fixed size and are stored in
+	// order already
+	return append(key, value...)
+}
+
+// appendTextKey adds escaped text to key. Without the terminator the
+// result is a prefix of the key of any text starting with text.
+func appendTextKey(key []byte, text memoryCell, terminate bool) []byte {
+	for _, b := range text {
+		key = append(key, b)
+		if b == keyTextEscape {
+			key = append(key, keyTextEscaped)
+		}
+	}
+
+	if terminate {
+		key = append(key, keyTextEscape, keyTextTerminator)
+	}
+
+	return key
+}
+
+// encodeKey returns the key of a tuple of values of the given types
+func encodeKey(values []memoryCell, types []ColumnType) []byte {
+	key := []byte{}
+	for i, value := range values {
+		key = appendKey(key, value, types[i])
+	}
+
+	return key
+}
+
+// isNullKey reports whether a key starts with a NULL
+func isNullKey(key []byte) bool {
+	return len(key) > 0 && key[0] == keyNullTag
+}
@@ -0,0 +1,93 @@
+package gosql
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendKey(t *testing.T) {
+	intCell := func(i int64, typ ColumnType) memoryCell {
+		mc, err := integerToMemoryCell(i, typ)
+		assert.Nil(t, err)
+		return mc
+	}
+	numericCell := func(s string) memoryCell {
+		d, ok := parseDecimal(s)
+		assert.True(t, ok)
+		return decimalToMemoryCell(d)
+	}
+	dateCell := func(days int64) memoryCell {
+		mc, err := dateToMemoryCell(days)
+		assert.Nil(t, err)
+		return mc
+	}
+
+	// Each list is in ascending order
+	tests := []struct {
+		typ    ColumnType
+		values []memoryCell
+	}{
+		{IntType, []memoryCell{intCell(-2147483648, IntType), intCell(-1, IntType), intCell(0, IntType), intCell(1, IntType), intCell(2147483647, IntType), nil}},
+		{SmallIntType, []memoryCell{intCell(-5, SmallIntType), intCell(3, SmallIntType), nil}},
+		{BigIntType, []memoryCell{intCell(-3000000000, BigIntType), intCell(-1, BigIntType), intCell(3000000000, BigIntType), nil}},
+		{DoubleType, []memoryCell{doubleToMemoryCell(-1.5), doubleToMemoryCell(0), doubleToMemoryCell(0.25), doubleToMemoryCell(1e300), nil}},
+		{NumericType, []memoryCell{numericCell("-10.5"), numericCell("-1.25"), numericCell("-1.2"), numericCell("0"), numericCell("1.2"), numericCell("1.25"), numericCell("10.5"), nil}},
+		{TextType, []memoryCell{memoryCell(""), memoryCell("a"), memoryCell("a\x00"), memoryCell("a\x00b"), memoryCell("a\x01"), memoryCell("ab"), memoryCell("b"), nil}},
+		{BoolType, []memoryCell{falseMemoryCell, trueMemoryCell, nil}},
+		{ByteaType, []memoryCell{memoryCell{}, memoryCell("\x00"), memoryCell("\x00\xff"), memoryCell("\x01"), nil}},
+		{DateType, []memoryCell{dateCell(-1), dateCell(0), dateCell(1), nil}},
+		{IntervalType, []memoryCell{
+			intervalToMemoryCell(Interval{Days: -1}),
+			intervalToMemoryCell(Interval{Microseconds: microsecondsPerDay - 1}),
+			intervalToMemoryCell(Interval{Months: 1, Days: -1}),
+			intervalToMemoryCell(Interval{Months: 1, Microseconds: 1}),
+			nil,
+		}},
+		{ArrayType | IntType, []memoryCell{
+			encodeArray([]memoryCell{}),
+			encodeArray([]memoryCell{intCell(-1, IntType)}),
+			encodeArray([]memoryCell{intCell(1, IntType)}),
+			encodeArray([]memoryCell{intCell(1, IntType), intCell(0, IntType)}),
+			encodeArray([]memoryCell{intCell(1, IntType), nil}),
+			encodeArray([]memoryCell{intCell(2, IntType)}),
+			nil,
+		}},
+	}
+
+	for _, test := range tests {
+		for i := 1; i < len(test.values); i++ {
+			low := appendKey(nil, test.values[i-1], test.typ)
+			high := appendKey(nil, test.values[i], test.typ)
+			assert.Equal(t, -1, bytes.Compare(low, high), "%s %v < %v", test.typ, test.values[i-1], test.values[i])
+		}
+	}
+
+	// Tuples order by their first value before their second, whatever
+	// the length of the first
+	types := []ColumnType{TextType, NumericType}
+	tuples := [][]memoryCell{
+		{memoryCell("a"), numericCell("100")},
+		{memoryCell("a"), nil},
+		{memoryCell("a\x00"), numericCell("-1")},
+		{memoryCell("ab"), numericCell("0.5")},
+		{memoryCell("ab"), numericCell("0.55")},
+		{nil, numericCell("1")},
+		{nil, nil},
+	}
+	for i := 1; i < len(tuples); i++ {
+		low := encodeKey(tuples[i-1], types)
+		high := encodeKey(tuples[i], types)
+		assert.Equal(t, -1, bytes.Compare(low, high), "%v < %v", tuples[i-1], tuples[i])
+	}
+
+	// NUMERIC values equal apart from their scale have the same key
+	assert.Equal(t, appendKey(nil, numericCell("1.5"), NumericType), appendKey(nil, numericCell("1.50"), NumericType))
+	assert.Equal(t, appendKey(nil, numericCell("-0.0"), NumericType), appendKey(nil, numericCell("0"), NumericType))
+
+	// Unterminated text is a prefix of the keys of longer text
+	prefix := appendTextKey([]byte{keyValueTag}, memoryCell("a\x00"), false)
+	assert.True(t, bytes.HasPrefix(appendKey(nil, memoryCell("a\x00b"), TextType), prefix))
+	assert.False(t, bytes.HasPrefix(appendKey(nil, memoryCell("a"), TextType), prefix))
+}
@@ -39,26 +39,117 @@ const (
 	NullKeyword       Keyword = "null"
 	LimitKeyword      Keyword = "limit"
 	OffsetKeyword     Keyword = "offset"
+	UpdateKeyword     Keyword = "update"
+	SetKeyword        Keyword = "set"
+	DeleteKeyword     Keyword = "delete"
+	JoinKeyword       Keyword = "join"
+	InnerKeyword      Keyword = "inner"
+	LeftKeyword       Keyword = "left"
+	RightKeyword      Keyword = "right"
+	FullKeyword       Keyword = "full"
+	OuterKeyword      Keyword = "outer"
+	CrossKeyword      Keyword = "cross"
+	GroupKeyword      Keyword = "group"
+	ByKeyword         Keyword = "by"
+	HavingKeyword     Keyword = "having"
+	OrderKeyword      Keyword = "order"
+	AscKeyword        Keyword = "asc"
+	DescKeyword       Keyword = "desc"
+	NullsKeyword      Keyword = "nulls"
+	FirstKeyword      Keyword = "first" // not reserved
+	LastKeyword       Keyword = "last"  // not reserved
+	DistinctKeyword   Keyword = "distinct"
+	UnionKeyword      Keyword = "union"
+	IntersectKeyword  Keyword = "intersect"
+	ExceptKeyword     Keyword = "except"
+	AllKeyword        Keyword = "all"
+	NotKeyword        Keyword = "not"
+	InKeyword         Keyword = "in"
+	ExistsKeyword     Keyword = "exists"
+	WithKeyword       Keyword = "with"
+	RecursiveKeyword  Keyword = "recursive"
+	IsKeyword         Keyword = "is"
+	LikeKeyword       Keyword = "like"
+	IlikeKeyword      Keyword = "ilike"
+	EscapeKeyword     Keyword = "escape"
+	BetweenKeyword    Keyword = "between"
+	CaseKeyword       Keyword = "case"
+	WhenKeyword       Keyword = "when"
+	ThenKeyword       Keyword = "then"
+	ElseKeyword       Keyword = "else"
+	EndKeyword        Keyword = "end"
+	CoalesceKeyword   Keyword = "coalesce"
+	NullifKeyword     Keyword = "nullif"
+	GreatestKeyword   Keyword = "greatest"
+	LeastKeyword      Keyword = "least"
+	CastKeyword       Keyword = "cast"
+	SmallintKeyword   Keyword = "smallint"
+	BigintKeyword     Keyword = "bigint"
+	RealKeyword       Keyword = "real"
+	DoubleKeyword     Keyword = "double precision"
+	NumericKeyword    Keyword = "numeric"
+	DecimalKeyword    Keyword = "decimal"
+	DateKeyword       Keyword = "date" // not reserved
+	TimeKeyword       Keyword = "time" // not reserved
+	TimestampKeyword  Keyword = "timestamp"
+	IntervalKeyword   Keyword = "interval"
+	ByteaKeyword      Keyword = "bytea"
+	UuidKeyword       Keyword = "uuid"
+	JsonKeyword       Keyword = "json"
+	JsonbKeyword      Keyword = "jsonb"
+	UsingKeyword      Keyword = "using"
+	ArrayKeyword      Keyword = "array"
+	AnyKeyword        Keyword = "any"
+	DefaultKeyword    Keyword = "default"
+	CheckKeyword      Keyword = "check"
+	ReferencesKeyword Keyword = "references"
+	ForeignkeyKeyword Keyword = "foreign key"
+	CascadeKeyword    Keyword = "cascade"
+	RestrictKeyword   Keyword = "restrict"
+	NoactionKeyword   Keyword = "no action"
+
+	CurrentDateKeyword      Keyword = "current_date"
+	CurrentTimestampKeyword Keyword = "current_timestamp"
 )
 
 // for storing SQL syntax
 type Symbol string
 
 const (
-	SemicolonSymbol  Symbol = ";"
-	AsteriskSymbol   Symbol = "*"
-	CommaSymbol      Symbol = ","
-	LeftParenSymbol  Symbol = "("
-	RightParenSymbol Symbol = ")"
-	EqSymbol         Symbol = "="
-	NeqSymbol        Symbol = "<>"
-	NeqSymbol2       Symbol = "!="
-	ConcatSymbol     Symbol = "||"
-	PlusSymbol       Symbol = "+"
-	LtSymbol         Symbol = "<"
-	LteSymbol        Symbol = "<="
-	GtSymbol         Symbol = ">"
-	GteSymbol        Symbol = ">="
+	SemicolonSymbol    Symbol = ";"
+	AsteriskSymbol     Symbol = "*"
+	CommaSymbol        Symbol = ","
+	LeftParenSymbol    Symbol = "("
+	RightParenSymbol   Symbol = ")"
+	EqSymbol           Symbol = "="
+	NeqSymbol          Symbol = "<>"
+	NeqSymbol2         Symbol = "!="
+	ConcatSymbol       Symbol = "||"
+	PlusSymbol         Symbol = "+"
+	MinusSymbol        Symbol = "-"
+	SlashSymbol        Symbol = "/"
+	PercentSymbol      Symbol = "%"
+	LtSymbol           Symbol = "<"
+	LteSymbol          Symbol = "<="
+	GtSymbol           Symbol = ">"
+	GteSymbol          Symbol = ">="
+	DotSymbol          Symbol = "."
+	TildeSymbol        Symbol = "~"
+	TildeStarSymbol    Symbol = "~*"
+	NotTildeSymbol     Symbol = "!~"
+	NotTildeStarSymbol Symbol = "!~*"
+	CastSymbol         Symbol = "::"
+
+	ArrowSymbol       Symbol = "->"
+	ArrowTextSymbol   Symbol = "->>"
+	PathSymbol        Symbol = "#>"
+	PathTextSymbol    Symbol = "#>>"
+	ContainsSymbol    Symbol = "@>"
+	ContainedBySymbol Symbol = "<@"
+
+	LeftBracketSymbol  Symbol = "["
+	RightBracketSymbol Symbol = "]"
+	OverlapSymbol      Symbol = "&&"
 )
 
 type TokenKind uint
@@ -79,37 +170,104 @@ type Token struct {
 	Loc   Location
 }
 
+// bindingPower is how tightly a binary operator holds its operands.
+// Operators with the same binding power associate to the left.
 func (t Token) bindingPower() uint {
 	switch t.Kind {
 	case KeywordKind:
 		switch Keyword(t.Value) {
-		case AndKeyword:
-			fallthrough
 		case OrKeyword:
 			return 1
+		case AndKeyword:
+			return 2
+		case IsKeyword:
+			return 4
+		case InKeyword:
+			fallthrough
+		case BetweenKeyword:
+			fallthrough
+		case LikeKeyword:
+			fallthrough
+		case IlikeKeyword:
+			return 6
 		}
 	case SymbolKind:
 		switch Symbol(t.Value) {
 		case EqSymbol:
 			fallthrough
 		case NeqSymbol:
-			return 2
-
+			fallthrough
 		case LtSymbol:
 			fallthrough
 		case GtSymbol:
-			return 3
-
-		// For some reason these are grouped separately
+			fallthrough
 		case LteSymbol:
 			fallthrough
 		case GteSymbol:
-			return 4
+			return 5
 
 		case ConcatSymbol:
 			fallthrough
+		case TildeSymbol:
+			fallthrough
+		case TildeStarSymbol:
+			fallthrough
+		case NotTildeSymbol:
+			fallthrough
+		case NotTildeStarSymbol:
+			fallthrough
+		case ArrowSymbol:
+			fallthrough
+		case ArrowTextSymbol:
+			fallthrough
+		case PathSymbol:
+			fallthrough
+		case PathTextSymbol:
+			fallthrough
+		case ContainsSymbol:
+			fallthrough
+		case ContainedBySymbol:
+			fallthrough
+		case OverlapSymbol:
+			return 7
+
 		case PlusSymbol:
-			return 5
+			fallthrough
+		case MinusSymbol:
+			return 8
+
+		case AsteriskSymbol:
+			fallthrough
+		case SlashSymbol:
+			fallthrough
+		case PercentSymbol:
+			return 9
+
+		// Binds more tightly than prefix operators, so -1::text is
+		// -(1::text)
+		case CastSymbol:
+			return 11
+
+		// Subscripts bind most tightly, so -a[1] is -(a[1])
+		case LeftBracketSymbol:
+			return 12
+		}
+	}
+
+	return 0
+}
+
+// prefixBindingPower is how tightly a prefix operator holds its
+// operand
+func (t Token) prefixBindingPower() uint {
+	switch t.Kind {
+	case KeywordKind:
+		if Keyword(t.Value) == NotKeyword {
+			return 3
+		}
+	case SymbolKind:
+		if Symbol(t.Value) == MinusSymbol {
+			return 10
 		}
 	}
 
@@ -191,6 +349,11 @@ func lexSymbol(source string, ic cursor) (*Token, cursor, bool) {
 		fallthrough
 	case ' ':
 		return nil, cur, true
+	// Leave numbers like .5 to lexNumeric
+	case '.':
+		if cur.pointer < uint(len(source)) && source[cur.pointer] >= '0' && source[cur.pointer] <= '9' {
+			return nil, ic, false
+		}
 	}
 
 	// Syntax that should be kept
@@ -204,11 +367,29 @@ func lexSymbol(source string, ic cursor) (*Token, cursor, bool) {
 		GteSymbol,
 		ConcatSymbol,
 		PlusSymbol,
+		MinusSymbol,
+		SlashSymbol,
+		PercentSymbol,
 		CommaSymbol,
 		LeftParenSymbol,
 		RightParenSymbol,
 		SemicolonSymbol,
 		AsteriskSymbol,
+		DotSymbol,
+		TildeSymbol,
+		TildeStarSymbol,
+		NotTildeSymbol,
+		NotTildeStarSymbol,
+		CastSymbol,
+		ArrowSymbol,
+		ArrowTextSymbol,
+		PathSymbol,
+		PathTextSymbol,
+		ContainsSymbol,
+		ContainedBySymbol,
+		LeftBracketSymbol,
+		RightBracketSymbol,
+		OverlapSymbol,
 	}
 
 	var options []string
@@ -265,6 +446,72 @@ func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 		NullKeyword,
 		LimitKeyword,
 		OffsetKeyword,
+		UpdateKeyword,
+		SetKeyword,
+		DeleteKeyword,
+		JoinKeyword,
+		InnerKeyword,
+		LeftKeyword,
+		RightKeyword,
+		FullKeyword,
+		OuterKeyword,
+		CrossKeyword,
+		GroupKeyword,
+		ByKeyword,
+		HavingKeyword,
+		OrderKeyword,
+		AscKeyword,
+		DescKeyword,
+		NullsKeyword,
+		DistinctKeyword,
+		UnionKeyword,
+		IntersectKeyword,
+		ExceptKeyword,
+		AllKeyword,
+		NotKeyword,
+		InKeyword,
+		ExistsKeyword,
+		WithKeyword,
+		RecursiveKeyword,
+		IsKeyword,
+		LikeKeyword,
+		IlikeKeyword,
+		EscapeKeyword,
+		BetweenKeyword,
+		CaseKeyword,
+		WhenKeyword,
+		ThenKeyword,
+		ElseKeyword,
+		EndKeyword,
+		CoalesceKeyword,
+		NullifKeyword,
+		GreatestKeyword,
+		LeastKeyword,
+		CastKeyword,
+		SmallintKeyword,
+		BigintKeyword,
+		RealKeyword,
+		DoubleKeyword,
+		NumericKeyword,
+		DecimalKeyword,
+		TimestampKeyword,
+		IntervalKeyword,
+		ByteaKeyword,
+		UuidKeyword,
+		JsonKeyword,
+		JsonbKeyword,
+		UsingKeyword,
+		ArrayKeyword,
+		AnyKeyword,
+		DefaultKeyword,
+		CheckKeyword,
+		ReferencesKeyword,
+		ForeignkeyKeyword,
+		CascadeKeyword,
+		RestrictKeyword,
+		NoactionKeyword,
+		CurrentDateKeyword,
+		CurrentTimestampKeyword,
 	}
 
 	var options []string
@@ -277,6 +524,14 @@ func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 		return nil, ic, false
 	}
 
+	// Keywords must end at a word boundary, otherwise identifiers
+	// like settings or updated_at are split into a keyword and a
+	// shorter identifier
+	end := ic.pointer + uint(len(match))
+	if end < uint(len(source)) && isIdentifierCharacter(source[end]) {
+		return nil, ic, false
+	}
+
 	cur.pointer = ic.pointer + uint(len(match))
 	cur.loc.Col = ic.loc.Col + uint(len(match))
 
@@ -412,6 +667,13 @@ func lexCharacterDelimited(source string, ic cursor, delimiter byte) (*Token, cu
 	return nil, ic, false
 }
 
+func isIdentifierCharacter(c byte) bool {
+	// Other characters count too, big ignoring non-ascii for now
+	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	isNumeric := c >= '0' && c <= '9'
+	return isAlphabetical || isNumeric || c == '$' || c == '_'
+}
+
 func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
 	// Handle separately if is a double-quoted identifier
 	if token, newCursor, ok := lexCharacterDelimited(source, ic, '"'); ok {
@@ -435,10 +697,7 @@ func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
 	for ; cur.pointer < uint(len(source)); cur.pointer++ {
 		c = source[cur.pointer]
 
-		// Other characters count too, big ignoring non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
+		if isIdentifierCharacter(c) {
 			value = append(value, c)
 			cur.loc.Col++
 			continue
@@ -495,9 +754,6 @@ lex:
 		if len(tokens) > 0 {
 			hint = " after " + tokens[len(tokens)-1].Value
 		}
-		for _, t := range tokens {
-			fmt.Println(t.Value)
-		}
 		return nil, fmt.Errorf("Unable to lex token%s, at %d:%d", hint, cur.loc.Line, cur.loc.Col)
 	}
 
@@ -151,6 +151,51 @@ func TestToken_lexSymbol(t *testing.T) {
 			symbol: true,
 			value:  "||",
 		},
+		{
+			symbol: true,
+			value:  ".",
+		},
+		{
+			symbol: true,
+			value:  "~* ",
+		},
+		{
+			symbol: true,
+			value:  "!~ ",
+		},
+		{
+			symbol: true,
+			value:  "!~* ",
+		},
+		{
+			symbol: true,
+			value:  "::",
+		},
+		{
+			symbol: true,
+			value:  "->> ",
+		},
+		{
+			symbol: true,
+			value:  "#>",
+		},
+		{
+			symbol: true,
+			value:  "<@ ",
+		},
+		{
+			symbol: true,
+			value:  "&&",
+		},
+		{
+			symbol: true,
+			value:  "[",
+		},
+		// false tests
+		{
+			symbol: false,
+			value:  ".5",
+		},
 	}
 
 	for _, test := range tests {
@@ -266,6 +311,22 @@ func TestToken_lexKeyword(t *testing.T) {
 			keyword: false,
 			value:   "flubbrety",
 		},
+		{
+			keyword: false,
+			value:   "settings",
+		},
+		{
+			keyword: false,
+			value:   "integer",
+		},
+		{
+			keyword: false,
+			value:   "date",
+		},
+		{
+			keyword: false,
+			value:   "last",
+		},
 	}
 
 	for _, test := range tests {
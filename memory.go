@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/petar/GoLLRB/llrb"
 )
@@ -30,7 +36,7 @@ func (mc memoryCell) AsInt() *int32 {
 }
 
 func (mc memoryCell) AsText() *string {
-	if len(mc) == 0 {
+	if mc == nil {
 		return nil
 	}
 
@@ -59,20 +65,8 @@ func (mc memoryCell) equals(b memoryCell) bool {
 
 func literalToMemoryCell(t *Token) memoryCell {
 	if t.Kind == NumericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.Value)
-		if err != nil {
-			fmt.Printf("Corrupted data [%s]: %s\n", t.Value, err)
-			return nil
-		}
-
-		// TODO: handle bigint
-		err = binary.Write(buf, binary.BigEndian, int32(i))
-		if err != nil {
-			fmt.Printf("Corrupted data [%s]: %s\n", buf.String(), err)
-			return nil
-		}
-		return buf.Bytes()
+		mc, _ := numericLiteralToMemoryCell(t.Value)
+		return mc
 	}
 
 	if t.Kind == StringKind {
@@ -100,63 +94,200 @@ var (
 )
 
 type treeItem struct {
-	value memoryCell
+	key   []byte
 	index uint
 }
 
+// Less orders items by key and then by row index so that every row
+// in a non-unique index has its own entry and can be removed
+// individually.
 func (te treeItem) Less(than llrb.Item) bool {
-	return bytes.Compare(te.value, than.(treeItem).value) < 0
+	other := than.(treeItem)
+	if c := bytes.Compare(te.key, other.key); c != 0 {
+		return c < 0
+	}
+
+	return te.index < other.index
 }
 
+// maxRowIndex sorts after every row with the same key, for
+// descending scans that must include all of them
+const maxRowIndex = ^uint(0)
+
+// Index types, as shown in TableMetadata. GIN indexes hold an entry
+// for each scalar of a JSONB document or each element of an array,
+// to find the documents or arrays containing another.
+const (
+	btreeIndexType = "rbtree"
+	ginIndexType   = "gin"
+)
+
 type index struct {
 	name       string
-	exp        Expression
+	exps       []Expression
 	unique     bool
 	primaryKey bool
 	tree       *llrb.LLRB
 	typ        string
+
+	// valueTypes are the types of the indexed values, one for each
+	// expression
+	valueTypes []ColumnType
+}
+
+// key encodes a value of the first indexed expression for the tree.
+// With several expressions it is a prefix of the keys of the rows
+// with that value.
+func (i *index) key(value memoryCell) []byte {
+	return appendKey(nil, value, i.valueTypes[0])
+}
+
+// keys lists the entries of the indexed values in the tree: their
+// key, or for GIN indexes the entries of the document or array
+func (i *index) keys(values []memoryCell) [][]byte {
+	if i.typ != ginIndexType {
+		return [][]byte{encodeKey(values, i.valueTypes)}
+	}
+
+	if values[0] == nil {
+		return nil
+	}
+
+	if i.valueTypes[0].IsArray() {
+		return arrayEntries(values[0], i.valueTypes[0])
+	}
+
+	return jsonEntries(jsonValue(values[0]))
+}
+
+// values evaluates the indexed expressions for a row. hasNull
+// reports whether any of them is NULL.
+func (i *index) values(t *table, rowIndex uint) ([]memoryCell, bool, error) {
+	values := []memoryCell{}
+	hasNull := false
+	for _, exp := range i.exps {
+		value, _, _, err := t.evaluateCell(rowIndex, exp)
+		if err != nil {
+			return nil, false, err
+		}
+
+		values = append(values, value)
+		hasNull = hasNull || value == nil
+	}
+
+	return values, hasNull, nil
 }
 
 func (i *index) addRow(t *table, rowIndex uint) error {
-	indexValue, _, _, err := t.evaluateCell(rowIndex, i.exp)
+	values, hasNull, err := i.values(t, rowIndex)
 	if err != nil {
 		return err
 	}
 
-	if indexValue == nil {
+	if hasNull && i.primaryKey {
 		return ErrViolatesNotNullConstraint
 	}
 
-	if i.unique && i.tree.Has(treeItem{value: indexValue}) {
+	// NULLs are never equal to each other so they can't conflict
+	keys := i.keys(values)
+	if i.unique && !hasNull && i.hasKey(keys[0]) {
 		return ErrViolatesUniqueConstraint
 	}
 
-	i.tree.InsertNoReplace(treeItem{
-		value: indexValue,
-		index: rowIndex,
-	})
+	for _, key := range keys {
+		i.tree.InsertNoReplace(treeItem{
+			key:   key,
+			index: rowIndex,
+		})
+	}
+	return nil
+}
+
+// removeRow drops the entry for a row, computed from its current
+// values. Rows that were never added are ignored.
+func (i *index) removeRow(t *table, rowIndex uint) error {
+	values, _, err := i.values(t, rowIndex)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range i.keys(values) {
+		i.tree.Delete(treeItem{
+			key:   key,
+			index: rowIndex,
+		})
+	}
 	return nil
 }
 
+// expressionsCode returns the indexed expressions separated by commas
+func (i *index) expressionsCode() string {
+	codes := []string{}
+	for _, exp := range i.exps {
+		codes = append(codes, exp.GenerateCode())
+	}
+
+	return strings.Join(codes, ", ")
+}
+
+// columnPosition returns the position of the expression of the index
+// that is just the column name, or -1
+func (i *index) columnPosition(name string) int {
+	for p, exp := range i.exps {
+		if exp.Kind == LiteralKind && exp.Literal.Kind == IdentifierKind && exp.Literal.Value == name {
+			return p
+		}
+	}
+
+	return -1
+}
+
+func (i *index) hasKey(key []byte) bool {
+	found := false
+	i.tree.AscendGreaterOrEqual(treeItem{key: key}, func(item llrb.Item) bool {
+		found = bytes.Equal(item.(treeItem).key, key)
+		return false
+	})
+	return found
+}
+
 func (i *index) applicableValue(exp Expression) *Expression {
 	if exp.Kind != BinaryKind {
 		return nil
 	}
 
 	be := exp.Binary
+	if be.Quantifier != nil {
+		return nil
+	}
+
 	// Find the column and the value in the binary Expression
 	columnExp := be.A
 	valueExp := be.B
-	if columnExp.GenerateCode() != i.exp.GenerateCode() {
+	if columnExp.GenerateCode() != i.exps[0].GenerateCode() {
 		columnExp = be.B
 		valueExp = be.A
 	}
 
 	// Neither side is applicable, return nil
-	if columnExp.GenerateCode() != i.exp.GenerateCode() {
+	if columnExp.GenerateCode() != i.exps[0].GenerateCode() {
 		return nil
 	}
 
+	// A LIKE pattern with a fixed prefix can be looked up as a range
+	// of values starting with it
+	if be.Op.Kind == KeywordKind && Keyword(be.Op.Value) == LikeKeyword {
+		if be.A.GenerateCode() != i.exps[0].GenerateCode() || be.Escape != nil {
+			return nil
+		}
+
+		if valueExp.Kind != LiteralKind || valueExp.Literal.Kind != StringKind || likePrefix(valueExp.Literal.Value) == "" {
+			return nil
+		}
+
+		return &valueExp
+	}
+
 	supportedChecks := []Symbol{EqSymbol, NeqSymbol, GtSymbol, GteSymbol, LtSymbol, LteSymbol}
 	supported := false
 	for _, sym := range supportedChecks {
@@ -169,7 +300,7 @@ func (i *index) applicableValue(exp Expression) *Expression {
 		return nil
 	}
 
-	if valueExp.Kind != LiteralKind {
+	if valueExp.Kind != LiteralKind && !isConstant(valueExp) {
 		fmt.Println("Only index checks on literals supported")
 		return nil
 	}
@@ -177,532 +308,4183 @@ func (i *index) applicableValue(exp Expression) *Expression {
 	return &valueExp
 }
 
-func (i *index) newTableFromSubset(t *table, exp Expression) *table {
-	valueExp := i.applicableValue(exp)
-	if valueExp == nil {
-		return t
-	}
-
-	value, _, _, err := createTable().evaluateCell(0, *valueExp)
-	if err != nil {
-		fmt.Println(err)
-		return t
+// indexedOp returns the comparison of be as seen from the indexed
+// expression, so 2 < x compares x with 2 using >
+func (i *index) indexedOp(be *BinaryExpression) Symbol {
+	op := Symbol(be.Op.Value)
+	if be.A.GenerateCode() == i.exps[0].GenerateCode() {
+		return op
 	}
 
-	tiValue := treeItem{value: value}
-
-	indexes := []uint{}
-	switch Symbol(exp.Binary.Op.Value) {
-	case EqSymbol:
-		i.tree.AscendGreaterOrEqual(tiValue, func(i llrb.Item) bool {
-			ti := i.(treeItem)
-
-			if !bytes.Equal(ti.value, value) {
-				return false
-			}
-
-			indexes = append(indexes, ti.index)
-			return true
-		})
-	case NeqSymbol:
-		i.tree.AscendGreaterOrEqual(llrb.Inf(-1), func(i llrb.Item) bool {
-			ti := i.(treeItem)
-			if bytes.Equal(ti.value, value) {
-				indexes = append(indexes, ti.index)
-			}
-
-			return true
-		})
+	switch op {
 	case LtSymbol:
-		i.tree.DescendLessOrEqual(tiValue, func(i llrb.Item) bool {
-			ti := i.(treeItem)
-			if bytes.Compare(ti.value, value) < 0 {
-				indexes = append(indexes, ti.index)
-			}
-
-			return true
-		})
+		return GtSymbol
 	case LteSymbol:
-		i.tree.DescendLessOrEqual(tiValue, func(i llrb.Item) bool {
-			ti := i.(treeItem)
-			if bytes.Compare(ti.value, value) <= 0 {
-				indexes = append(indexes, ti.index)
-			}
-
-			return true
-		})
+		return GteSymbol
 	case GtSymbol:
-		i.tree.AscendGreaterOrEqual(tiValue, func(i llrb.Item) bool {
-			ti := i.(treeItem)
-			if bytes.Compare(ti.value, value) > 0 {
-				indexes = append(indexes, ti.index)
-			}
-
-			return true
-		})
+		return LtSymbol
 	case GteSymbol:
-		i.tree.AscendGreaterOrEqual(tiValue, func(i llrb.Item) bool {
-			ti := i.(treeItem)
-			if bytes.Compare(ti.value, value) >= 0 {
-				indexes = append(indexes, ti.index)
-			}
+		return LteSymbol
+	}
 
-			return true
-		})
+	return op
+}
+
+// isConstant reports whether exp is a literal value rather than a
+// column, a typed literal like DATE '2024-01-01' or an array of
+// constants
+func isConstant(exp Expression) bool {
+	if exp.Kind == CastKind {
+		return isConstant(exp.Cast.Exp)
 	}
 
-	newT := createTable()
-	newT.columns = t.columns
-	newT.columnTypes = t.columnTypes
-	newT.indexes = t.indexes
-	newT.rows = [][]memoryCell{}
+	if exp.Kind == ArrayKind {
+		for _, item := range *exp.Array.Items {
+			if !isConstant(*item) {
+				return false
+			}
+		}
 
-	for _, index := range indexes {
-		newT.rows = append(newT.rows, t.rows[index])
+		return true
 	}
 
-	return newT
+	return exp.Kind == LiteralKind && exp.Literal.Kind != IdentifierKind
 }
 
-type table struct {
-	name        string
-	columns     []string
-	columnTypes []ColumnType
-	rows        [][]memoryCell
-	indexes     []*index
-}
+// containedValue finds the document or array rows must contain to
+// match exp when it checks containment in the indexed column, like
+// payload @> '{"a": 1}'
+func (i *index) containedValue(exp Expression) *Expression {
+	if exp.Kind != BinaryKind || exp.Binary.Op.Kind != SymbolKind {
+		return nil
+	}
 
-func createTable() *table {
-	return &table{
-		name:        "?tmp?",
-		columns:     nil,
-		columnTypes: nil,
-		rows:        nil,
-		indexes:     []*index{},
+	be := exp.Binary
+	switch Symbol(be.Op.Value) {
+	case ContainsSymbol:
+		if be.A.GenerateCode() == i.exps[0].GenerateCode() && isConstant(be.B) {
+			return &be.B
+		}
+	case ContainedBySymbol:
+		if be.B.GenerateCode() == i.exps[0].GenerateCode() && isConstant(be.A) {
+			return &be.A
+		}
 	}
+
+	return nil
 }
 
-func (t *table) evaluateLiteralCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
-	if exp.Kind != LiteralKind {
-		return nil, "", 0, ErrInvalidCell
+// applicable reports whether the index can find the rows matching
+// exp
+func (i *index) applicable(exp Expression) bool {
+	if i.typ == ginIndexType {
+		return i.containedValue(exp) != nil
 	}
 
-	lit := exp.Literal
-	if lit.Kind == IdentifierKind {
-		for i, tableCol := range t.columns {
-			if tableCol == lit.Value {
-				return t.rows[rowIndex][i], tableCol, t.columnTypes[i], nil
-			}
+	switch exp.Kind {
+	case InKind:
+		in := exp.In
+		if in.Not || in.List == nil || in.Exp.GenerateCode() != i.exps[0].GenerateCode() {
+			return false
 		}
 
-		return nil, "", 0, ErrColumnDoesNotExist
-	}
+		for _, item := range *in.List {
+			if !isConstant(*item) {
+				return false
+			}
+		}
 
-	columnType := IntType
-	if lit.Kind == StringKind {
-		columnType = TextType
-	} else if lit.Kind == BoolKind {
-		columnType = BoolType
+		return true
+	case BetweenKind:
+		be := exp.Between
+		return !be.Not && be.Exp.GenerateCode() == i.exps[0].GenerateCode() && isConstant(be.Low) && isConstant(be.High)
 	}
 
-	return literalToMemoryCell(lit), "?column?", columnType, nil
+	return i.prefixValues(exp) != nil || i.applicableValue(exp) != nil
 }
 
-func (t *table) evaluateBinaryCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
-	if exp.Kind != BinaryKind {
-		return nil, "", 0, ErrInvalidCell
+// lookupValue evaluates a constant to compare to the values of the
+// indexed expression at position p, converted to their type. It fails
+// if that would change the value, like rounding 2.5 for an int index,
+// since rows could be missed.
+func (i *index) lookupValue(exp Expression, p int) (memoryCell, bool) {
+	value, _, typ, err := createTable().evaluateCell(0, exp)
+	if err != nil {
+		return nil, false
 	}
 
-	bexp := exp.Binary
+	valueType := i.valueTypes[p]
+	if value == nil || typ == valueType {
+		return value, true
+	}
 
-	l, _, lt, err := t.evaluateCell(rowIndex, bexp.A)
-	if err != nil {
-		return nil, "", 0, err
+	if !isNumericType(typ) || !isNumericType(valueType) {
+		return nil, false
 	}
 
-	r, _, rt, err := t.evaluateCell(rowIndex, bexp.B)
+	converted, err := castNumber(value, typ, valueType)
 	if err != nil {
-		return nil, "", 0, err
+		return nil, false
 	}
 
-	switch bexp.Op.Kind {
-	case SymbolKind:
-		switch Symbol(bexp.Op.Value) {
-		case EqSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+	back, err := castNumber(converted, valueType, typ)
+	if err != nil || compareCells(back, value, typ) != 0 {
+		return nil, false
+	}
 
-			eq := l.equals(r)
-			if lt == TextType && rt == TextType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+	return converted, true
+}
 
-			if lt == IntType && rt == IntType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+// rowIndexesEqual returns the positions of the rows whose first
+// indexed value is value
+func (i *index) rowIndexesEqual(value memoryCell) []uint {
+	return i.rowIndexesWithPrefix(i.key(value))
+}
 
-			if lt == BoolType && rt == BoolType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+// rowIndexesWithKey returns the positions of the rows with an entry
+// for key
+func (i *index) rowIndexesWithKey(key []byte) []uint {
+	indexes := []uint{}
+	i.tree.AscendGreaterOrEqual(treeItem{key: key}, func(i llrb.Item) bool {
+		ti := i.(treeItem)
+		if !bytes.Equal(ti.key, key) {
+			return false
+		}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case NeqSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+		indexes = append(indexes, ti.index)
+		return true
+	})
 
-			if lt != rt || !l.equals(r) {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+	return indexes
+}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case ConcatSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", TextType, nil
-			}
+// rowIndexesWithPrefix returns the positions of the rows with an
+// entry starting with prefix
+func (i *index) rowIndexesWithPrefix(prefix []byte) []uint {
+	indexes := []uint{}
+	i.tree.AscendGreaterOrEqual(treeItem{key: prefix}, func(i llrb.Item) bool {
+		ti := i.(treeItem)
+		if !bytes.HasPrefix(ti.key, prefix) {
+			return false
+		}
 
-			if lt != TextType || rt != TextType {
-				return nil, "", 0, ErrInvalidOperands
-			}
+		indexes = append(indexes, ti.index)
+		return true
+	})
 
-			return literalToMemoryCell(&Token{Kind: StringKind, Value: *l.AsText() + *r.AsText()}), "?column?", TextType, nil
-		case PlusSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", IntType, nil
-			}
+	return indexes
+}
 
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
-			}
+// rowIndexesFromList looks up each value of an IN list
+func (i *index) rowIndexesFromList(list []*Expression) ([]uint, bool) {
+	seen := map[string]bool{}
+	indexes := []uint{}
+	for _, item := range list {
+		value, ok := i.lookupValue(*item, 0)
+		if !ok {
+			return nil, false
+		}
 
-			iValue := int(*l.AsInt() + *r.AsInt())
-			return literalToMemoryCell(&Token{Kind: NumericKind, Value: strconv.Itoa(iValue)}), "?column?", IntType, nil
-		case LtSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+		// NULL is never equal to an indexed value
+		if value == nil {
+			continue
+		}
 
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
-			}
+		// Equal values like 1.5 and 1.50 have the same key
+		key := i.key(value)
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
 
-			if *l.AsInt() < *r.AsInt() {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+		indexes = append(indexes, i.rowIndexesWithPrefix(key)...)
+	}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case LteSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+	// Keep rows in table order rather than list order
+	sort.Slice(indexes, func(a, b int) bool {
+		return indexes[a] < indexes[b]
+	})
 
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
-			}
+	return indexes, true
+}
 
-			if *l.AsInt() <= *r.AsInt() {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+// rowIndexesFromRange scans the values between low and high
+func (i *index) rowIndexesFromRange(lowExp, highExp Expression) ([]uint, bool) {
+	low, ok := i.lookupValue(lowExp, 0)
+	if !ok {
+		return nil, false
+	}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case GtSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+	high, ok := i.lookupValue(highExp, 0)
+	if !ok {
+		return nil, false
+	}
 
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
-			}
+	indexes := []uint{}
+	if low == nil || high == nil {
+		return indexes, true
+	}
 
-			if *l.AsInt() > *r.AsInt() {
-				return trueMemoryCell, "?column?", BoolType, nil
-			}
+	lowKey, highKey := i.key(low), i.key(high)
+	if bytes.Compare(lowKey, highKey) > 0 {
+		return indexes, true
+	}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case GteSymbol:
-			if len(l) == 0 || len(r) == 0 {
-				return nullMemoryCell, "?column?", BoolType, nil
-			}
+	i.tree.AscendGreaterOrEqual(treeItem{key: lowKey}, func(i llrb.Item) bool {
+		ti := i.(treeItem)
+		if bytes.Compare(ti.key, highKey) > 0 && !bytes.HasPrefix(ti.key, highKey) {
+			return false
+		}
 
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
+		indexes = append(indexes, ti.index)
+		return true
+	})
+
+	return indexes, true
+}
+
+// rowIndexesContaining returns the positions of the rows whose
+// document or array has every entry of the one exp evaluates to.
+// Having them doesn't mean a row contains it, rows must still be
+// checked.
+func (i *index) rowIndexesContaining(exp Expression) ([]uint, bool) {
+	value, ok := i.lookupValue(exp, 0)
+	if !ok {
+		return nil, false
+	}
+
+	indexes := []uint{}
+	if value == nil {
+		return indexes, true
+	}
+
+	// Empty arrays and documents made only of empty objects and
+	// arrays have no entries to look up
+	entries := i.keys([]memoryCell{value})
+	if len(entries) == 0 {
+		return nil, false
+	}
+
+	var matching map[uint]bool
+	for _, entry := range entries {
+		found := map[uint]bool{}
+		for _, rowIndex := range i.rowIndexesWithKey(entry) {
+			if matching == nil || matching[rowIndex] {
+				found[rowIndex] = true
 			}
+		}
 
-			if *l.AsInt() >= *r.AsInt() {
-				return trueMemoryCell, "?column?", BoolType, nil
+		matching = found
+	}
+
+	for rowIndex := range matching {
+		indexes = append(indexes, rowIndex)
+	}
+
+	sort.Slice(indexes, func(a, b int) bool {
+		return indexes[a] < indexes[b]
+	})
+
+	return indexes, true
+}
+
+// equalValue returns the constant exp compares indexed to for
+// equality, or nil
+func equalValue(exp Expression, indexed Expression) *Expression {
+	if exp.Kind != BinaryKind || exp.Binary.Quantifier != nil || exp.Binary.Op.Kind != SymbolKind || Symbol(exp.Binary.Op.Value) != EqSymbol {
+		return nil
+	}
+
+	be := exp.Binary
+	code := indexed.GenerateCode()
+	if be.A.GenerateCode() == code && isConstant(be.B) {
+		return &be.B
+	}
+
+	if be.B.GenerateCode() == code && isConstant(be.A) {
+		return &be.A
+	}
+
+	return nil
+}
+
+// prefixExpression combines the equalities of exps on the leading
+// expressions of the index with AND, in the order of the index. It
+// returns nil unless there are at least two.
+func (i *index) prefixExpression(exps []Expression) *Expression {
+	if i.typ != btreeIndexType {
+		return nil
+	}
+
+	var prefix *Expression
+	for p, indexed := range i.exps {
+		var eq *Expression
+		for j := range exps {
+			if equalValue(exps[j], indexed) != nil {
+				eq = &exps[j]
+				break
 			}
+		}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		default:
-			// TODO
+		if eq == nil {
 			break
 		}
-	case KeywordKind:
-		switch Keyword(bexp.Op.Value) {
-		case AndKeyword:
-			res := falseMemoryCell
-			if lt != BoolType || rt != BoolType {
-				return nil, "", 0, ErrInvalidOperands
+
+		if p == 0 {
+			prefix = eq
+			continue
+		}
+
+		prefix = &Expression{
+			Kind: BinaryKind,
+			Binary: &BinaryExpression{
+				A:  *prefix,
+				B:  *eq,
+				Op: Token{Kind: KeywordKind, Value: string(AndKeyword)},
+			},
+		}
+	}
+
+	if prefix == nil || prefix.Kind != BinaryKind || Keyword(prefix.Binary.Op.Value) != AndKeyword {
+		return nil
+	}
+
+	return prefix
+}
+
+// prefixValues returns the constants the leading expressions of the
+// index are compared to when exp was built by prefixExpression, or
+// nil
+func (i *index) prefixValues(exp Expression) []*Expression {
+	if i.typ != btreeIndexType || len(i.exps) < 2 {
+		return nil
+	}
+
+	eqs := []Expression{}
+	for exp.Kind == BinaryKind && exp.Binary.Op.Kind == KeywordKind && Keyword(exp.Binary.Op.Value) == AndKeyword {
+		eqs = append([]Expression{exp.Binary.B}, eqs...)
+		exp = exp.Binary.A
+	}
+	eqs = append([]Expression{exp}, eqs...)
+
+	if len(eqs) < 2 || len(eqs) > len(i.exps) {
+		return nil
+	}
+
+	values := []*Expression{}
+	for p, eq := range eqs {
+		value := equalValue(eq, i.exps[p])
+		if value == nil {
+			return nil
+		}
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// rowIndexesFromPrefix returns the positions of the rows whose
+// leading indexed values equal values
+func (i *index) rowIndexesFromPrefix(values []*Expression) ([]uint, bool) {
+	prefix := []byte{}
+	for p, valueExp := range values {
+		value, ok := i.lookupValue(*valueExp, p)
+		if !ok {
+			return nil, false
+		}
+
+		// NULL is never equal to an indexed value
+		if value == nil {
+			return []uint{}, true
+		}
+
+		prefix = appendKey(prefix, value, i.valueTypes[p])
+	}
+
+	return i.rowIndexesWithPrefix(prefix), true
+}
+
+// rowIndexesFromSubset returns the positions of the rows whose
+// indexed value satisfies exp. It returns false if the index can't
+// be used for exp.
+func (i *index) rowIndexesFromSubset(exp Expression) ([]uint, bool) {
+	if !i.applicable(exp) {
+		return nil, false
+	}
+
+	if i.typ == ginIndexType {
+		return i.rowIndexesContaining(*i.containedValue(exp))
+	}
+
+	switch exp.Kind {
+	case InKind:
+		return i.rowIndexesFromList(*exp.In.List)
+	case BetweenKind:
+		return i.rowIndexesFromRange(exp.Between.Low, exp.Between.High)
+	}
+
+	if prefixValues := i.prefixValues(exp); prefixValues != nil {
+		return i.rowIndexesFromPrefix(prefixValues)
+	}
+
+	valueExp := i.applicableValue(exp)
+	if valueExp == nil {
+		return nil, false
+	}
+
+	value, ok := i.lookupValue(*valueExp, 0)
+	if !ok {
+		return nil, false
+	}
+
+	indexes := []uint{}
+
+	// Comparisons with NULL are never true
+	if value == nil {
+		return indexes, true
+	}
+
+	// With several indexed expressions, the keys of the rows with
+	// value start with key and sort before key followed by 0xff,
+	// which no value tag is
+	key := i.key(value)
+	tiKey := treeItem{key: key}
+	tiMaxKey := treeItem{key: key, index: maxRowIndex}
+	tiPrefixEnd := treeItem{key: append(append([]byte{}, key...), 0xff)}
+
+	if Keyword(exp.Binary.Op.Value) == LikeKeyword {
+		// Rows sharing the prefix are only candidates, the full
+		// pattern is still checked against each of them
+		text := memoryCell(likePrefix(*value.AsText()))
+		prefix := appendTextKey([]byte{keyValueTag}, text, false)
+		return i.rowIndexesWithPrefix(prefix), true
+	}
+
+	switch i.indexedOp(exp.Binary) {
+	case EqSymbol:
+		indexes = i.rowIndexesEqual(value)
+	case NeqSymbol:
+		if i.tree.Len() == 0 {
+			break
+		}
+
+		i.tree.AscendGreaterOrEqual(i.tree.Min(), func(i llrb.Item) bool {
+			ti := i.(treeItem)
+			if isNullKey(ti.key) {
+				return false
 			}
 
-			if len(l) == 0 || len(r) == 0 {
-				res = nullMemoryCell
-			} else if *l.AsBool() && *r.AsBool() {
-				res = trueMemoryCell
+			if !bytes.HasPrefix(ti.key, key) {
+				indexes = append(indexes, ti.index)
 			}
 
-			return res, "?column?", BoolType, nil
-		case OrKeyword:
-			res := falseMemoryCell
-			if lt != BoolType || rt != BoolType {
-				return nil, "", 0, ErrInvalidOperands
+			return true
+		})
+	case LtSymbol:
+		i.tree.DescendLessOrEqual(tiMaxKey, func(i llrb.Item) bool {
+			ti := i.(treeItem)
+			if bytes.Compare(ti.key, key) < 0 {
+				indexes = append(indexes, ti.index)
 			}
 
-			if len(l) == 0 || len(r) == 0 {
-				res = nullMemoryCell
-			} else if *l.AsBool() || *r.AsBool() {
-				res = trueMemoryCell
+			return true
+		})
+	case LteSymbol:
+		i.tree.DescendLessOrEqual(tiPrefixEnd, func(i llrb.Item) bool {
+			ti := i.(treeItem)
+			if bytes.Compare(ti.key, key) <= 0 || bytes.HasPrefix(ti.key, key) {
+				indexes = append(indexes, ti.index)
 			}
 
-			return res, "?column?", BoolType, nil
-		default:
-			// TODO
-			break
+			return true
+		})
+	case GtSymbol:
+		i.tree.AscendGreaterOrEqual(tiKey, func(i llrb.Item) bool {
+			ti := i.(treeItem)
+			if isNullKey(ti.key) {
+				return false
+			}
+
+			if bytes.Compare(ti.key, key) > 0 && !bytes.HasPrefix(ti.key, key) {
+				indexes = append(indexes, ti.index)
+			}
+
+			return true
+		})
+	case GteSymbol:
+		i.tree.AscendGreaterOrEqual(tiKey, func(i llrb.Item) bool {
+			ti := i.(treeItem)
+			if isNullKey(ti.key) {
+				return false
+			}
+
+			if bytes.Compare(ti.key, key) >= 0 {
+				indexes = append(indexes, ti.index)
+			}
+
+			return true
+		})
+	}
+
+	return indexes, true
+}
+
+func (i *index) newTableFromSubset(t *table, exp Expression) *table {
+	indexes, ok := i.rowIndexesFromSubset(exp)
+	if !ok {
+		return t
+	}
+
+	// Row positions in the subset no longer match the positions
+	// stored in the indexes, so the indexes are not carried over
+	newT := createTable()
+	newT.name = t.name
+	newT.columns = t.columns
+	newT.columnTypes = t.columnTypes
+	newT.columnTables = t.columnTables
+	newT.rows = [][]memoryCell{}
+
+	for _, index := range indexes {
+		newT.rows = append(newT.rows, t.rows[index])
+	}
+
+	return newT
+}
+
+type table struct {
+	name        string
+	columns     []string
+	columnTypes []ColumnType
+	rows        [][]memoryCell
+	indexes     []*index
+
+	// columnPrecisions holds the precision of NUMERIC(p, s)
+	// columns, with nil for other columns. It is nil for tables
+	// that aren't stored.
+	columnPrecisions []*numericPrecision
+
+	// columnNotNull and columnDefaults hold the NOT NULL and
+	// DEFAULT constraints of each column, and checks and
+	// foreignKeys the CHECK and FOREIGN KEY constraints of the
+	// table. They are nil for tables that aren't stored.
+	columnNotNull  []bool
+	columnDefaults []*Expression
+	checks         []*Expression
+	foreignKeys    []*foreignKey
+
+	// columnTables holds the table or alias each column came from
+	// when columns of several tables are joined. It is nil when all
+	// columns belong to this table.
+	columnTables []string
+
+	// expColumns maps the generated code of whole expressions, such
+	// as aggregates computed while grouping, to the column holding
+	// their value
+	expColumns map[string]int
+
+	// groupedFrom is the table a grouped table was built from
+	groupedFrom *table
+
+	scope *queryScope
+}
+
+// queryScope holds what expressions can use beyond the columns of
+// the table they are evaluated on
+type queryScope struct {
+	// backend runs subqueries
+	backend *MemoryBackend
+
+	// outer is the table and row of the enclosing query while
+	// running a subquery. Columns missing from the subquery's
+	// tables are looked up there.
+	outer         *table
+	outerRowIndex uint
+
+	// ctes holds the rows of the common table expressions that
+	// FROM clauses can read from, by name
+	ctes map[string]*table
+}
+
+func createTable() *table {
+	return &table{
+		name:        "?tmp?",
+		columns:     nil,
+		columnTypes: nil,
+		rows:        nil,
+		indexes:     []*index{},
+	}
+}
+
+func (t *table) columnTable(i int) string {
+	if t.columnTables == nil {
+		return t.name
+	}
+
+	return t.columnTables[i]
+}
+
+// columnIndex finds a column by name, restricted to the columns of
+// tableName unless it is empty. It fails if the name matches more
+// than one column.
+func (t *table) columnIndex(tableName, column string) (int, error) {
+	found := -1
+	for i, tableCol := range t.columns {
+		if tableCol != column {
+			continue
+		}
+
+		if tableName != "" && t.columnTable(i) != tableName {
+			continue
+		}
+
+		if found != -1 {
+			return -1, ErrAmbiguousColumn
 		}
+
+		found = i
 	}
 
-	return nil, "", 0, ErrInvalidCell
+	if found == -1 {
+		if t.groupedFrom != nil {
+			if _, err := t.groupedFrom.columnIndex(tableName, column); err == nil {
+				return -1, ErrColumnNotGrouped
+			}
+		}
+
+		return -1, ErrColumnDoesNotExist
+	}
+
+	return found, nil
 }
 
-func (t *table) evaluateCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
-	switch exp.Kind {
-	case LiteralKind:
-		return t.evaluateLiteralCell(rowIndex, exp)
-	case BinaryKind:
-		return t.evaluateBinaryCell(rowIndex, exp)
-	default:
+func (t *table) evaluateLiteralCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != LiteralKind {
 		return nil, "", 0, ErrInvalidCell
 	}
+
+	lit := exp.Literal
+	if lit.Kind == IdentifierKind {
+		i, err := t.columnIndex("", lit.Value)
+		if err == ErrColumnDoesNotExist && t.scope != nil && t.scope.outer != nil {
+			return t.scope.outer.evaluateCell(t.scope.outerRowIndex, exp)
+		}
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		return t.rows[rowIndex][i], t.columns[i], t.columnTypes[i], nil
+	}
+
+	columnType := IntType
+	if lit.Kind == StringKind {
+		columnType = TextType
+	} else if lit.Kind == BoolKind {
+		columnType = BoolType
+	} else if lit.Kind == NumericKind {
+		mc, err := numericLiteralToMemoryCell(lit.Value)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		return mc, "?column?", numericLiteralType(lit.Value), nil
+	}
+
+	return literalToMemoryCell(lit), "?column?", columnType, nil
 }
 
-type indexAndExpression struct {
-	i *index
-	e Expression
+func (t *table) evaluateQualifiedCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != QualifiedKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	qc := exp.Qualified
+	i, err := t.columnIndex(qc.Table.Value, qc.Column.Value)
+	if err == ErrColumnDoesNotExist && t.scope != nil && t.scope.outer != nil {
+		return t.scope.outer.evaluateCell(t.scope.outerRowIndex, exp)
+	}
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return t.rows[rowIndex][i], t.columns[i], t.columnTypes[i], nil
 }
 
-func (t *table) getApplicableIndexes(where *Expression) []indexAndExpression {
-	var linearizeExpressions func(where *Expression, exps []Expression) []Expression
-	linearizeExpressions = func(where *Expression, exps []Expression) []Expression {
-		if where == nil || where.Kind != BinaryKind {
-			return exps
-		}
+func (t *table) evaluateBinaryCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != BinaryKind {
+		return nil, "", 0, ErrInvalidCell
+	}
 
-		if where.Binary.Op.Value == string(OrKeyword) {
+	bexp := exp.Binary
+	if bexp.Quantifier != nil {
+		return t.evaluateQuantifiedCell(rowIndex, exp)
+	}
+
+	l, _, lt, err := t.evaluateCell(rowIndex, bexp.A)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	r, _, rt, err := t.evaluateCell(rowIndex, bexp.B)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	switch bexp.Op.Kind {
+	case SymbolKind:
+		switch Symbol(bexp.Op.Value) {
+		case EqSymbol, NeqSymbol, LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+			value, err := comparison(Symbol(bexp.Op.Value), l, lt, r, rt)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			return value, "?column?", BoolType, nil
+		case ConcatSymbol:
+			if lt.IsArray() || rt.IsArray() {
+				if l == nil && r == nil {
+					typ := lt
+					if !typ.IsArray() {
+						typ = rt
+					}
+
+					return nullMemoryCell, "?column?", typ, nil
+				}
+
+				value, typ, err := concatArrays(l, lt, r, rt)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				return value, "?column?", typ, nil
+			}
+
+			if l == nil || r == nil {
+				typ := TextType
+				if lt == ByteaType && rt == ByteaType {
+					typ = ByteaType
+				}
+
+				return nullMemoryCell, "?column?", typ, nil
+			}
+
+			if lt == ByteaType && rt == ByteaType {
+				return append(append(memoryCell{}, l...), r...), "?column?", ByteaType, nil
+			}
+
+			if lt != TextType || rt != TextType {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			return literalToMemoryCell(&Token{Kind: StringKind, Value: *l.AsText() + *r.AsText()}), "?column?", TextType, nil
+		case PlusSymbol, MinusSymbol, AsteriskSymbol, SlashSymbol, PercentSymbol:
+			if l == nil || r == nil {
+				typ := IntType
+				if isNumericType(lt) && isNumericType(rt) {
+					typ = commonNumericType(lt, rt)
+				} else if isDatetimeType(lt) || isDatetimeType(rt) {
+					typ = datetimeArithmeticType(lt, rt)
+				}
+
+				return nullMemoryCell, "?column?", typ, nil
+			}
+
+			if isDatetimeType(lt) || isDatetimeType(rt) {
+				value, typ, err := datetimeArithmetic(Symbol(bexp.Op.Value), l, lt, r, rt)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				return value, "?column?", typ, nil
+			}
+
+			if !isNumericType(lt) || !isNumericType(rt) {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			value, typ, err := numericArithmetic(Symbol(bexp.Op.Value), l, lt, r, rt)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			return value, "?column?", typ, nil
+		case TildeSymbol, TildeStarSymbol, NotTildeSymbol, NotTildeStarSymbol:
+			if l == nil || r == nil {
+				return nullMemoryCell, "?column?", BoolType, nil
+			}
+
+			if lt != TextType || rt != TextType {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			op := Symbol(bexp.Op.Value)
+			pattern := *r.AsText()
+			if op == TildeStarSymbol || op == NotTildeStarSymbol {
+				pattern = "(?i)" + pattern
+			}
+
+			re, err := compileRegexp(pattern)
+			if err != nil {
+				return nil, "", 0, ErrInvalidRegularExpression
+			}
+
+			negate := op == NotTildeSymbol || op == NotTildeStarSymbol
+			if re.MatchString(*l.AsText()) != negate {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			return falseMemoryCell, "?column?", BoolType, nil
+		case ArrowSymbol, ArrowTextSymbol, PathSymbol, PathTextSymbol, ContainsSymbol, ContainedBySymbol, OverlapSymbol:
+			op := Symbol(bexp.Op.Value)
+			if op == OverlapSymbol || ((op == ContainsSymbol || op == ContainedBySymbol) && (lt.IsArray() || rt.IsArray())) {
+				if l == nil || r == nil {
+					return nullMemoryCell, "?column?", BoolType, nil
+				}
+
+				value, err := arrayOperator(op, l, lt, r, rt)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				return value, "?column?", BoolType, nil
+			}
+
+			if l == nil || r == nil {
+				return nullMemoryCell, "?column?", jsonOperatorType(op, lt), nil
+			}
+
+			value, err := jsonOperator(op, l, lt, r, rt)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			return value, "?column?", jsonOperatorType(op, lt), nil
+		default:
+			// TODO
+			break
+		}
+	case KeywordKind:
+		switch Keyword(bexp.Op.Value) {
+		case AndKeyword:
+			if (l != nil && lt != BoolType) || (r != nil && rt != BoolType) {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			// False wins over NULL: NULL AND false is false
+			if (l != nil && !*l.AsBool()) || (r != nil && !*r.AsBool()) {
+				return falseMemoryCell, "?column?", BoolType, nil
+			}
+
+			if l == nil || r == nil {
+				return nullMemoryCell, "?column?", BoolType, nil
+			}
+
+			return trueMemoryCell, "?column?", BoolType, nil
+		case LikeKeyword, IlikeKeyword:
+			escape := "\\"
+			if bexp.Escape != nil {
+				e, _, et, err := t.evaluateCell(rowIndex, *bexp.Escape)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				if e == nil {
+					return nullMemoryCell, "?column?", BoolType, nil
+				}
+
+				if et != TextType {
+					return nil, "", 0, ErrInvalidOperands
+				}
+
+				escape = *e.AsText()
+			}
+
+			if l == nil || r == nil {
+				return nullMemoryCell, "?column?", BoolType, nil
+			}
+
+			if lt != TextType || rt != TextType {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			re, err := likeToRegexp(*r.AsText(), escape, Keyword(bexp.Op.Value) == IlikeKeyword)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			if re.MatchString(*l.AsText()) {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			return falseMemoryCell, "?column?", BoolType, nil
+		case OrKeyword:
+			if (l != nil && lt != BoolType) || (r != nil && rt != BoolType) {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			// True wins over NULL: NULL OR true is true
+			if (l != nil && *l.AsBool()) || (r != nil && *r.AsBool()) {
+				return trueMemoryCell, "?column?", BoolType, nil
+			}
+
+			if l == nil || r == nil {
+				return nullMemoryCell, "?column?", BoolType, nil
+			}
+
+			return falseMemoryCell, "?column?", BoolType, nil
+		default:
+			// TODO
+			break
+		}
+	}
+
+	return nil, "", 0, ErrInvalidCell
+}
+
+// comparison applies =, <>, <, <=, > or >= to two values. Values that
+// can't be ordered can still be compared with = and <> when they have
+// the same type.
+func comparison(op Symbol, l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, error) {
+	if l == nil || r == nil {
+		return nullMemoryCell, nil
+	}
+
+	c := 0
+	if orderable(lt, rt) {
+		var err error
+		c, err = compareValues(l, lt, r, rt)
+		if err != nil {
+			return nil, err
+		}
+	} else if op != EqSymbol && op != NeqSymbol {
+		return nil, ErrInvalidOperands
+	} else if lt == JSONType || rt == JSONType {
+		// Only JSONB is normalized enough to compare
+		return nil, ErrInvalidOperands
+	} else if lt != rt || !l.equals(r) {
+		c = 1
+	}
+
+	var result bool
+	switch op {
+	case EqSymbol:
+		result = c == 0
+	case NeqSymbol:
+		result = c != 0
+	case LtSymbol:
+		result = c < 0
+	case LteSymbol:
+		result = c <= 0
+	case GtSymbol:
+		result = c > 0
+	case GteSymbol:
+		result = c >= 0
+	default:
+		return nil, ErrInvalidOperands
+	}
+
+	if result {
+		return trueMemoryCell, nil
+	}
+
+	return falseMemoryCell, nil
+}
+
+// evaluateQuantifiedCell compares a value with each element of an
+// array or each row of a subquery. ANY is true when a comparison is
+// and ALL when all of them are, otherwise NULLs make the result NULL.
+func (t *table) evaluateQuantifiedCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	bexp := exp.Binary
+	l, _, lt, err := t.evaluateCell(rowIndex, bexp.A)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var elements []memoryCell
+	var elementType ColumnType
+	if bexp.B.Kind == SubqueryKind {
+		results, err := t.runSubquery(rowIndex, bexp.B.Subquery.Select)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if len(results.Columns) != 1 {
+			return nil, "", 0, ErrSubqueryColumnCount
+		}
+
+		elementType = results.Columns[0].Type
+		for _, row := range results.Rows {
+			elements = append(elements, row[0].(memoryCell))
+		}
+	} else {
+		value, _, typ, err := t.evaluateCell(rowIndex, bexp.B)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if !typ.IsArray() {
+			return nil, "", 0, ErrInvalidOperands
+		}
+
+		if value == nil {
+			return nullMemoryCell, "?column?", BoolType, nil
+		}
+
+		elements = decodeArray(value)
+		elementType = typ.ElementType()
+	}
+
+	all := Keyword(bexp.Quantifier.Value) == AllKeyword
+	unknown := false
+	for _, element := range elements {
+		result, err := comparison(Symbol(bexp.Op.Value), l, lt, element, elementType)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if result == nil {
+			unknown = true
+			continue
+		}
+
+		// ANY is decided by a match and ALL by a mismatch
+		if *result.AsBool() != all {
+			return result, "?column?", BoolType, nil
+		}
+	}
+
+	if unknown {
+		return nullMemoryCell, "?column?", BoolType, nil
+	}
+
+	if all {
+		return trueMemoryCell, "?column?", BoolType, nil
+	}
+
+	return falseMemoryCell, "?column?", BoolType, nil
+}
+
+// likePrefix returns the characters a LIKE pattern must start with
+// before its first wildcard or escape
+func likePrefix(pattern string) string {
+	end := strings.IndexAny(pattern, "%_\\")
+	if end == -1 {
+		return pattern
+	}
+
+	return pattern[:end]
+}
+
+// likeToRegexp translates a LIKE pattern, where % matches any run of
+// characters and _ any single one, into an anchored regular
+// expression. An empty escape string disables escaping.
+func likeToRegexp(pattern, escape string, caseInsensitive bool) (*regexp.Regexp, error) {
+	if utf8.RuneCountInString(escape) > 1 {
+		return nil, ErrInvalidEscape
+	}
+
+	escapeRune, _ := utf8.DecodeRuneInString(escape)
+
+	var b strings.Builder
+	b.WriteString("(?s)")
+	if caseInsensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case escape != "" && c == escapeRune:
+			escaped = true
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// A pattern can't end in the middle of an escape
+	if escaped {
+		return nil, ErrInvalidEscape
+	}
+
+	b.WriteString("$")
+	return compileRegexp(b.String())
+}
+
+// maxCachedRegexps bounds the compiled pattern cache; it is cleared
+// once it grows past this many entries.
+const maxCachedRegexps = 256
+
+var (
+	regexpsMu sync.Mutex
+	regexps   = map[string]*regexp.Regexp{}
+)
+
+// compileRegexp compiles a pattern once and reuses it for every row a
+// query matches against it.
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpsMu.Lock()
+	defer regexpsMu.Unlock()
+
+	if re, ok := regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(regexps) >= maxCachedRegexps {
+		regexps = map[string]*regexp.Regexp{}
+	}
+	regexps[pattern] = re
+	return re, nil
+}
+
+func (t *table) evaluateUnaryCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != UnaryKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	uexp := exp.Unary
+	v, _, vt, err := t.evaluateCell(rowIndex, uexp.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	switch uexp.Op.Kind {
+	case KeywordKind:
+		if Keyword(uexp.Op.Value) != NotKeyword {
+			break
+		}
+
+		if v == nil {
+			return nullMemoryCell, "?column?", BoolType, nil
+		}
+
+		if vt != BoolType {
+			return nil, "", 0, ErrInvalidOperands
+		}
+
+		if *v.AsBool() {
+			return falseMemoryCell, "?column?", BoolType, nil
+		}
+
+		return trueMemoryCell, "?column?", BoolType, nil
+	case SymbolKind:
+		if Symbol(uexp.Op.Value) != MinusSymbol {
+			break
+		}
+
+		if v == nil {
+			if !isNumericType(vt) && vt != IntervalType {
+				vt = IntType
+			}
+
+			return nullMemoryCell, "?column?", vt, nil
+		}
+
+		if vt == IntervalType {
+			i, err := negateInterval(cellInterval(v))
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			return intervalToMemoryCell(i), "?column?", vt, nil
+		}
+
+		if !isNumericType(vt) {
+			return nil, "", 0, ErrInvalidOperands
+		}
+
+		zero, err := castNumber(literalToMemoryCell(&Token{Kind: NumericKind, Value: "0"}), IntType, vt)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		value, _, err := numericArithmetic(MinusSymbol, zero, vt, v, vt)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		return value, "?column?", vt, nil
+	}
+
+	return nil, "", 0, ErrInvalidCell
+}
+
+func (t *table) evaluateCallCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != CallKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	// Aggregates are computed while grouping, so reaching one here
+	// means it is somewhere it can't be used, like WHERE
+	if isAggregate(exp) {
+		return nil, "", 0, ErrAggregateNotAllowed
+	}
+
+	call := exp.Call
+	name := call.Name.Value
+	if call.Asterisk || call.Distinct {
+		return nil, "", 0, ErrInvalidArguments
+	}
+
+	args := []Cell{}
+	types := []ColumnType{}
+	untyped := []bool{}
+	null := false
+	for _, arg := range *call.Args {
+		value, _, valueType, err := t.evaluateCell(rowIndex, *arg)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		null = null || value == nil
+		args = append(args, value)
+		types = append(types, valueType)
+		untyped = append(untyped, arg.Kind == LiteralKind && arg.Literal.Kind == NullKind)
+	}
+
+	f, err := lookupFunction(name, types, untyped)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if null {
+		return nullMemoryCell, name, f.signature.Returns, nil
+	}
+
+	for i, arg := range args {
+		if expected := f.signature.argType(i); types[i] != expected {
+			args[i], err = castNumber(arg.(memoryCell), types[i], expected)
+			if err != nil {
+				return nil, "", 0, err
+			}
+		}
+	}
+
+	result, err := f.impl(args)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	value, err := valueToMemoryCell(result, f.signature.Returns)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return value, name, f.signature.Returns, nil
+}
+
+// runSubquery runs slct for the row at rowIndex, which is where
+// columns missing from the tables of slct are looked up
+func (t *table) runSubquery(rowIndex uint, slct *SelectStatement) (*Results, error) {
+	if t.scope == nil {
+		return nil, ErrSubqueryNotAllowed
+	}
+
+	mb := t.scope.backend
+	return mb.selectScoped(slct, &queryScope{
+		backend:       mb,
+		outer:         t,
+		outerRowIndex: rowIndex,
+		ctes:          t.scope.ctes,
+	})
+}
+
+func (t *table) evaluateSubqueryCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != SubqueryKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	results, err := t.runSubquery(rowIndex, exp.Subquery.Select)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if exp.Subquery.Exists {
+		if len(results.Rows) > 0 {
+			return trueMemoryCell, "exists", BoolType, nil
+		}
+
+		return falseMemoryCell, "exists", BoolType, nil
+	}
+
+	if len(results.Columns) != 1 {
+		return nil, "", 0, ErrSubqueryColumnCount
+	}
+
+	column := results.Columns[0]
+	switch len(results.Rows) {
+	case 0:
+		return nullMemoryCell, column.Name, column.Type, nil
+	case 1:
+		return results.Rows[0][0].(memoryCell), column.Name, column.Type, nil
+	default:
+		return nil, "", 0, ErrSubqueryTooManyRows
+	}
+}
+
+// evaluateInCell compares a value against each row of a subquery.
+// As with =, NULLs make the result NULL unless a match is found.
+func (t *table) evaluateInCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != InKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	ie := exp.In
+	value, _, valueType, err := t.evaluateCell(rowIndex, ie.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var cells []memoryCell
+	var cellTypes []ColumnType
+	if ie.List != nil {
+		for _, item := range *ie.List {
+			cell, _, cellType, err := t.evaluateCell(rowIndex, *item)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			cells = append(cells, cell)
+			cellTypes = append(cellTypes, cellType)
+		}
+	} else {
+		results, err := t.runSubquery(rowIndex, ie.Subquery)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if len(results.Columns) != 1 {
+			return nil, "", 0, ErrSubqueryColumnCount
+		}
+
+		columnType := results.Columns[0].Type
+		if columnType != valueType && !orderable(columnType, valueType) {
+			return nil, "", 0, ErrInvalidOperands
+		}
+
+		for _, row := range results.Rows {
+			cells = append(cells, row[0].(memoryCell))
+			cellTypes = append(cellTypes, columnType)
+		}
+	}
+
+	found := false
+	unknown := false
+	for i, cell := range cells {
+		if value == nil || cell == nil {
+			unknown = true
+			continue
+		}
+
+		c, err := compareValues(value, valueType, cell, cellTypes[i])
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if c == 0 {
+			found = true
+			break
+		}
+	}
+
+	if !found && unknown {
+		return nullMemoryCell, "?column?", BoolType, nil
+	}
+
+	if found != ie.Not {
+		return trueMemoryCell, "?column?", BoolType, nil
+	}
+
+	return falseMemoryCell, "?column?", BoolType, nil
+}
+
+func (t *table) evaluateBetweenCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != BetweenKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	be := exp.Between
+	cells := []memoryCell{}
+	types := []ColumnType{}
+	for _, e := range []Expression{be.Exp, be.Low, be.High} {
+		cell, _, cellType, err := t.evaluateCell(rowIndex, e)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if cell != nil && cellType != TextType && !isNumericType(cellType) && !isDatetimeType(cellType) && !isBinaryType(cellType) {
+			return nil, "", 0, ErrInvalidOperands
+		}
+
+		cells = append(cells, cell)
+		types = append(types, cellType)
+	}
+
+	// Every operand must be comparable to the others, even when a
+	// NULL means some comparisons aren't needed
+	for i := range cells {
+		for j := 0; j < i; j++ {
+			if cells[i] == nil || cells[j] == nil {
+				continue
+			}
+
+			if _, err := compareValues(cells[j], types[j], cells[i], types[i]); err != nil {
+				return nil, "", 0, err
+			}
+		}
+	}
+
+	value, low, high := cells[0], cells[1], cells[2]
+
+	// Like value >= low AND value <= high: a NULL only matters if
+	// the other bound doesn't already rule the value out
+	outside := false
+	unknown := value == nil
+	for i, bound := range []memoryCell{low, high} {
+		if bound == nil || value == nil {
+			unknown = true
+			continue
+		}
+
+		c, _ := compareValues(value, types[0], bound, types[i+1])
+		if (i == 0 && c < 0) || (i == 1 && c > 0) {
+			outside = true
+		}
+	}
+
+	if !outside && unknown {
+		return nullMemoryCell, "?column?", BoolType, nil
+	}
+
+	if outside == be.Not {
+		return trueMemoryCell, "?column?", BoolType, nil
+	}
+
+	return falseMemoryCell, "?column?", BoolType, nil
+}
+
+// commonType finds the type shared by expressions that may each
+// provide the result, so that it doesn't depend on which one does.
+// Types are found on a row of NULLs, and expressions that fail there
+// or are a bare NULL don't constrain the type. Numbers of different
+// types share the highest ranked of them.
+func (t *table) commonType(exps []Expression) (ColumnType, error) {
+	probe := t.withNullRow()
+
+	typ := IntType
+	typed := false
+	for _, exp := range exps {
+		if exp.Kind == LiteralKind && exp.Literal.Kind == NullKind {
+			continue
+		}
+
+		_, _, expType, err := probe.evaluateCell(0, exp)
+		if err != nil {
+			continue
+		}
+
+		if typed && expType != typ {
+			if !isNumericType(expType) || !isNumericType(typ) {
+				return 0, ErrInvalidOperands
+			}
+
+			expType = commonNumericType(typ, expType)
+		}
+
+		typ = expType
+		typed = true
+	}
+
+	return typ, nil
+}
+
+// evaluateAs evaluates exp and converts a number to typ, the type
+// found by commonType
+func (t *table) evaluateAs(rowIndex uint, exp Expression, typ ColumnType) (memoryCell, error) {
+	value, _, valueType, err := t.evaluateCell(rowIndex, exp)
+	if err != nil || value == nil || valueType == typ {
+		return value, err
+	}
+
+	return castNumber(value, valueType, typ)
+}
+
+func (t *table) evaluateCaseCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != CaseKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	ce := exp.Case
+	results := []Expression{}
+	for _, when := range *ce.Whens {
+		results = append(results, when.Then)
+	}
+	if ce.Else != nil {
+		results = append(results, *ce.Else)
+	}
+
+	typ, err := t.commonType(results)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var operand memoryCell
+	var operandType ColumnType
+	if ce.Operand != nil {
+		operand, _, operandType, err = t.evaluateCell(rowIndex, *ce.Operand)
+		if err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	for _, when := range *ce.Whens {
+		value, _, valueType, err := t.evaluateCell(rowIndex, when.When)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		// A NULL never matches, in either form of CASE
+		matched := false
+		if ce.Operand != nil {
+			if operand != nil && value != nil {
+				c, err := compareValues(operand, operandType, value, valueType)
+				if err != nil {
+					return nil, "", 0, err
+				}
+
+				matched = c == 0
+			}
+		} else if value != nil {
+			if valueType != BoolType {
+				return nil, "", 0, ErrInvalidOperands
+			}
+
+			matched = *value.AsBool()
+		}
+
+		if matched {
+			result, err := t.evaluateAs(rowIndex, when.Then, typ)
+			return result, "case", typ, err
+		}
+	}
+
+	if ce.Else == nil {
+		return nullMemoryCell, "case", typ, nil
+	}
+
+	result, err := t.evaluateAs(rowIndex, *ce.Else, typ)
+	return result, "case", typ, err
+}
+
+func (t *table) evaluateConditionalCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != ConditionalKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	ce := exp.Conditional
+	args := []Expression{}
+	for _, arg := range *ce.Args {
+		args = append(args, *arg)
+	}
+
+	name := ce.Function.Value
+	switch Keyword(name) {
+	case CoalesceKeyword:
+		typ, err := t.commonType(args)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		// Arguments after the first non-NULL one aren't evaluated
+		for _, arg := range args {
+			value, err := t.evaluateAs(rowIndex, arg, typ)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			if value != nil {
+				return value, name, typ, nil
+			}
+		}
+
+		return nullMemoryCell, name, typ, nil
+	case NullifKeyword:
+		value, _, valueType, err := t.evaluateCell(rowIndex, args[0])
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		other, _, otherType, err := t.evaluateCell(rowIndex, args[1])
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		if value != nil && other != nil {
+			c, err := compareValues(value, valueType, other, otherType)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			if c == 0 {
+				return nullMemoryCell, name, valueType, nil
+			}
+		}
+
+		return value, name, valueType, nil
+	case GreatestKeyword, LeastKeyword:
+		typ, err := t.commonType(args)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		// NULLs are ignored, the result is only NULL if all are
+		var result memoryCell
+		for _, arg := range args {
+			value, err := t.evaluateAs(rowIndex, arg, typ)
+			if err != nil {
+				return nil, "", 0, err
+			}
+
+			if value == nil {
+				continue
+			}
+
+			c := 0
+			if result != nil {
+				c = compareCells(value, result, typ)
+			}
+
+			if result == nil || (Keyword(name) == GreatestKeyword && c > 0) || (Keyword(name) == LeastKeyword && c < 0) {
+				result = value
+			}
+		}
+
+		return result, name, typ, nil
+	}
+
+	return nil, "", 0, ErrInvalidCell
+}
+
+// datatypeColumnType finds the column type named in a column
+// definition or cast, along with the precision given to NUMERIC. With
+// array set it is the type of an array of those.
+func datatypeColumnType(datatype Token, modifiers *[]*Token, array bool) (ColumnType, *numericPrecision, error) {
+	var typ ColumnType
+	switch Keyword(datatype.Value) {
+	case IntKeyword:
+		typ = IntType
+	case TextKeyword:
+		typ = TextType
+	case BoolKeyword:
+		typ = BoolType
+	case SmallintKeyword:
+		typ = SmallIntType
+	case BigintKeyword:
+		typ = BigIntType
+	case RealKeyword:
+		typ = RealType
+	case DoubleKeyword:
+		typ = DoubleType
+	case NumericKeyword, DecimalKeyword:
+		typ = NumericType
+	case DateKeyword:
+		typ = DateType
+	case TimeKeyword:
+		typ = TimeType
+	case TimestampKeyword:
+		typ = TimestampType
+	case IntervalKeyword:
+		typ = IntervalType
+	case ByteaKeyword:
+		typ = ByteaType
+	case UuidKeyword:
+		typ = UUIDType
+	case JsonKeyword:
+		typ = JSONType
+	case JsonbKeyword:
+		typ = JSONBType
+	default:
+		return 0, nil, ErrInvalidDatatype
+	}
+
+	if array {
+		typ |= ArrayType
+	}
+
+	if modifiers == nil {
+		return typ, nil, nil
+	}
+
+	if typ.ElementType() != NumericType || len(*modifiers) == 0 || len(*modifiers) > 2 {
+		return 0, nil, ErrInvalidDatatype
+	}
+
+	values := []int{}
+	for _, modifier := range *modifiers {
+		i, err := strconv.Atoi(modifier.Value)
+		if err != nil {
+			return 0, nil, ErrInvalidDatatype
+		}
+
+		values = append(values, i)
+	}
+
+	np := numericPrecision{precision: values[0]}
+	if len(values) > 1 {
+		np.scale = values[1]
+	}
+
+	if np.precision < 1 || np.precision > maxNumericPrecision || np.scale < 0 || np.scale > np.precision {
+		return 0, nil, ErrInvalidDatatype
+	}
+
+	return typ, &np, nil
+}
+
+// castCell converts a non-NULL value between column types
+func castCell(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	if from == to {
+		return value, nil
+	}
+
+	switch {
+	case from.IsArray() || to.IsArray():
+		return castArray(value, from, to)
+	case isNumericType(from) && isNumericType(to):
+		return castNumber(value, from, to)
+	case isNumericType(from) && to == TextType:
+		return memoryCell(numberToText(value, from)), nil
+	case from == TextType && isNumericType(to):
+		return textToNumber(*value.AsText(), to)
+	case isDatetimeType(from) || isDatetimeType(to):
+		return castDatetime(value, from, to)
+	case isBinaryType(from) || isBinaryType(to):
+		return castBinary(value, from, to)
+	case isJSONType(from) || isJSONType(to):
+		return castJSON(value, from, to)
+	}
+
+	switch to {
+	case TextType:
+		if from == BoolType {
+			return memoryCell(strconv.FormatBool(*value.AsBool())), nil
+		}
+	case IntType:
+		if from == BoolType {
+			if *value.AsBool() {
+				return intToMemoryCell(1)
+			}
+
+			return intToMemoryCell(0)
+		}
+	case BoolType:
+		switch from {
+		case TextType:
+			switch strings.ToLower(strings.TrimSpace(*value.AsText())) {
+			case "t", "true", "y", "yes", "on", "1":
+				return trueMemoryCell, nil
+			case "f", "false", "n", "no", "off", "0":
+				return falseMemoryCell, nil
+			}
+
+			return nil, ErrInvalidCast
+		case IntType:
+			if *value.AsInt() != 0 {
+				return trueMemoryCell, nil
+			}
+
+			return falseMemoryCell, nil
+		}
+	}
+
+	return nil, ErrInvalidCast
+}
+
+func (t *table) evaluateCastCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != CastKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	ce := exp.Cast
+	to, precision, err := datatypeColumnType(ce.Datatype, ce.Modifiers, ce.Array)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	value, name, from, err := t.evaluateCell(rowIndex, ce.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if value == nil {
+		return nullMemoryCell, name, to, nil
+	}
+
+	value, err = castCell(value, from, to)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	value, err = applyPrecision(value, to, precision)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return value, name, to, nil
+}
+
+// applyPrecision rounds a NUMERIC value, or each element of a NUMERIC
+// array, to the precision of its column or cast if it has one
+func applyPrecision(value memoryCell, typ ColumnType, precision *numericPrecision) (memoryCell, error) {
+	if precision == nil || value == nil {
+		return value, nil
+	}
+
+	if typ.IsArray() {
+		return mapArray(value, precision.apply)
+	}
+
+	return precision.apply(value)
+}
+
+// evaluateArrayCell builds an array from a list of values, which are
+// converted to their common type
+func (t *table) evaluateArrayCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != ArrayKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	items := []Expression{}
+	for _, item := range *exp.Array.Items {
+		items = append(items, *item)
+	}
+
+	typ, err := t.commonType(items)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	// Arrays only have one dimension
+	if typ.IsArray() {
+		return nil, "", 0, ErrInvalidOperands
+	}
+
+	elements := []memoryCell{}
+	for _, item := range items {
+		element, err := t.evaluateAs(rowIndex, item, typ)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return encodeArray(elements), "array", ArrayType | typ, nil
+}
+
+// evaluateSubscriptCell finds an element of an array by its position,
+// which starts at 1. Positions outside of the array give NULL.
+func (t *table) evaluateSubscriptCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != SubscriptKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	value, name, typ, err := t.evaluateCell(rowIndex, exp.Subscript.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	index, _, indexType, err := t.evaluateCell(rowIndex, exp.Subscript.Index)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if !typ.IsArray() || (index != nil && !isIntegerType(indexType)) {
+		return nil, "", 0, ErrInvalidOperands
+	}
+
+	if value == nil || index == nil {
+		return nullMemoryCell, name, typ.ElementType(), nil
+	}
+
+	elements := decodeArray(value)
+	i := cellInt64(index, indexType)
+	if i < 1 || i > int64(len(elements)) {
+		return nullMemoryCell, name, typ.ElementType(), nil
+	}
+
+	return elements[i-1], name, typ.ElementType(), nil
+}
+
+func (t *table) evaluateIsCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if exp.Kind != IsKind {
+		return nil, "", 0, ErrInvalidCell
+	}
+
+	iexp := exp.Is
+	l, _, lt, err := t.evaluateCell(rowIndex, iexp.Exp)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	if iexp.DistinctFrom == nil {
+		if (l == nil) != iexp.Not {
+			return trueMemoryCell, "?column?", BoolType, nil
+		}
+
+		return falseMemoryCell, "?column?", BoolType, nil
+	}
+
+	r, _, rt, err := t.evaluateCell(rowIndex, *iexp.DistinctFrom)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	// NULLs are not distinct from each other, values of types that
+	// can't be compared always are
+	distinct := true
+	if l == nil || r == nil {
+		distinct = l != nil || r != nil
+	} else if c, err := compareValues(l, lt, r, rt); err == nil {
+		distinct = c != 0
+	}
+
+	if distinct != iexp.Not {
+		return trueMemoryCell, "?column?", BoolType, nil
+	}
+
+	return falseMemoryCell, "?column?", BoolType, nil
+}
+
+func (t *table) evaluateCell(rowIndex uint, exp Expression) (memoryCell, string, ColumnType, error) {
+	if t.expColumns != nil {
+		if i, ok := t.expColumns[exp.GenerateCode()]; ok {
+			return t.rows[rowIndex][i], t.columns[i], t.columnTypes[i], nil
+		}
+	}
+
+	switch exp.Kind {
+	case LiteralKind:
+		return t.evaluateLiteralCell(rowIndex, exp)
+	case BinaryKind:
+		return t.evaluateBinaryCell(rowIndex, exp)
+	case QualifiedKind:
+		return t.evaluateQualifiedCell(rowIndex, exp)
+	case CallKind:
+		return t.evaluateCallCell(rowIndex, exp)
+	case SubqueryKind:
+		return t.evaluateSubqueryCell(rowIndex, exp)
+	case InKind:
+		return t.evaluateInCell(rowIndex, exp)
+	case UnaryKind:
+		return t.evaluateUnaryCell(rowIndex, exp)
+	case IsKind:
+		return t.evaluateIsCell(rowIndex, exp)
+	case BetweenKind:
+		return t.evaluateBetweenCell(rowIndex, exp)
+	case CaseKind:
+		return t.evaluateCaseCell(rowIndex, exp)
+	case ConditionalKind:
+		return t.evaluateConditionalCell(rowIndex, exp)
+	case CastKind:
+		return t.evaluateCastCell(rowIndex, exp)
+	case ArrayKind:
+		return t.evaluateArrayCell(rowIndex, exp)
+	case SubscriptKind:
+		return t.evaluateSubscriptCell(rowIndex, exp)
+	default:
+		return nil, "", 0, ErrInvalidCell
+	}
+}
+
+type indexAndExpression struct {
+	i *index
+	e Expression
+}
+
+func (t *table) getApplicableIndexes(where *Expression) []indexAndExpression {
+	var linearizeExpressions func(where *Expression, exps []Expression) []Expression
+	linearizeExpressions = func(where *Expression, exps []Expression) []Expression {
+		if where == nil {
+			return exps
+		}
+
+		if where.Kind == BinaryKind && where.Binary.Op.Value == string(OrKeyword) {
 			return exps
 		}
 
-		if where.Binary.Op.Value == string(AndKeyword) {
-			exps := linearizeExpressions(&where.Binary.A, exps)
-			return linearizeExpressions(&where.Binary.B, exps)
+		if where.Kind == BinaryKind && where.Binary.Op.Value == string(AndKeyword) {
+			exps := linearizeExpressions(&where.Binary.A, exps)
+			return linearizeExpressions(&where.Binary.B, exps)
+		}
+
+		return append(exps, *where)
+	}
+
+	exps := linearizeExpressions(where, []Expression{})
+
+	// Equalities on the leading expressions of an index on several
+	// are looked up together, and narrow rows down the most
+	iAndE := []indexAndExpression{}
+	for _, index := range t.indexes {
+		if prefix := index.prefixExpression(exps); prefix != nil {
+			iAndE = append(iAndE, indexAndExpression{
+				i: index,
+				e: *prefix,
+			})
+		}
+	}
+
+	for _, exp := range exps {
+		for _, index := range t.indexes {
+			if index.applicable(exp) {
+				iAndE = append(iAndE, indexAndExpression{
+					i: index,
+					e: exp,
+				})
+			}
+		}
+	}
+
+	return iAndE
+}
+
+// withNullRow returns a copy of t holding a single row of NULLs. It
+// is used to find the type of an expression when there may be no
+// rows to evaluate it on.
+func (t *table) withNullRow() *table {
+	probe := *t
+	probe.rows = [][]memoryCell{make([]memoryCell, len(t.columns))}
+	return &probe
+}
+
+// compareCells orders two non-NULL cells of the same type
+func compareCells(a, b memoryCell, typ ColumnType) int {
+	if typ == IntType {
+		ai, bi := *a.AsInt(), *b.AsInt()
+		if ai < bi {
+			return -1
+		} else if ai > bi {
+			return 1
+		}
+
+		return 0
+	}
+
+	if typ == IntervalType {
+		return compareIntervals(a, b)
+	}
+
+	if typ.IsArray() {
+		return compareArrays(a, b, typ.ElementType())
+	}
+
+	if typ == NumericType {
+		return bytes.Compare(numericValue(a), numericValue(b))
+	}
+
+	// Other types order the same as their bytes
+	return bytes.Compare(a, b)
+}
+
+var aggregateFunctions = map[string]bool{
+	"count":     true,
+	"sum":       true,
+	"avg":       true,
+	"min":       true,
+	"max":       true,
+	"array_agg": true,
+}
+
+func isAggregate(exp Expression) bool {
+	return exp.Kind == CallKind && aggregateFunctions[exp.Call.Name.Value]
+}
+
+// walkExpression calls fn on exp and every expression nested in it,
+// skipping the expressions nested in any for which fn returns false
+func walkExpression(exp Expression, fn func(Expression) bool) {
+	if !fn(exp) {
+		return
+	}
+
+	switch exp.Kind {
+	case BinaryKind:
+		walkExpression(exp.Binary.A, fn)
+		walkExpression(exp.Binary.B, fn)
+	case CallKind:
+		if exp.Call.Args != nil {
+			for _, arg := range *exp.Call.Args {
+				walkExpression(*arg, fn)
+			}
+		}
+	case InKind:
+		// Subqueries are separate queries so their aggregates are
+		// not walked
+		walkExpression(exp.In.Exp, fn)
+		if exp.In.List != nil {
+			for _, item := range *exp.In.List {
+				walkExpression(*item, fn)
+			}
+		}
+	case BetweenKind:
+		walkExpression(exp.Between.Exp, fn)
+		walkExpression(exp.Between.Low, fn)
+		walkExpression(exp.Between.High, fn)
+	case CaseKind:
+		if exp.Case.Operand != nil {
+			walkExpression(*exp.Case.Operand, fn)
+		}
+		for _, when := range *exp.Case.Whens {
+			walkExpression(when.When, fn)
+			walkExpression(when.Then, fn)
+		}
+		if exp.Case.Else != nil {
+			walkExpression(*exp.Case.Else, fn)
+		}
+	case ConditionalKind:
+		for _, arg := range *exp.Conditional.Args {
+			walkExpression(*arg, fn)
+		}
+	case CastKind:
+		walkExpression(exp.Cast.Exp, fn)
+	case ArrayKind:
+		for _, item := range *exp.Array.Items {
+			walkExpression(*item, fn)
+		}
+	case SubscriptKind:
+		walkExpression(exp.Subscript.Exp, fn)
+		walkExpression(exp.Subscript.Index, fn)
+	case UnaryKind:
+		walkExpression(exp.Unary.Exp, fn)
+	case IsKind:
+		walkExpression(exp.Is.Exp, fn)
+		if exp.Is.DistinctFrom != nil {
+			walkExpression(*exp.Is.DistinctFrom, fn)
+		}
+	}
+}
+
+// findAggregates returns every distinct aggregate call in exps
+func findAggregates(exps []*Expression) []Expression {
+	seen := map[string]bool{}
+	aggregates := []Expression{}
+	for _, exp := range exps {
+		if exp == nil {
+			continue
+		}
+
+		walkExpression(*exp, func(e Expression) bool {
+			if !isAggregate(e) {
+				return true
+			}
+
+			if code := e.GenerateCode(); !seen[code] {
+				seen[code] = true
+				aggregates = append(aggregates, e)
+			}
+
+			// Nested aggregates are caught when evaluating the
+			// arguments
+			return false
+		})
+	}
+
+	return aggregates
+}
+
+// aggregateType checks the arguments of an aggregate call and
+// returns the type of its result
+func (t *table) aggregateType(call CallExpression) (ColumnType, error) {
+	if call.Asterisk {
+		if call.Name.Value != "count" {
+			return 0, ErrInvalidArguments
+		}
+
+		return IntType, nil
+	}
+
+	if len(*call.Args) != 1 {
+		return 0, ErrInvalidArguments
+	}
+
+	_, _, argType, err := t.withNullRow().evaluateCell(0, *(*call.Args)[0])
+	if err != nil {
+		return 0, err
+	}
+
+	switch call.Name.Value {
+	case "count":
+		return IntType, nil
+	case "sum":
+		// Sums of integers get a wider type so they rarely overflow
+		switch argType {
+		case SmallIntType, IntType:
+			return BigIntType, nil
+		case BigIntType, NumericType:
+			return NumericType, nil
+		case RealType, DoubleType:
+			return argType, nil
+		}
+
+		return 0, ErrInvalidOperands
+	case "avg":
+		if isFloatType(argType) {
+			return DoubleType, nil
+		} else if isNumericType(argType) {
+			return NumericType, nil
+		}
+
+		return 0, ErrInvalidOperands
+	case "array_agg":
+		if argType.IsArray() {
+			return 0, ErrInvalidOperands
+		}
+
+		return ArrayType | argType, nil
+	default:
+		return argType, nil
+	}
+}
+
+// evaluateAggregate computes an aggregate call over the given rows
+// as a value of type typ, found by aggregateType. NULL values are
+// ignored except by array_agg, and every aggregate but count returns
+// NULL when there are no values. With DISTINCT each value is only
+// used once.
+func (t *table) evaluateAggregate(call CallExpression, typ ColumnType, rowIndexes []uint) (memoryCell, error) {
+	if call.Asterisk {
+		return literalToMemoryCell(&Token{Kind: NumericKind, Value: strconv.Itoa(len(rowIndexes))}), nil
+	}
+
+	var result memoryCell
+	var elements []memoryCell
+	count := 0
+	// Floats are summed as floats, other numbers exactly
+	sum := decimal{new(big.Int), 0}
+	floatSum := float64(0)
+	seen := map[string]bool{}
+	for _, rowIndex := range rowIndexes {
+		value, _, valueType, err := t.evaluateCell(rowIndex, *(*call.Args)[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if call.Distinct {
+			key := string(appendGroupKey(nil, value, valueType))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		if call.Name.Value == "array_agg" {
+			elements = append(elements, value)
+			continue
+		}
+
+		if value == nil {
+			continue
+		}
+
+		count++
+		switch call.Name.Value {
+		case "sum", "avg":
+			if isFloatType(valueType) {
+				floatSum += cellFloat(value, valueType)
+				break
+			}
+
+			d, err := cellDecimal(value, valueType)
+			if err != nil {
+				return nil, err
+			}
+
+			sum, err = decimalArithmetic(PlusSymbol, sum, d)
+			if err != nil {
+				return nil, err
+			}
+		case "min":
+			if result == nil || compareCells(value, result, valueType) < 0 {
+				result = value
+			}
+		case "max":
+			if result == nil || compareCells(value, result, valueType) > 0 {
+				result = value
+			}
+		}
+	}
+
+	switch call.Name.Value {
+	case "count":
+		return literalToMemoryCell(&Token{Kind: NumericKind, Value: strconv.Itoa(count)}), nil
+	case "sum":
+		if count == 0 {
+			return nullMemoryCell, nil
+		}
+
+		if isFloatType(typ) {
+			return floatToMemoryCell(floatSum, typ)
+		}
+
+		return castNumber(decimalToMemoryCell(sum), NumericType, typ)
+	case "avg":
+		if count == 0 {
+			return nullMemoryCell, nil
+		}
+
+		if isFloatType(typ) {
+			return floatToMemoryCell(floatSum/float64(count), typ)
+		}
+
+		avg, err := decimalArithmetic(SlashSymbol, sum, decimal{big.NewInt(int64(count)), 0})
+		if err != nil {
+			return nil, err
+		}
+
+		return decimalToMemoryCell(avg), nil
+	case "array_agg":
+		if elements == nil {
+			return nullMemoryCell, nil
+		}
+
+		return encodeArray(elements), nil
+	}
+
+	return result, nil
+}
+
+// appendGroupKey adds a length-prefixed value to a key identifying a
+// group, so that keys of different values never collide
+func appendGroupKey(key []byte, value memoryCell, typ ColumnType) []byte {
+	if value == nil {
+		return append(key, 0)
+	}
+
+	// NUMERIC values only differing by their scale, like 1.5 and
+	// 1.50, are equal
+	if typ.ElementType() == NumericType {
+		value = appendKey(nil, value, typ)
+	}
+
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(value)))
+	key = append(key, 1)
+	key = append(key, length...)
+	return append(key, value...)
+}
+
+// groupRows collapses the given rows of t into one row per distinct
+// value of the groupBy expressions, or into a single row if there
+// are none. The new table holds the value of each groupBy expression
+// followed by the value of each aggregate over the rows of the group.
+func (t *table) groupRows(rowIndexes []uint, groupBy []*Expression, aggregates []Expression) (*table, error) {
+	g := createTable()
+	g.rows = [][]memoryCell{}
+	g.columnTables = []string{}
+	g.expColumns = map[string]int{}
+	g.groupedFrom = t
+	g.scope = t.scope
+
+	probe := t.withNullRow()
+	for _, exp := range groupBy {
+		_, name, columnType, err := probe.evaluateCell(0, *exp)
+		if err != nil {
+			return nil, err
+		}
+
+		// Grouped columns can still be referenced by name
+		tableName := ""
+		if exp.Kind == QualifiedKind {
+			i, err := t.columnIndex(exp.Qualified.Table.Value, exp.Qualified.Column.Value)
+			if err != nil {
+				return nil, err
+			}
+			tableName = t.columnTable(i)
+		} else if exp.Kind == LiteralKind && exp.Literal.Kind == IdentifierKind {
+			i, err := t.columnIndex("", exp.Literal.Value)
+			if err != nil {
+				return nil, err
+			}
+			tableName = t.columnTable(i)
+		}
+
+		g.expColumns[exp.GenerateCode()] = len(g.columns)
+		g.columns = append(g.columns, name)
+		g.columnTypes = append(g.columnTypes, columnType)
+		g.columnTables = append(g.columnTables, tableName)
+	}
+
+	groups := map[string]int{}
+	groupRowIndexes := [][]uint{}
+	for _, rowIndex := range rowIndexes {
+		key := []byte{}
+		row := []memoryCell{}
+		for _, exp := range groupBy {
+			value, _, typ, err := t.evaluateCell(rowIndex, *exp)
+			if err != nil {
+				return nil, err
+			}
+
+			key = appendGroupKey(key, value, typ)
+			row = append(row, value)
+		}
+
+		if i, ok := groups[string(key)]; ok {
+			groupRowIndexes[i] = append(groupRowIndexes[i], rowIndex)
+			continue
+		}
+
+		groups[string(key)] = len(g.rows)
+		g.rows = append(g.rows, row)
+		groupRowIndexes = append(groupRowIndexes, []uint{rowIndex})
+	}
+
+	// Without GROUP BY there is exactly one group, even when there
+	// are no rows
+	if len(groupBy) == 0 && len(g.rows) == 0 {
+		g.rows = append(g.rows, []memoryCell{})
+		groupRowIndexes = append(groupRowIndexes, []uint{})
+	}
+
+	for _, aggregate := range aggregates {
+		columnType, err := t.aggregateType(*aggregate.Call)
+		if err != nil {
+			return nil, err
+		}
+
+		g.expColumns[aggregate.GenerateCode()] = len(g.columns)
+		g.columns = append(g.columns, aggregate.Call.Name.Value)
+		g.columnTypes = append(g.columnTypes, columnType)
+		g.columnTables = append(g.columnTables, "")
+
+		for i := range g.rows {
+			value, err := t.evaluateAggregate(*aggregate.Call, columnType, groupRowIndexes[i])
+			if err != nil {
+				return nil, err
+			}
+
+			g.rows[i] = append(g.rows[i], value)
+		}
+	}
+
+	return g, nil
+}
+
+// orderedIndex finds an index whose tree is already in the order of
+// item, so rows can be read from it without sorting
+func (t *table) orderedIndex(item OrderByItem) *index {
+	for _, index := range t.indexes {
+		if index.typ != btreeIndexType || len(index.exps) != 1 || index.exps[0].GenerateCode() != item.Exp.GenerateCode() {
+			continue
+		}
+
+		// NULLs are last in the tree, so first when it's read
+		// backwards
+		if item.NullsFirst() != item.Desc {
+			continue
+		}
+
+		return index
+	}
+
+	return nil
+}
+
+// orderRows sorts rowIndexes by the ORDER BY items. When the only
+// item is the expression of an index the rows are read in the
+// order of the index instead.
+func (t *table) orderRows(rowIndexes []uint, orderBy []OrderByItem) ([]uint, error) {
+	if len(orderBy) == 1 {
+		if index := t.orderedIndex(orderBy[0]); index != nil {
+			matching := map[uint]bool{}
+			for _, rowIndex := range rowIndexes {
+				matching[rowIndex] = true
+			}
+
+			// Every row is in the tree, NULLs included
+			ordered := []uint{}
+			collect := func(i llrb.Item) bool {
+				if ti := i.(treeItem); matching[ti.index] {
+					ordered = append(ordered, ti.index)
+				}
+
+				return true
+			}
+
+			if index.tree.Len() == 0 {
+				return ordered, nil
+			}
+
+			if orderBy[0].Desc {
+				index.tree.DescendLessOrEqual(index.tree.Max(), collect)
+			} else {
+				index.tree.AscendGreaterOrEqual(index.tree.Min(), collect)
+			}
+
+			return ordered, nil
+		}
+	}
+
+	keys := make([][]memoryCell, len(rowIndexes))
+	keyTypes := make([]ColumnType, len(orderBy))
+	for i, rowIndex := range rowIndexes {
+		for j, item := range orderBy {
+			value, _, columnType, err := t.evaluateCell(rowIndex, *item.Exp)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[i] = append(keys[i], value)
+			keyTypes[j] = columnType
+		}
+	}
+
+	positions := make([]int, len(rowIndexes))
+	for i := range positions {
+		positions[i] = i
+	}
+
+	// Stable so that rows with equal keys keep their order
+	sort.SliceStable(positions, func(a, b int) bool {
+		for j, item := range orderBy {
+			x, y := keys[positions[a]][j], keys[positions[b]][j]
+			if x == nil && y == nil {
+				continue
+			} else if x == nil {
+				return item.NullsFirst()
+			} else if y == nil {
+				return !item.NullsFirst()
+			}
+
+			c := compareCells(x, y, keyTypes[j])
+			if c == 0 {
+				continue
+			}
+
+			if item.Desc {
+				return c > 0
+			}
+
+			return c < 0
+		}
+
+		return false
+	})
+
+	ordered := make([]uint, len(rowIndexes))
+	for i, position := range positions {
+		ordered[i] = rowIndexes[position]
+	}
+
+	return ordered, nil
+}
+
+// distinctRows keeps the first of the rows with equal values of exps
+func (t *table) distinctRows(rowIndexes []uint, exps []*Expression) ([]uint, error) {
+	seen := map[string]bool{}
+	distinct := []uint{}
+	for _, rowIndex := range rowIndexes {
+		// Group keys are equal exactly when every cell is equal
+		key := []byte{}
+		for _, exp := range exps {
+			value, _, typ, err := t.evaluateCell(rowIndex, *exp)
+			if err != nil {
+				return nil, err
+			}
+
+			key = appendGroupKey(key, value, typ)
+		}
+
+		if seen[string(key)] {
+			continue
+		}
+
+		seen[string(key)] = true
+		distinct = append(distinct, rowIndex)
+	}
+
+	return distinct, nil
+}
+
+// resolveOrderBy replaces ORDER BY items that refer to a select item
+// by its position or its alias with the select item's expression
+func resolveOrderBy(orderBy []*OrderByItem, items []*SelectItem) ([]OrderByItem, error) {
+	resolved := []OrderByItem{}
+	for _, item := range orderBy {
+		r := *item
+		if position, ok := selectItemPosition(*r.Exp); ok {
+			if position < 1 || position > len(items) {
+				return nil, ErrInvalidOrderByPosition
+			}
+
+			r.Exp = items[position-1].Exp
+		} else if exp := aliasedSelectItem(*r.Exp, items); exp != nil {
+			r.Exp = exp
+		}
+
+		resolved = append(resolved, r)
+	}
+
+	return resolved, nil
+}
+
+// resolveGroupBy replaces GROUP BY expressions that refer to a select
+// item by its position or its alias with the select item's
+// expression. Unlike in ORDER BY, a column of t takes precedence over
+// an alias of the same name.
+func (t *table) resolveGroupBy(groupBy []*Expression, items []*SelectItem) ([]*Expression, error) {
+	resolved := []*Expression{}
+	for _, exp := range groupBy {
+		if position, ok := selectItemPosition(*exp); ok {
+			if position < 1 || position > len(items) {
+				return nil, ErrInvalidGroupByPosition
+			}
+
+			exp = items[position-1].Exp
+		} else if exp.Kind == LiteralKind && exp.Literal.Kind == IdentifierKind {
+			if _, err := t.columnIndex("", exp.Literal.Value); err == ErrColumnDoesNotExist {
+				if aliased := aliasedSelectItem(*exp, items); aliased != nil {
+					exp = aliased
+				}
+			}
+		}
+
+		resolved = append(resolved, exp)
+	}
+
+	return resolved, nil
+}
+
+// selectItemPosition is the position an integer literal refers to in
+// ORDER BY or GROUP BY, counting select items from 1
+func selectItemPosition(exp Expression) (int, bool) {
+	if exp.Kind != LiteralKind || exp.Literal.Kind != NumericKind {
+		return 0, false
+	}
+
+	position, err := strconv.Atoi(exp.Literal.Value)
+	if err != nil {
+		return 0, true
+	}
+
+	return position, true
+}
+
+// aliasedSelectItem is the expression of the select item exp names by
+// its alias, if any
+func aliasedSelectItem(exp Expression, items []*SelectItem) *Expression {
+	if exp.Kind != LiteralKind || exp.Literal.Kind != IdentifierKind {
+		return nil
+	}
+
+	for _, si := range items {
+		if si.As != nil && si.As.Value == exp.Literal.Value {
+			return si.Exp
+		}
+	}
+
+	return nil
+}
+
+// checkDistinctOrderBy makes sure rows can be ordered after DISTINCT
+// picks them. ORDER BY can only use select items, and with DISTINCT ON
+// it must start with the DISTINCT ON expressions so that the first
+// row of each set of duplicates is the one kept.
+func (t *table) checkDistinctOrderBy(orderBy []OrderByItem, items []*SelectItem, distinctOn *[]*Expression) error {
+	if distinctOn != nil {
+		for i, item := range orderBy {
+			if i >= len(*distinctOn) {
+				break
+			}
+
+			if !t.containsExpression(*distinctOn, *item.Exp) {
+				return ErrDistinctOnOrderBy
+			}
+		}
+
+		return nil
+	}
+
+	exps := []*Expression{}
+	for _, item := range items {
+		exps = append(exps, item.Exp)
+	}
+
+	for _, item := range orderBy {
+		if !t.containsExpression(exps, *item.Exp) {
+			return ErrDistinctOrderBy
+		}
+	}
+
+	return nil
+}
+
+// containsExpression reports whether exp is one of exps, treating
+// references to the same column as the same expression
+func (t *table) containsExpression(exps []*Expression, exp Expression) bool {
+	for _, e := range exps {
+		if e.GenerateCode() == exp.GenerateCode() {
+			return true
+		}
+
+		a, aOk := t.referencedColumn(*e)
+		b, bOk := t.referencedColumn(exp)
+		if aOk && bOk && a == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// referencedColumn is the column exp refers to, if it is a plain or
+// qualified column reference
+func (t *table) referencedColumn(exp Expression) (int, bool) {
+	var i int
+	var err error
+	switch {
+	case exp.Kind == LiteralKind && exp.Literal.Kind == IdentifierKind:
+		i, err = t.columnIndex("", exp.Literal.Value)
+	case exp.Kind == QualifiedKind:
+		i, err = t.columnIndex(exp.Qualified.Table.Value, exp.Qualified.Column.Value)
+	default:
+		return 0, false
+	}
+
+	return i, err == nil
+}
+
+type MemoryBackend struct {
+	tables map[string]*table
+}
+
+// joinTables combines every row of l with every row of r for which on
+// is true. Outer joins also keep the unmatched rows of l and/or r
+// with the columns of the other side set to NULL.
+func joinTables(l, r *table, kind JoinKind, on *Expression) (*table, error) {
+	t := createTable()
+	t.columns = append(append([]string{}, l.columns...), r.columns...)
+	t.columnTypes = append(append([]ColumnType{}, l.columnTypes...), r.columnTypes...)
+	for i := range l.columns {
+		t.columnTables = append(t.columnTables, l.columnTable(i))
+	}
+	for i := range r.columns {
+		t.columnTables = append(t.columnTables, r.columnTable(i))
+	}
+	t.rows = [][]memoryCell{}
+	t.scope = l.scope
+
+	leftNulls := make([]memoryCell, len(l.columns))
+	rightNulls := make([]memoryCell, len(r.columns))
+	rightMatched := make([]bool, len(r.rows))
+	for _, lrow := range l.rows {
+		// Skip rows that have been deleted
+		if lrow == nil {
+			continue
+		}
+
+		matched := false
+		for j, rrow := range r.rows {
+			if rrow == nil {
+				continue
+			}
+
+			t.rows = append(t.rows, append(append([]memoryCell{}, lrow...), rrow...))
+			if on != nil {
+				val, _, _, err := t.evaluateCell(uint(len(t.rows)-1), *on)
+				if err != nil {
+					return nil, err
+				}
+
+				if b := val.AsBool(); b == nil || !*b {
+					t.rows = t.rows[:len(t.rows)-1]
+					continue
+				}
+			}
+
+			matched = true
+			rightMatched[j] = true
+		}
+
+		if !matched && (kind == LeftJoinKind || kind == FullJoinKind) {
+			t.rows = append(t.rows, append(append([]memoryCell{}, lrow...), rightNulls...))
+		}
+	}
+
+	if kind == RightJoinKind || kind == FullJoinKind {
+		for j, rrow := range r.rows {
+			if rrow == nil || rightMatched[j] {
+				continue
+			}
+
+			t.rows = append(t.rows, append(append([]memoryCell{}, leftNulls...), rrow...))
+		}
+	}
+
+	return t, nil
+}
+
+// functionTable joins the rows of a set-returning function in FROM,
+// of which only unnest is supported, to the table l of the items
+// before it. Unless the join is a RIGHT or FULL join, the argument is
+// evaluated for each row of l so that it can use the columns of l.
+// The column of the function is named by its alias if it has one.
+func functionTable(l *table, fi *FromItem, scope *queryScope) (*table, error) {
+	call := fi.Function
+	if call.Name.Value != "unnest" {
+		return nil, ErrFunctionDoesNotExist
+	}
+
+	if call.Asterisk || len(*call.Args) != 1 {
+		return nil, ErrInvalidArguments
+	}
+
+	name := call.Name.Value
+	if fi.As != nil {
+		name = fi.As.Value
+	}
+
+	r := createTable()
+	r.name = name
+	r.columns = []string{name}
+	r.scope = scope
+
+	arg := *(*call.Args)[0]
+	unnest := func(t *table, rowIndex uint) error {
+		value, _, typ, err := t.evaluateCell(rowIndex, arg)
+		if err != nil {
+			return err
+		}
+
+		if !typ.IsArray() {
+			return ErrInvalidArguments
+		}
+
+		r.columnTypes = []ColumnType{typ.ElementType()}
+		r.rows = [][]memoryCell{}
+		for _, element := range decodeArray(value) {
+			r.rows = append(r.rows, []memoryCell{element})
+		}
+
+		return nil
+	}
+
+	if l == nil || fi.Join == RightJoinKind || fi.Join == FullJoinKind {
+		empty := createTable()
+		empty.rows = [][]memoryCell{{}}
+		empty.scope = scope
+		if err := unnest(empty, 0); err != nil {
+			return nil, err
+		}
+
+		if l == nil {
+			return r, nil
+		}
+
+		return joinTables(l, r, fi.Join, fi.On)
+	}
+
+	// The type of the argument is found on a row of NULLs in case l
+	// has no rows
+	if err := unnest(l.withNullRow(), 0); err != nil {
+		return nil, err
+	}
+
+	empty := *l
+	empty.rows = nil
+	t, err := joinTables(&empty, r, fi.Join, fi.On)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, lrow := range l.rows {
+		// Skip rows that have been deleted
+		if lrow == nil {
+			continue
+		}
+
+		if err := unnest(l, uint(i)); err != nil {
+			return nil, err
+		}
+
+		single := *l
+		single.rows = [][]memoryCell{lrow}
+		joined, err := joinTables(&single, r, fi.Join, fi.On)
+		if err != nil {
+			return nil, err
+		}
+
+		t.rows = append(t.rows, joined.rows...)
+	}
+
+	return t, nil
+}
+
+// fromTable returns the table a SELECT reads from. A single table is
+// used as is so that its indexes stay available, multiple tables are
+// joined into a new table from left to right.
+func (mb *MemoryBackend) fromTable(from []*FromItem, scope *queryScope) (*table, error) {
+	var t *table
+	for i, fi := range from {
+		if fi.Function != nil {
+			var err error
+			t, err = functionTable(t, fi, scope)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		// Common table expressions hide tables of the same name
+		stored, ok := scope.ctes[fi.Table.Value]
+		if !ok {
+			stored, ok = mb.tables[fi.Table.Value]
+		}
+		if !ok {
+			return nil, ErrTableDoesNotExist
+		}
+
+		// A shallow copy shares rows and indexes with the stored
+		// table but can be known by an alias and see the enclosing
+		// query
+		scoped := *stored
+		scoped.scope = scope
+		if fi.As != nil {
+			scoped.name = fi.As.Value
+		}
+		next := &scoped
+
+		if i == 0 {
+			t = next
+			continue
+		}
+
+		var err error
+		t, err = joinTables(t, next, fi.Join, fi.On)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// pageRows applies the LIMIT and OFFSET of slct to rowIndexes
+func (t *table) pageRows(rowIndexes []uint, slct *SelectStatement) ([]uint, error) {
+	limit := len(rowIndexes)
+	if slct.Limit != nil {
+		v, _, vt, err := t.evaluateCell(0, *slct.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isIntegerType(vt) {
+			return nil, ErrInvalidOperands
+		}
+
+		if v != nil {
+			limit = int(cellInt64(v, vt))
+		}
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("Invalid, negative limit")
+	}
+
+	offset := 0
+	if slct.Offset != nil {
+		v, _, vt, err := t.evaluateCell(0, *slct.Offset)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isIntegerType(vt) {
+			return nil, ErrInvalidOperands
+		}
+
+		if v != nil {
+			offset = int(cellInt64(v, vt))
+		}
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("Invalid, negative limit")
+	}
+
+	if offset > len(rowIndexes) {
+		offset = len(rowIndexes)
+	}
+	if limit > len(rowIndexes)-offset {
+		limit = len(rowIndexes) - offset
+	}
+
+	return rowIndexes[offset : offset+limit], nil
+}
+
+// setOperationTable computes the rows of a set operation into a
+// temporary table with the column names of its left side. Rows are
+// compared like in DISTINCT, so NULLs are equal to each other.
+func (mb *MemoryBackend) setOperationTable(so *SetOperation, scope *queryScope) (*table, error) {
+	left, err := mb.selectScoped(so.Left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := mb.selectScoped(so.Right, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(left.Columns) != len(right.Columns) {
+		return nil, ErrSetOperationColumnCount
+	}
+
+	// A bare NULL takes the type of the other side, and numbers of
+	// different types share the highest ranked of them
+	leftNulls := nullColumns(so.Left)
+	rightNulls := nullColumns(so.Right)
+	t := createTable()
+	t.rows = [][]memoryCell{}
+	t.scope = scope
+	for i, column := range left.Columns {
+		typ := column.Type
+		rightType := right.Columns[i].Type
+		switch {
+		case i < len(leftNulls) && leftNulls[i]:
+			typ = rightType
+		case (i < len(rightNulls) && rightNulls[i]) || typ == rightType:
+		case isNumericType(typ) && isNumericType(rightType):
+			typ = commonNumericType(typ, rightType)
+		default:
+			return nil, ErrSetOperationColumnType
+		}
+
+		t.columns = append(t.columns, column.Name)
+		t.columnTypes = append(t.columnTypes, typ)
+	}
+
+	for _, side := range []*Results{left, right} {
+		side.Rows, err = castRows(side, t.columnTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rowKey := func(row []Cell) string {
+		key := []byte{}
+		for i, cell := range row {
+			key = appendGroupKey(key, cell.(memoryCell), t.columnTypes[i])
+		}
+		return string(key)
+	}
+
+	addRow := func(row []Cell) {
+		cells := []memoryCell{}
+		for _, cell := range row {
+			cells = append(cells, cell.(memoryCell))
+		}
+		t.rows = append(t.rows, cells)
+	}
+
+	seen := map[string]bool{}
+	if so.Op == UnionOperator {
+		for _, row := range append(append([][]Cell{}, left.Rows...), right.Rows...) {
+			if !so.All {
+				key := rowKey(row)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+
+			addRow(row)
+		}
+
+		return t, nil
+	}
+
+	inRight := map[string]int{}
+	for _, row := range right.Rows {
+		inRight[rowKey(row)]++
+	}
+
+	for _, row := range left.Rows {
+		key := rowKey(row)
+		found := inRight[key] > 0
+		if so.All {
+			// Each row on the right matches only one on the left
+			if found {
+				inRight[key]--
+			}
+		} else {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		if found == (so.Op == IntersectOperator) {
+			addRow(row)
+		}
+	}
+
+	return t, nil
+}
+
+// nullColumns reports which result columns of slct are a bare NULL,
+// on both sides when it is itself a set operation
+func nullColumns(slct *SelectStatement) []bool {
+	if slct.SetOperation != nil {
+		left := nullColumns(slct.SetOperation.Left)
+		right := nullColumns(slct.SetOperation.Right)
+		if len(left) != len(right) {
+			return nil
+		}
+
+		for i := range left {
+			left[i] = left[i] && right[i]
+		}
+		return left
+	}
+
+	if slct.Item == nil {
+		return nil
+	}
+
+	nulls := []bool{}
+	for _, item := range *slct.Item {
+		// Can't tell which columns * expands to
+		if item.Asterisk {
+			return nil
+		}
+
+		nulls = append(nulls, item.Exp.Kind == LiteralKind && item.Exp.Literal.Kind == NullKind)
+	}
+
+	return nulls
+}
+
+// castRows converts the numbers in results to the column types of a
+// set operation
+func castRows(results *Results, types []ColumnType) ([][]Cell, error) {
+	rows := [][]Cell{}
+	for _, row := range results.Rows {
+		cells := []Cell{}
+		for i, cell := range row {
+			value := cell.(memoryCell)
+			if value != nil && results.Columns[i].Type != types[i] {
+				var err error
+				value, err = castNumber(value, results.Columns[i].Type, types[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			cells = append(cells, value)
+		}
+		rows = append(rows, cells)
+	}
+
+	return rows, nil
+}
+
+func (mb *MemoryBackend) selectSetOperation(slct *SelectStatement, scope *queryScope) (*Results, error) {
+	t, err := mb.setOperationTable(slct.SetOperation, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []ResultColumn{}
+	items := []*SelectItem{}
+	for i, column := range t.columns {
+		columns = append(columns, ResultColumn{
+			Type: t.columnTypes[i],
+			Name: column,
+		})
+		items = append(items, &SelectItem{
+			Exp: &Expression{
+				Literal: &Token{Value: column, Kind: IdentifierKind},
+				Kind:    LiteralKind,
+			},
+		})
+	}
+
+	rowIndexes := []uint{}
+	for i := range t.rows {
+		rowIndexes = append(rowIndexes, uint(i))
+	}
+
+	if slct.OrderBy != nil {
+		// Only the combined columns can be used to order the rows
+		orderBy, err := resolveOrderBy(*slct.OrderBy, items)
+		if err != nil {
+			return nil, err
+		}
+
+		rowIndexes, err = t.orderRows(rowIndexes, orderBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rowIndexes, err = t.pageRows(rowIndexes, slct)
+	if err != nil {
+		return nil, err
+	}
+
+	results := [][]Cell{}
+	for _, i := range rowIndexes {
+		result := []Cell{}
+		for _, value := range t.rows[i] {
+			result = append(result, value)
+		}
+		results = append(results, result)
+	}
+
+	return &Results{
+		Columns: columns,
+		Rows:    results,
+	}, nil
+}
+
+// cteTable holds the results of a common table expression in a
+// temporary table, renaming its columns if the expression lists
+// column names
+func cteTable(cte *CommonTableExpression, results *Results) (*table, error) {
+	t := createTable()
+	t.name = cte.Name.Value
+	t.rows = [][]memoryCell{}
+	for _, column := range results.Columns {
+		t.columns = append(t.columns, column.Name)
+		t.columnTypes = append(t.columnTypes, column.Type)
+	}
+
+	if cte.Columns != nil {
+		if len(*cte.Columns) > len(t.columns) {
+			return nil, ErrCTEColumnCount
+		}
+
+		for i, column := range *cte.Columns {
+			t.columns[i] = column.Value
+		}
+	}
+
+	for _, row := range results.Rows {
+		cells := []memoryCell{}
+		for _, cell := range row {
+			cells = append(cells, cell.(memoryCell))
+		}
+		t.rows = append(t.rows, cells)
+	}
+
+	return t, nil
+}
+
+// readsTable reports whether a FROM clause of slct names the table
+func readsTable(slct *SelectStatement, name string) bool {
+	if slct.SetOperation != nil {
+		return readsTable(slct.SetOperation.Left, name) || readsTable(slct.SetOperation.Right, name)
+	}
+
+	if slct.From != nil {
+		for _, fi := range *slct.From {
+			if fi.Table.Value == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// maxRecursiveRows bounds the rows a recursive common table
+// expression can produce, since one that never stops would otherwise
+// run out of memory
+const maxRecursiveRows = 100000
+
+// recursiveTable evaluates a recursive common table expression. The
+// SELECT after UNION is run repeatedly, reading the rows produced by
+// its previous run, until it produces no new rows.
+func (mb *MemoryBackend) recursiveTable(cte *CommonTableExpression, scope *queryScope) (*table, error) {
+	so := cte.Select.SetOperation
+	anchor, err := mb.selectScoped(so.Left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := cteTable(cte, anchor)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	working := [][]memoryCell{}
+	for _, row := range result.rows {
+		if !so.All {
+			key := []byte{}
+			for i, cell := range row {
+				key = appendGroupKey(key, cell, result.columnTypes[i])
+			}
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+		}
+
+		working = append(working, row)
+	}
+	result.rows = working
+
+	for len(working) > 0 {
+		previous := *result
+		previous.rows = working
+		scope.ctes[result.name] = &previous
+
+		next, err := mb.selectScoped(so.Right, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(next.Columns) != len(result.columns) {
+			return nil, ErrSetOperationColumnCount
+		}
+		for i, column := range next.Columns {
+			if column.Type != result.columnTypes[i] {
+				return nil, ErrSetOperationColumnType
+			}
+		}
+
+		working = [][]memoryCell{}
+		for _, row := range next.Rows {
+			cells := []memoryCell{}
+			key := []byte{}
+			for i, cell := range row {
+				cells = append(cells, cell.(memoryCell))
+				key = appendGroupKey(key, cell.(memoryCell), result.columnTypes[i])
+			}
+
+			if !so.All {
+				if seen[string(key)] {
+					continue
+				}
+				seen[string(key)] = true
+			}
+
+			working = append(working, cells)
+		}
+
+		result.rows = append(result.rows, working...)
+		if len(result.rows) > maxRecursiveRows {
+			return nil, ErrRecursionLimit
+		}
+	}
+
+	return result, nil
+}
+
+// withScope adds the common table expressions of a WITH clause to a
+// copy of scope, in order so each one can read the ones before it
+func (mb *MemoryBackend) withScope(ctes []*CommonTableExpression, recursive bool, parent *queryScope) (*queryScope, error) {
+	scope := *parent
+	scope.ctes = map[string]*table{}
+	for name, t := range parent.ctes {
+		scope.ctes[name] = t
+	}
+
+	names := map[string]bool{}
+	for _, cte := range ctes {
+		name := cte.Name.Value
+		if names[name] {
+			return nil, ErrDuplicateCTE
+		}
+		names[name] = true
+
+		so := cte.Select.SetOperation
+		if recursive && so != nil && so.Op == UnionOperator && readsTable(so.Right, name) {
+			t, err := mb.recursiveTable(cte, &scope)
+			if err != nil {
+				return nil, err
+			}
+
+			scope.ctes[name] = t
+			continue
+		}
+
+		results, err := mb.selectScoped(cte.Select, &scope)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := cteTable(cte, results)
+		if err != nil {
+			return nil, err
+		}
+
+		scope.ctes[name] = t
+	}
+
+	return &scope, nil
+}
+
+func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
+	return mb.selectScoped(slct, &queryScope{backend: mb})
+}
+
+func (mb *MemoryBackend) selectScoped(slct *SelectStatement, scope *queryScope) (*Results, error) {
+	if slct.With != nil {
+		var err error
+		scope, err = mb.withScope(*slct.With, slct.Recursive, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slct.SetOperation != nil {
+		return mb.selectSetOperation(slct, scope)
+	}
+
+	t := createTable()
+
+	if slct.From != nil {
+		var err error
+		t, err = mb.fromTable(*slct.From, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slct.Item == nil || len(*slct.Item) == 0 {
+		return &Results{}, nil
+	}
+
+	results := [][]Cell{}
+	columns := []ResultColumn{}
+
+	if slct.From == nil {
+		t = createTable()
+		t.rows = [][]memoryCell{{}}
+		t.scope = scope
+	}
+
+	// Expand SELECT * at the AST level into a SELECT on all columns
+	finalItems := []*SelectItem{}
+	for _, item := range *slct.Item {
+		if item.Asterisk {
+			newItems := []*SelectItem{}
+			for j := 0; j < len(t.columns); j++ {
+				// Qualified so that columns with the same name in
+				// joined tables aren't ambiguous
+				newSelectItem := &SelectItem{
+					Exp: &Expression{
+						Qualified: &QualifiedColumn{
+							Table: Token{
+								Value: t.columnTable(j),
+								Kind:  IdentifierKind,
+							},
+							Column: Token{
+								Value: t.columns[j],
+								Kind:  IdentifierKind,
+								Loc:   Location{0, uint(len("SELECT") + 1)},
+							},
+						},
+						Kind: QualifiedKind,
+					},
+					Asterisk: false,
+					As:       nil,
+				}
+				newItems = append(newItems, newSelectItem)
+			}
+			finalItems = append(finalItems, newItems...)
+		} else {
+			finalItems = append(finalItems, item)
+		}
+	}
+
+	rowIndexes, err := t.rowsMatching(slct.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy := []OrderByItem{}
+	if slct.OrderBy != nil {
+		orderBy, err = resolveOrderBy(*slct.OrderBy, finalItems)
+		if err != nil {
+			return nil, err
+		}
+
+		if slct.Distinct {
+			err = t.checkDistinctOrderBy(orderBy, finalItems, slct.DistinctOn)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	exps := []*Expression{slct.Having}
+	for _, item := range finalItems {
+		exps = append(exps, item.Exp)
+	}
+	for _, item := range orderBy {
+		exps = append(exps, item.Exp)
+	}
+	if slct.DistinctOn != nil {
+		exps = append(exps, *slct.DistinctOn...)
+	}
+
+	aggregates := findAggregates(exps)
+	if slct.GroupBy != nil || slct.Having != nil || len(aggregates) > 0 {
+		groupBy := []*Expression{}
+		if slct.GroupBy != nil {
+			groupBy, err = t.resolveGroupBy(*slct.GroupBy, finalItems)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		t, err = t.groupRows(rowIndexes, groupBy, aggregates)
+		if err != nil {
+			return nil, err
+		}
+
+		rowIndexes, err = t.rowsMatching(slct.Having)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(orderBy) > 0 {
+		rowIndexes, err = t.orderRows(rowIndexes, orderBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slct.Distinct {
+		distinctOn := []*Expression{}
+		if slct.DistinctOn != nil {
+			distinctOn = *slct.DistinctOn
+		} else {
+			for _, item := range finalItems {
+				distinctOn = append(distinctOn, item.Exp)
+			}
+		}
+
+		rowIndexes, err = t.distinctRows(rowIndexes, distinctOn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rowIndexes, err = t.pageRows(rowIndexes, slct)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range rowIndexes {
+
+		result := []Cell{}
+		isFirstRow := len(results) == 0
+
+		for _, col := range finalItems {
+			value, columnName, columnType, err := t.evaluateCell(i, *col.Exp)
+			if err != nil {
+				return nil, err
+			}
+
+			if isFirstRow {
+				if col.As != nil {
+					columnName = col.As.Value
+				}
+
+				columns = append(columns, ResultColumn{
+					Type: columnType,
+					Name: columnName,
+				})
+			}
+
+			result = append(result, value)
+		}
+
+		results = append(results, result)
+	}
+
+	// Describe the columns even when there are no rows, leaving
+	// them out if that isn't possible without a row
+	if len(results) == 0 {
+		probe := t.withNullRow()
+		for _, col := range finalItems {
+			_, columnName, columnType, err := probe.evaluateCell(0, *col.Exp)
+			if err != nil {
+				columns = []ResultColumn{}
+				break
+			}
+
+			if col.As != nil {
+				columnName = col.As.Value
+			}
+
+			columns = append(columns, ResultColumn{
+				Type: columnType,
+				Name: columnName,
+			})
+		}
+	}
+
+	return &Results{
+		Columns: columns,
+		Rows:    results,
+	}, nil
+}
+
+func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
+	t, ok := mb.tables[inst.Table.Value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	if inst.Values == nil {
+		return nil
+	}
+
+	// Find the column each value is for
+	given := make([]bool, len(t.columns))
+	columns := []int{}
+	if inst.Columns == nil {
+		for i := range t.columns {
+			given[i] = true
+			columns = append(columns, i)
+		}
+	} else {
+		var err error
+		columns, err = t.columnIndexes(*inst.Columns)
+		if err != nil {
+			return err
+		}
+
+		for _, column := range columns {
+			if given[column] {
+				return ErrDuplicateColumn
+			}
+
+			given[column] = true
+		}
+	}
+
+	if len(*inst.Values) != len(columns) {
+		return ErrMissingValues
+	}
+
+	row := make([]memoryCell, len(t.columns))
+	for i, valueNode := range *inst.Values {
+		emptyTable := createTable()
+		value, _, valueType, err := emptyTable.evaluateCell(0, *valueNode)
+		if err != nil {
+			return err
+		}
+
+		row[columns[i]], err = t.assignCell(value, valueType, columns[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Columns left out get their default, or NULL
+	for i, exp := range t.columnDefaults {
+		if given[i] || exp == nil {
+			continue
+		}
+
+		value, err := t.defaultCell(i)
+		if err != nil {
+			return err
+		}
+
+		row[i] = value
+	}
+
+	cs := newChangeSet()
+	cs.set(t, uint(len(t.rows)), row)
+	return cs.apply()
+}
+
+// assignable reports whether a value of type from can be stored in a
+// column of type to. Numbers can be stored in any numeric column they
+// fit in, dates in timestamp columns and arrays in columns of arrays
+// their elements can be stored in. Other values must already have the
+// type of the column.
+func assignable(from, to ColumnType) bool {
+	if from.IsArray() && to.IsArray() {
+		return assignable(from.ElementType(), to.ElementType())
+	}
+
+	return from == to || (isNumericType(from) && isNumericType(to)) || (from == DateType && to == TimestampType)
+}
+
+// assignCell converts a value of type typ being stored in column i
+func (t *table) assignCell(value memoryCell, typ ColumnType, i int) (memoryCell, error) {
+	if value == nil {
+		return value, nil
+	}
+
+	columnType := t.columnTypes[i]
+	if !assignable(typ, columnType) {
+		return nil, ErrInvalidDatatype
+	}
+
+	value, err := castCell(value, typ, columnType)
+	if err != nil {
+		return nil, err
+	}
+
+	if i < len(t.columnPrecisions) {
+		return applyPrecision(value, columnType, t.columnPrecisions[i])
+	}
+
+	return value, nil
+}
+
+// defaultCell evaluates the default of column i
+func (t *table) defaultCell(i int) (memoryCell, error) {
+	emptyTable := createTable()
+	value, _, valueType, err := emptyTable.evaluateCell(0, *t.columnDefaults[i])
+	if err != nil {
+		return nil, err
+	}
+
+	return t.assignCell(value, valueType, i)
+}
+
+// checkRow returns an error if a new row for t breaks its NOT NULL
+// or CHECK constraints. Checks that are NULL pass, as in Postgres.
+func (t *table) checkRow(row []memoryCell) error {
+	for i, notNull := range t.columnNotNull {
+		if notNull && row[i] == nil {
+			return ErrViolatesNotNullConstraint
+		}
+	}
+
+	probe := *t
+	probe.rows = [][]memoryCell{row}
+	// Checks can't run subqueries
+	probe.scope = nil
+	for _, check := range t.checks {
+		value, _, _, err := probe.evaluateCell(0, *check)
+		if err != nil {
+			return err
+		}
+
+		if b := value.AsBool(); b != nil && !*b {
+			return ErrViolatesCheckConstraint
+		}
+	}
+
+	return nil
+}
+
+// validateConstraints returns an error if a default of t can't be
+// stored in its column or a check isn't a condition on its columns
+func (t *table) validateConstraints() error {
+	for i, exp := range t.columnDefaults {
+		if exp == nil {
+			continue
+		}
+
+		_, err := t.defaultCell(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	probe := t.withNullRow()
+	probe.scope = nil
+	for _, check := range t.checks {
+		_, _, typ, err := probe.evaluateCell(0, *check)
+		if err != nil {
+			return err
+		}
+
+		if typ != BoolType {
+			return ErrInvalidDatatype
+		}
+	}
+
+	return nil
+}
+
+// rowsMatching returns the positions of the rows in t for which
+// where is true, or every row if where is nil. An applicable index
+// is used to narrow down the rows to check when there is one.
+func (t *table) rowsMatching(where *Expression) ([]uint, error) {
+	var candidates []uint
+	if iAndEs := t.getApplicableIndexes(where); len(iAndEs) > 0 {
+		candidates, _ = iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+	}
+
+	if candidates == nil {
+		for i, row := range t.rows {
+			// Skip rows that have been deleted
+			if row != nil {
+				candidates = append(candidates, uint(i))
+			}
+		}
+	}
+
+	rowIndexes := []uint{}
+	for _, i := range candidates {
+		if where != nil {
+			val, _, _, err := t.evaluateCell(i, *where)
+			if err != nil {
+				return nil, err
+			}
+
+			if b := val.AsBool(); b == nil || !*b {
+				continue
+			}
+		}
+
+		rowIndexes = append(rowIndexes, i)
+	}
+
+	return rowIndexes, nil
+}
+
+func (mb *MemoryBackend) Update(upd *UpdateStatement) error {
+	t, ok := mb.tables[upd.Table.Value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	if upd.Set == nil {
+		return nil
+	}
+
+	columns := []int{}
+	for _, set := range *upd.Set {
+		column := -1
+		for i, tableCol := range t.columns {
+			if tableCol == set.Column.Value {
+				column = i
+				break
+			}
+		}
+
+		if column == -1 {
+			return ErrColumnDoesNotExist
+		}
+
+		for _, c := range columns {
+			if c == column {
+				return ErrDuplicateColumn
+			}
+		}
+
+		columns = append(columns, column)
+	}
+
+	rowIndexes, err := t.rowsMatching(upd.Where)
+	if err != nil {
+		return err
+	}
+
+	// Compute every new row before changing anything so that an
+	// error leaves the tables as they were
+	cs := newChangeSet()
+	for _, rowIndex := range rowIndexes {
+		// Start from the row as changed by foreign key actions of
+		// the rows updated before it
+		row := append([]memoryCell{}, cs.row(t, rowIndex)...)
+		for i, set := range *upd.Set {
+			value, _, columnType, err := t.evaluateCell(rowIndex, set.Exp)
+			if err != nil {
+				return err
+			}
+
+			value, err = t.assignCell(value, columnType, columns[i])
+			if err != nil {
+				return err
+			}
+
+			row[columns[i]] = value
+		}
+
+		err = mb.updateRow(cs, t, rowIndex, row)
+		if err != nil {
+			return err
 		}
+	}
 
-		return append(exps, *where)
+	return cs.apply()
+}
+
+func (mb *MemoryBackend) Delete(del *DeleteStatement) error {
+	t, ok := mb.tables[del.Table.Value]
+	if !ok {
+		return ErrTableDoesNotExist
 	}
 
-	exps := linearizeExpressions(where, []Expression{})
+	rowIndexes, err := t.rowsMatching(del.Where)
+	if err != nil {
+		return err
+	}
 
-	iAndE := []indexAndExpression{}
-	for _, exp := range exps {
-		for _, index := range t.indexes {
-			if index.applicableValue(exp) != nil {
-				iAndE = append(iAndE, indexAndExpression{
-					i: index,
-					e: exp,
-				})
-			}
+	cs := newChangeSet()
+	for _, rowIndex := range rowIndexes {
+		err = mb.deleteRow(cs, t, rowIndex)
+		if err != nil {
+			return err
 		}
 	}
 
-	return iAndE
+	return cs.apply()
 }
 
-type MemoryBackend struct {
-	tables map[string]*table
+// tableChanges holds the new values of the rows of a table changed
+// by a statement, with nil for deleted rows
+type tableChanges struct {
+	rowIndexes []uint
+	rows       map[uint][]memoryCell
+
+	// old holds the values replaced by apply and length the number
+	// of rows before it, to roll the changes back
+	old    [][]memoryCell
+	length int
 }
 
-func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
-	t := createTable()
+// changeSet holds the rows a statement changes in each table,
+// including those changed by the actions of foreign keys, so that
+// every constraint can be checked before any table is changed
+type changeSet struct {
+	tables  []*table
+	changes map[*table]*tableChanges
 
-	if slct.From != nil {
-		var ok bool
-		t, ok = mb.tables[slct.From.Value]
-		if !ok {
-			return nil, ErrTableDoesNotExist
+	// rechecked holds rows whose references must be checked once
+	// every change is known
+	rechecked map[*table][]uint
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{
+		changes:   map[*table]*tableChanges{},
+		rechecked: map[*table][]uint{},
+	}
+}
+
+// row returns the value row i of t will have after the changes, or
+// nil if it is deleted
+func (cs *changeSet) row(t *table, i uint) []memoryCell {
+	if tc, ok := cs.changes[t]; ok {
+		if row, ok := tc.rows[i]; ok {
+			return row
 		}
 	}
 
-	if slct.Item == nil || len(*slct.Item) == 0 {
-		return &Results{}, nil
+	if i < uint(len(t.rows)) {
+		return t.rows[i]
 	}
 
-	results := [][]Cell{}
-	columns := []ResultColumn{}
+	return nil
+}
 
-	if slct.From == nil {
-		t = createTable()
-		t.rows = [][]memoryCell{{}}
+// set changes row i of t to row, deleting it if row is nil. Rows
+// past the end of t are inserted.
+func (cs *changeSet) set(t *table, i uint, row []memoryCell) {
+	tc, ok := cs.changes[t]
+	if !ok {
+		tc = &tableChanges{rows: map[uint][]memoryCell{}}
+		cs.changes[t] = tc
+		cs.tables = append(cs.tables, t)
 	}
 
-	for _, iAndE := range t.getApplicableIndexes(slct.Where) {
-		index := iAndE.i
-		exp := iAndE.e
-		t = index.newTableFromSubset(t, exp)
+	if _, ok := tc.rows[i]; !ok {
+		tc.rowIndexes = append(tc.rowIndexes, i)
 	}
 
-	// Expand SELECT * at the AST level into a SELECT on all columns
-	finalItems := []*SelectItem{}
-	for _, item := range *slct.Item {
-		if item.Asterisk {
-			newItems := []*SelectItem{}
-			for j := 0; j < len(t.columns); j++ {
-				newSelectItem := &SelectItem{
-					Exp: &Expression{
-						Literal: &Token{
-							Value: t.columns[j],
-							Kind:  IdentifierKind,
-							Loc:   Location{0, uint(len("SELECT") + 1)},
-						},
-						Binary: nil,
-						Kind:   LiteralKind,
-					},
-					Asterisk: false,
-					As:       nil,
-				}
-				newItems = append(newItems, newSelectItem)
-			}
-			finalItems = append(finalItems, newItems...)
-		} else {
-			finalItems = append(finalItems, item)
-		}
-	}
+	tc.rows[i] = row
+}
 
-	limit := len(t.rows)
-	if slct.Limit != nil {
-		v, _, _, err := t.evaluateCell(0, *slct.Limit)
-		if err != nil {
-			return nil, err
-		}
+// recheck marks a row of t whose references must be checked after
+// every change is known
+func (cs *changeSet) recheck(t *table, i uint) {
+	cs.rechecked[t] = append(cs.rechecked[t], i)
+}
 
-		limit = int(*v.AsInt())
-	}
-	if limit < 0 {
-		return nil, fmt.Errorf("Invalid, negative limit")
+// deleteRow deletes row i of t and applies the actions of the
+// foreign keys referencing it
+func (mb *MemoryBackend) deleteRow(cs *changeSet, t *table, i uint) error {
+	old := cs.row(t, i)
+	if old == nil {
+		// Already deleted by the action of a foreign key
+		return nil
 	}
 
-	offset := 0
-	if slct.Offset != nil {
-		v, _, _, err := t.evaluateCell(0, *slct.Offset)
-		if err != nil {
-			return nil, err
-		}
+	cs.set(t, i, nil)
+	return mb.changeKeys(cs, t, old, nil)
+}
 
-		offset = int(*v.AsInt())
-	}
-	if offset < 0 {
-		return nil, fmt.Errorf("Invalid, negative limit")
-	}
+// updateRow changes row i of t to row and applies the actions of the
+// foreign keys referencing it
+func (mb *MemoryBackend) updateRow(cs *changeSet, t *table, i uint, row []memoryCell) error {
+	old := cs.row(t, i)
+	cs.set(t, i, row)
+	return mb.changeKeys(cs, t, old, row)
+}
 
-	rowIndex := -1
-	for i := range t.rows {
-		result := []Cell{}
-		isFirstRow := len(results) == 0
+// check returns an error if a row changed by cs breaks a constraint
+func (cs *changeSet) check() error {
+	for _, t := range cs.tables {
+		tc := cs.changes[t]
+		for _, rowIndex := range tc.rowIndexes {
+			row := tc.rows[rowIndex]
+			if row == nil {
+				continue
+			}
 
-		if slct.Where != nil {
-			val, _, _, err := t.evaluateCell(uint(i), *slct.Where)
+			err := t.checkRow(row)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			if !*val.AsBool() {
-				continue
+			err = cs.checkReferences(t, row)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		rowIndex++
-		if rowIndex < offset {
-			continue
-		} else if rowIndex > offset+limit-1 {
-			break
+	for t, rowIndexes := range cs.rechecked {
+		for _, rowIndex := range rowIndexes {
+			if row := cs.row(t, rowIndex); row != nil {
+				err := cs.checkReferences(t, row)
+				if err != nil {
+					return err
+				}
+			}
 		}
+	}
 
-		for _, col := range finalItems {
-			value, columnName, columnType, err := t.evaluateCell(uint(i), *col.Exp)
-			if err != nil {
-				return nil, err
-			}
+	return nil
+}
 
-			if isFirstRow {
-				columns = append(columns, ResultColumn{
-					Type: columnType,
-					Name: columnName,
-				})
+// apply checks the changes and stores them, leaving every table as
+// it was if any fails
+func (cs *changeSet) apply() error {
+	err := cs.check()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range cs.tables {
+		err = cs.changes[t].apply(t)
+		if err != nil {
+			for j := i - 1; j >= 0; j-- {
+				cs.changes[cs.tables[j]].rollback(cs.tables[j])
 			}
 
-			result = append(result, value)
+			return err
 		}
-
-		results = append(results, result)
 	}
 
-	return &Results{
-		Columns: columns,
-		Rows:    results,
-	}, nil
+	return nil
 }
 
-func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
-	t, ok := mb.tables[inst.Table.Value]
-	if !ok {
-		return ErrTableDoesNotExist
-	}
+// apply stores the changed rows of t. The old values are taken out
+// of every index before adding the new ones so that rows can trade
+// unique values with each other. On failure t is left as it was.
+func (tc *tableChanges) apply(t *table) error {
+	tc.length = len(t.rows)
+	tc.old = make([][]memoryCell, len(tc.rowIndexes))
+	for j, rowIndex := range tc.rowIndexes {
+		if rowIndex >= uint(len(t.rows)) || t.rows[rowIndex] == nil {
+			continue
+		}
 
-	if inst.Values == nil {
-		return nil
+		tc.old[j] = t.rows[rowIndex]
+		for _, index := range t.indexes {
+			err := index.removeRow(t, rowIndex)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	if len(*inst.Values) != len(t.columns) {
-		return ErrMissingValues
+	for _, rowIndex := range tc.rowIndexes {
+		for rowIndex >= uint(len(t.rows)) {
+			t.rows = append(t.rows, nil)
+		}
+
+		// Deleted rows are left as nil tombstones so the row
+		// positions stored in indexes stay valid
+		t.rows[rowIndex] = tc.rows[rowIndex]
 	}
 
-	row := []memoryCell{}
-	for _, valueNode := range *inst.Values {
-		if valueNode.Kind != LiteralKind {
-			fmt.Println("Skipping non-literal.")
-			continue
-		}
+	for _, index := range t.indexes {
+		for _, rowIndex := range tc.rowIndexes {
+			if t.rows[rowIndex] == nil {
+				continue
+			}
 
-		emptyTable := createTable()
-		value, _, _, err := emptyTable.evaluateCell(0, *valueNode)
-		if err != nil {
-			return err
+			err := index.addRow(t, rowIndex)
+			if err != nil {
+				tc.rollback(t)
+				return err
+			}
 		}
+	}
+
+	return nil
+}
 
-		row = append(row, value)
+// rollback restores the rows replaced by apply along with their
+// index entries
+func (tc *tableChanges) rollback(t *table) {
+	for _, index := range t.indexes {
+		for _, rowIndex := range tc.rowIndexes {
+			// Errors are impossible here, the new values were
+			// already evaluated once. Entries that were never
+			// added are ignored.
+			if t.rows[rowIndex] != nil {
+				_ = index.removeRow(t, rowIndex)
+			}
+		}
 	}
 
-	t.rows = append(t.rows, row)
+	for j, rowIndex := range tc.rowIndexes {
+		t.rows[rowIndex] = tc.old[j]
+	}
+	t.rows = t.rows[:tc.length]
 
 	for _, index := range t.indexes {
-		err := index.addRow(t, uint(len(t.rows)-1))
-		if err != nil {
-			// Drop the row on failure
-			t.rows = t.rows[:len(t.rows)-1]
-			return err
+		for j, rowIndex := range tc.rowIndexes {
+			// These values were in the index before the changes
+			if tc.old[j] != nil {
+				_ = index.addRow(t, rowIndex)
+			}
 		}
 	}
+}
 
-	return nil
+// columnExpressions returns expressions reading each of columns
+func columnExpressions(columns []*Token) *[]*Expression {
+	exps := []*Expression{}
+	for _, column := range columns {
+		exps = append(exps, &Expression{
+			Literal: column,
+			Kind:    LiteralKind,
+		})
+	}
+
+	return &exps
 }
 
 func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
@@ -712,26 +4494,21 @@ func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
 
 	t := createTable()
 	t.name = crt.Name.Value
+	t.scope = &queryScope{backend: mb}
 	mb.tables[t.name] = t
 	if crt.Cols == nil {
 		return nil
 	}
 
-	var primaryKey *Expression = nil
+	var primaryKey []*Token = nil
+	uniques := [][]*Token{}
 	for _, col := range *crt.Cols {
 		t.columns = append(t.columns, col.Name.Value)
 
-		var dt ColumnType
-		switch col.Datatype.Value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		case "boolean":
-			dt = BoolType
-		default:
+		dt, precision, err := datatypeColumnType(col.Datatype, col.Modifiers, col.Array)
+		if err != nil {
 			delete(mb.tables, t.name)
-			return ErrInvalidDatatype
+			return err
 		}
 
 		if col.PrimaryKey {
@@ -740,13 +4517,58 @@ func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
 				return ErrPrimaryKeyAlreadyExists
 			}
 
-			primaryKey = &Expression{
-				Literal: &col.Name,
-				Kind:    LiteralKind,
-			}
+			primaryKey = []*Token{&col.Name}
+		}
+
+		if col.Unique {
+			uniques = append(uniques, []*Token{&col.Name})
 		}
 
 		t.columnTypes = append(t.columnTypes, dt)
+		t.columnPrecisions = append(t.columnPrecisions, precision)
+		t.columnNotNull = append(t.columnNotNull, col.NotNull || col.PrimaryKey)
+		t.columnDefaults = append(t.columnDefaults, col.Default)
+		if col.Check != nil {
+			t.checks = append(t.checks, col.Check)
+		}
+	}
+
+	if crt.Constraints != nil {
+		for _, constraint := range *crt.Constraints {
+			if constraint.Check != nil {
+				t.checks = append(t.checks, constraint.Check)
+			}
+
+			if constraint.Unique != nil {
+				uniques = append(uniques, *constraint.Unique)
+			}
+
+			if constraint.PrimaryKey == nil {
+				continue
+			}
+
+			if primaryKey != nil {
+				delete(mb.tables, t.name)
+				return ErrPrimaryKeyAlreadyExists
+			}
+
+			primaryKey = *constraint.PrimaryKey
+			columns, err := t.columnIndexes(primaryKey)
+			if err != nil {
+				delete(mb.tables, t.name)
+				return err
+			}
+
+			for _, c := range columns {
+				t.columnNotNull[c] = true
+			}
+		}
+	}
+
+	err := t.validateConstraints()
+	if err != nil {
+		delete(mb.tables, t.name)
+		return err
 	}
 
 	if primaryKey != nil {
@@ -755,7 +4577,30 @@ func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
 			Name:       Token{Value: t.name + "_pkey"},
 			Unique:     true,
 			PrimaryKey: true,
-			Exp:        *primaryKey,
+			Exps:       columnExpressions(primaryKey),
+		})
+		if err != nil {
+			delete(mb.tables, t.name)
+			return err
+		}
+	}
+
+	for _, columns := range uniques {
+		if _, err := t.columnIndexes(columns); err != nil {
+			delete(mb.tables, t.name)
+			return err
+		}
+
+		names := []string{t.name}
+		for _, column := range columns {
+			names = append(names, column.Value)
+		}
+
+		err := mb.CreateIndex(&CreateIndexStatement{
+			Table:  crt.Name,
+			Name:   Token{Value: strings.Join(append(names, "key"), "_")},
+			Unique: true,
+			Exps:   columnExpressions(columns),
 		})
 		if err != nil {
 			delete(mb.tables, t.name)
@@ -763,6 +4608,30 @@ func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
 		}
 	}
 
+	// Foreign keys are added last since they can reference the
+	// primary key of the table itself
+	for i, col := range *crt.Cols {
+		if col.References != nil {
+			err := mb.addForeignKey(t, col.References, i)
+			if err != nil {
+				delete(mb.tables, t.name)
+				return err
+			}
+		}
+	}
+
+	if crt.Constraints != nil {
+		for _, constraint := range *crt.Constraints {
+			if constraint.ForeignKey != nil {
+				err := mb.addForeignKey(t, constraint.ForeignKey, -1)
+				if err != nil {
+					delete(mb.tables, t.name)
+					return err
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -778,17 +4647,52 @@ func (mb *MemoryBackend) CreateIndex(ci *CreateIndexStatement) error {
 		}
 	}
 
+	exps := []Expression{}
+	valueTypes := []ColumnType{}
+	for _, exp := range *ci.Exps {
+		_, _, valueType, err := table.withNullRow().evaluateCell(0, *exp)
+		if err != nil {
+			return err
+		}
+
+		exps = append(exps, *exp)
+		valueTypes = append(valueTypes, valueType)
+	}
+
+	typ := btreeIndexType
+	if ci.Method != nil {
+		switch ci.Method.Value {
+		case "btree":
+		case "gin":
+			typ = ginIndexType
+		default:
+			return ErrInvalidIndexMethod
+		}
+	}
+
+	// GIN indexes only find the documents or arrays containing
+	// another
+	if typ == ginIndexType && (ci.Unique || len(exps) != 1 || (valueTypes[0] != JSONBType && !valueTypes[0].IsArray())) {
+		return ErrInvalidIndexMethod
+	}
+
 	index := &index{
-		exp:        ci.Exp,
+		exps:       exps,
 		unique:     ci.Unique,
 		primaryKey: ci.PrimaryKey,
 		name:       ci.Name.Value,
 		tree:       llrb.New(),
-		typ:        "rbtree",
+		typ:        typ,
+		valueTypes: valueTypes,
 	}
 	table.indexes = append(table.indexes, index)
 
-	for i := range table.rows {
+	for i, row := range table.rows {
+		// Skip rows that have been deleted
+		if row == nil {
+			continue
+		}
+
 		err := index.addRow(table, uint(i))
 		if err != nil {
 			return err
@@ -799,7 +4703,13 @@ func (mb *MemoryBackend) CreateIndex(ci *CreateIndexStatement) error {
 }
 
 func (mb *MemoryBackend) DropTable(dt *DropTableStatement) error {
-	if _, ok := mb.tables[dt.Name.Value]; ok {
+	if t, ok := mb.tables[dt.Name.Value]; ok {
+		for _, fk := range mb.referencingKeys(t) {
+			if fk.child != t {
+				return ErrReferencedByForeignKey
+			}
+		}
+
 		delete(mb.tables, dt.Name.Value)
 		return nil
 	}
@@ -812,18 +4722,13 @@ func (mb *MemoryBackend) GetTables() []TableMetadata {
 		tm := TableMetadata{}
 		tm.Name = name
 
-		pkeyColumn := ""
 		for _, i := range t.indexes {
-			if i.primaryKey {
-				pkeyColumn = i.exp.GenerateCode()
-			}
-
 			tm.Indexes = append(tm.Indexes, Index{
 				Name:       i.name,
 				Type:       i.typ,
 				Unique:     i.unique,
 				PrimaryKey: i.primaryKey,
-				Exp:        i.exp.GenerateCode(),
+				Exp:        i.expressionsCode(),
 			})
 		}
 
@@ -831,8 +4736,32 @@ func (mb *MemoryBackend) GetTables() []TableMetadata {
 			tm.Columns = append(tm.Columns, ResultColumn{
 				Type:    t.columnTypes[i],
 				Name:    column,
-				NotNull: pkeyColumn == `"`+column+`"`,
+				NotNull: t.columnNotNull[i],
 			})
+
+			def := ""
+			if exp := t.columnDefaults[i]; exp != nil {
+				def = exp.GenerateCode()
+			}
+			tm.Defaults = append(tm.Defaults, def)
+		}
+
+		for _, check := range t.checks {
+			tm.Checks = append(tm.Checks, check.GenerateCode())
+		}
+
+		for _, fk := range t.foreignKeys {
+			foreignKey := ForeignKey{
+				Name:     fk.name,
+				Table:    fk.parent.name,
+				OnDelete: fk.onDelete,
+				OnUpdate: fk.onUpdate,
+			}
+			for j, c := range fk.columns {
+				foreignKey.Columns = append(foreignKey.Columns, t.columns[c])
+				foreignKey.RefColumns = append(foreignKey.RefColumns, fk.parent.columns[fk.parentColumns[j]])
+			}
+			tm.ForeignKeys = append(tm.ForeignKeys, foreignKey)
 		}
 
 		tms = append(tms, tm)
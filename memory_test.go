@@ -8,106 +8,2290 @@ import (
 
 var mb *MemoryBackend
 
+// execStatement parses and executes a statement that isn't a query
+func execStatement(mb *MemoryBackend, query string) error {
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse(query)
+	if err != nil {
+		return err
+	}
+
+	stmt := ast.Statements[0]
+	switch stmt.Kind {
+	case CreateTableKind:
+		return mb.CreateTable(stmt.CreateTableStatement)
+	case CreateIndexKind:
+		return mb.CreateIndex(stmt.CreateIndexStatement)
+	case InsertKind:
+		return mb.Insert(stmt.InsertStatement)
+	case UpdateKind:
+		return mb.Update(stmt.UpdateStatement)
+	case DeleteKind:
+		return mb.Delete(stmt.DeleteStatement)
+	case DropTableKind:
+		return mb.DropTable(stmt.DropTableStatement)
+	}
+
+	return nil
+}
+
+// runStatements executes setup statements that are all expected to
+// succeed
+func runStatements(t *testing.T, mb *MemoryBackend, queries ...string) {
+	for _, query := range queries {
+		assert.Nil(t, execStatement(mb, query), query)
+	}
+}
+
+// selectStrings runs a query and formats every cell as a string to
+// keep expected results short
+func selectStrings(mb *MemoryBackend, query string) ([][]string, error) {
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := mb.Select(ast.Statements[0].SelectStatement)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, result := range res.Rows {
+		row := []string{}
+		for i, cell := range result {
+			s := "NULL"
+			switch res.Columns[i].Type {
+			case IntType:
+				if v := cell.AsInt(); v != nil {
+					s = fmt.Sprintf("%d", *v)
+				}
+			case TextType:
+				if v := cell.AsText(); v != nil {
+					s = *v
+				}
+			case BoolType:
+				if v := cell.AsBool(); v != nil {
+					s = fmt.Sprintf("%t", *v)
+				}
+			case SmallIntType:
+				if v := cell.AsSmallInt(); v != nil {
+					s = fmt.Sprintf("%d", *v)
+				}
+			case BigIntType:
+				if v := cell.AsBigInt(); v != nil {
+					s = fmt.Sprintf("%d", *v)
+				}
+			case RealType:
+				if v := cell.AsReal(); v != nil {
+					s = formatFloat(float64(*v), 32)
+				}
+			case DoubleType:
+				if v := cell.AsDouble(); v != nil {
+					s = formatFloat(*v, 64)
+				}
+			case NumericType:
+				if v := cell.AsNumeric(); v != nil {
+					s = *v
+				}
+			case DateType:
+				if v := cell.AsDate(); v != nil {
+					s = formatDate(*v)
+				}
+			case TimeType:
+				if v := cell.AsTime(); v != nil {
+					s = formatTime(*v)
+				}
+			case TimestampType:
+				if v := cell.AsTimestamp(); v != nil {
+					s = formatTimestamp(*v)
+				}
+			case IntervalType:
+				if v := cell.AsInterval(); v != nil {
+					s = v.String()
+				}
+			case ByteaType:
+				if v := cell.AsBytea(); v != nil {
+					s = formatBytea(*v)
+				}
+			case UUIDType:
+				if v := cell.AsUUID(); v != nil {
+					s = *v
+				}
+			case JSONType, JSONBType:
+				if v := cell.AsJSON(); v != nil {
+					s = *v
+				}
+			default:
+				if v := cell.AsArray(); v != nil {
+					s = arrayToText(cell.(memoryCell), res.Columns[i].Type)
+				}
+			}
+			row = append(row, s)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
 func TestSelect(t *testing.T) {
 	mb = NewMemoryBackend()
 
-	parser := Parser{HelpMessagesDisabled: true}
-	ast, err := parser.Parse("SELECT * FROM test")
-	assert.Nil(t, err)
-	assert.NotEqual(t, ast, nil)
-	_, err = mb.Select(ast.Statements[0].SelectStatement)
-	assert.Equal(t, err, ErrTableDoesNotExist)
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("SELECT * FROM test")
+	assert.Nil(t, err)
+	assert.NotEqual(t, ast, nil)
+	_, err = mb.Select(ast.Statements[0].SelectStatement)
+	assert.Equal(t, err, ErrTableDoesNotExist)
+
+	ast, err = parser.Parse("CREATE TABLE test(x INT, y INT, z BOOLEAN);")
+	assert.Nil(t, err)
+	assert.NotEqual(t, ast, nil)
+	err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+	assert.Nil(t, err)
+
+	ast, err = parser.Parse("INSERT INTO test VALUES(100, 200, true)")
+	assert.Nil(t, err)
+	assert.NotEqual(t, ast, nil)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Nil(t, err)
+
+	Value100 := literalToMemoryCell(&Token{"100", NumericKind, Location{}})
+	Value200 := literalToMemoryCell(&Token{"200", NumericKind, Location{}})
+	xCol := ResultColumn{IntType, "x", false}
+	yCol := ResultColumn{IntType, "y", false}
+	zCol := ResultColumn{BoolType, "z", false}
+
+	tests := []struct {
+		query   string
+		results Results
+	}{
+		{
+			"SELECT * FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol, zCol},
+				[][]Cell{{Value100, Value200, trueMemoryCell}},
+			},
+		},
+		{
+			"SELECT x FROM test",
+			Results{
+				[]ResultColumn{xCol},
+				[][]Cell{{Value100}},
+			},
+		},
+		{
+			"SELECT x, y FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol},
+				[][]Cell{{Value100, Value200}},
+			},
+		},
+		{
+			"SELECT x, y, z FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol, zCol},
+				[][]Cell{{Value100, Value200, trueMemoryCell}},
+			},
+		},
+		{
+			"SELECT *, x FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol, zCol, xCol},
+				[][]Cell{{Value100, Value200, trueMemoryCell, Value100}},
+			},
+		},
+		{
+			"SELECT *, x, y FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol, zCol, xCol, yCol},
+				[][]Cell{{Value100, Value200, trueMemoryCell, Value100, Value200}},
+			},
+		},
+		{
+			"SELECT *, x, y, z FROM test",
+			Results{
+				[]ResultColumn{xCol, yCol, zCol, xCol, yCol, zCol},
+				[][]Cell{{Value100, Value200, trueMemoryCell, Value100, Value200, trueMemoryCell}},
+			},
+		},
+		{
+			"SELECT x, *, z FROM test",
+			Results{
+				[]ResultColumn{xCol, xCol, yCol, zCol, zCol},
+				[][]Cell{{Value100, Value100, Value200, trueMemoryCell, trueMemoryCell}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		ast, err = parser.Parse(test.query)
+		assert.Nil(t, err)
+		assert.NotEqual(t, ast, nil)
+
+		var res *Results
+		res, err = mb.Select(ast.Statements[0].SelectStatement)
+		assert.Nil(t, err)
+		assert.Equal(t, *res, test.results)
+	}
+}
+
+func TestSelect_Join(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (uid INT, name TEXT);",
+		"CREATE TABLE purchases (buyer INT, item TEXT);",
+		"CREATE TABLE colors (color TEXT);",
+		"INSERT INTO users VALUES (1, 'Kate')",
+		"INSERT INTO users VALUES (2, 'Ali')",
+		"INSERT INTO users VALUES (3, 'Sam')",
+		"INSERT INTO purchases VALUES (1, 'pen')",
+		"INSERT INTO purchases VALUES (1, 'ink')",
+		"INSERT INTO purchases VALUES (2, 'pad')",
+		"INSERT INTO purchases VALUES (4, 'cup')",
+		"INSERT INTO colors VALUES ('red')",
+		"INSERT INTO colors VALUES ('blue')",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+	}{
+		{
+			"SELECT name, item FROM users JOIN purchases ON uid = buyer",
+			[][]string{{"Kate", "pen"}, {"Kate", "ink"}, {"Ali", "pad"}},
+		},
+		{
+			"SELECT name, item FROM users INNER JOIN purchases ON uid = buyer WHERE item <> 'pen'",
+			[][]string{{"Kate", "ink"}, {"Ali", "pad"}},
+		},
+		{
+			"SELECT name, item FROM users LEFT JOIN purchases ON uid = buyer",
+			[][]string{{"Kate", "pen"}, {"Kate", "ink"}, {"Ali", "pad"}, {"Sam", "NULL"}},
+		},
+		{
+			"SELECT name, item FROM users RIGHT OUTER JOIN purchases ON uid = buyer",
+			[][]string{{"Kate", "pen"}, {"Kate", "ink"}, {"Ali", "pad"}, {"NULL", "cup"}},
+		},
+		{
+			"SELECT uid, buyer FROM users FULL JOIN purchases ON uid = buyer AND item = 'pad'",
+			[][]string{{"1", "NULL"}, {"2", "2"}, {"3", "NULL"}, {"NULL", "1"}, {"NULL", "1"}, {"NULL", "4"}},
+		},
+		{
+			"SELECT name, color FROM users, colors WHERE uid = 1",
+			[][]string{{"Kate", "red"}, {"Kate", "blue"}},
+		},
+		{
+			"SELECT * FROM users CROSS JOIN colors LIMIT 1",
+			[][]string{{"1", "Kate", "red"}},
+		},
+		{
+			"SELECT name, item, color FROM users JOIN purchases ON uid = buyer JOIN colors ON color = 'red' WHERE name = 'Ali'",
+			[][]string{{"Ali", "pad", "red"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	_, err := selectStrings(mb, "SELECT * FROM users JOIN missing ON uid = 1")
+	assert.Equal(t, ErrTableDoesNotExist, err)
+}
+
+func TestSelect_QualifiedColumns(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (id INT, name TEXT, manager INT);",
+		"CREATE TABLE purchases (id INT, buyer INT);",
+		"INSERT INTO users VALUES (1, 'Kate', 2)",
+		"INSERT INTO users VALUES (2, 'Ali', 2)",
+		"INSERT INTO purchases VALUES (10, 1)",
+		"INSERT INTO purchases VALUES (11, 2)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT users.id, name FROM users WHERE users.id = 1",
+			rows:  [][]string{{"1", "Kate"}},
+		},
+		{
+			query: "SELECT u.id, p.id FROM users u JOIN purchases AS p ON u.id = p.buyer",
+			rows:  [][]string{{"1", "10"}, {"2", "11"}},
+		},
+		{
+			query: "SELECT * FROM users u JOIN purchases p ON u.id = buyer WHERE p.id = 11",
+			rows:  [][]string{{"2", "Ali", "2", "11", "2"}},
+		},
+		{
+			query: "SELECT e.name, m.name FROM users e JOIN users m ON e.manager = m.id",
+			rows:  [][]string{{"Kate", "Ali"}, {"Ali", "Ali"}},
+		},
+		{
+			query: "SELECT id FROM users JOIN purchases ON users.id = buyer",
+			err:   ErrAmbiguousColumn,
+		},
+		{
+			query: "SELECT users.id FROM users u",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			query: "SELECT p.name FROM users u JOIN purchases p ON true",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_GroupBy(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE sales (id INT, region TEXT, amount INT);",
+		"CREATE TABLE empty (id INT, region TEXT);",
+		"INSERT INTO sales VALUES (1, 'east', 10)",
+		"INSERT INTO sales VALUES (2, 'west', 5)",
+		"INSERT INTO sales VALUES (3, 'east', 20)",
+		"INSERT INTO sales VALUES (4, 'east', null)",
+		"INSERT INTO sales VALUES (5, null, 7)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT count(*), count(amount), sum(amount), min(amount), max(amount), avg(amount) FROM sales",
+			rows:  [][]string{{"5", "4", "42", "5", "20", "10.5000000000000000"}},
+		},
+		{
+			query: "SELECT region, count(*), sum(amount) FROM sales GROUP BY region",
+			rows:  [][]string{{"east", "3", "30"}, {"west", "1", "5"}, {"NULL", "1", "7"}},
+		},
+		{
+			query: "SELECT sales.region, max(id) FROM sales WHERE id > 1 GROUP BY region HAVING count(*) > 1",
+			rows:  [][]string{{"east", "4"}},
+		},
+		{
+			query: "SELECT count(*) + 1, min(region) FROM sales WHERE amount > 5",
+			rows:  [][]string{{"4", "east"}},
+		},
+		{
+			query: "SELECT region FROM sales GROUP BY region HAVING max(amount) > 6 LIMIT 1 OFFSET 1",
+			rows:  [][]string{{"NULL"}},
+		},
+		{
+			query: "SELECT count(*), max(region), sum(id) FROM empty",
+			rows:  [][]string{{"0", "NULL", "NULL"}},
+		},
+		{
+			query: "SELECT region, count(*) FROM empty GROUP BY region",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT count(DISTINCT region), sum(DISTINCT amount % 2), count(DISTINCT amount > 6), array_agg(DISTINCT region) FROM sales",
+			rows:  [][]string{{"2", "1", "2", "{east,west,NULL}"}},
+		},
+		{
+			query: "SELECT region, count(*) FROM sales GROUP BY 1",
+			rows:  [][]string{{"east", "3"}, {"west", "1"}, {"NULL", "1"}},
+		},
+		{
+			query: "SELECT amount > 6 AS big, count(*) FROM sales GROUP BY big ORDER BY 1",
+			rows:  [][]string{{"false", "1"}, {"true", "3"}, {"NULL", "1"}},
+		},
+		{
+			query: "SELECT region AS id, count(*) FROM sales GROUP BY id",
+			err:   ErrColumnNotGrouped,
+		},
+		{
+			query: "SELECT region, count(*) FROM sales GROUP BY 3",
+			err:   ErrInvalidGroupByPosition,
+		},
+		{
+			query: "SELECT region, count(*) FROM sales GROUP BY 2",
+			err:   ErrAggregateNotAllowed,
+		},
+		{
+			query: "SELECT lower(DISTINCT region) FROM sales",
+			err:   ErrInvalidArguments,
+		},
+		{
+			query: "SELECT id, count(*) FROM sales GROUP BY region",
+			err:   ErrColumnNotGrouped,
+		},
+		{
+			query: "SELECT id FROM sales WHERE count(*) > 1",
+			err:   ErrAggregateNotAllowed,
+		},
+		{
+			query: "SELECT sum(region) FROM sales",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT max(count(*)) FROM sales",
+			err:   ErrAggregateNotAllowed,
+		},
+		{
+			query: "SELECT foo(id) FROM sales",
+			err:   ErrFunctionDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("SELECT count(*) AS n, max(region) FROM empty")
+	assert.Nil(t, err)
+	res, err := mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{Type: IntType, Name: "n"}, {Type: TextType, Name: "max"}}, res.Columns)
+}
+
+func TestSelect_OrderBy(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (id INT, name TEXT, age INT);",
+		"CREATE UNIQUE INDEX name_idx ON users (name);",
+		"INSERT INTO users VALUES (1, 'Kate', 30)",
+		"INSERT INTO users VALUES (2, 'Ali', null)",
+		"INSERT INTO users VALUES (3, 'Zoe', 25)",
+		"INSERT INTO users VALUES (4, 'Bob', 30)",
+		"INSERT INTO users VALUES (0, 'Eve', 41)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id FROM users ORDER BY id",
+			rows:  [][]string{{"0"}, {"1"}, {"2"}, {"3"}, {"4"}},
+		},
+		{
+			query: "SELECT name FROM users ORDER BY name DESC",
+			rows:  [][]string{{"Zoe"}, {"Kate"}, {"Eve"}, {"Bob"}, {"Ali"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id > 1 ORDER BY name",
+			rows:  [][]string{{"Ali"}, {"Bob"}, {"Zoe"}},
+		},
+		{
+			query: "SELECT id, age FROM users ORDER BY age",
+			rows:  [][]string{{"3", "25"}, {"1", "30"}, {"4", "30"}, {"0", "41"}, {"2", "NULL"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY age DESC, id DESC",
+			rows:  [][]string{{"2"}, {"0"}, {"4"}, {"1"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY age ASC NULLS FIRST, name",
+			rows:  [][]string{{"2"}, {"3"}, {"4"}, {"1"}, {"0"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY age DESC NULLS LAST LIMIT 2 OFFSET 1",
+			rows:  [][]string{{"1"}, {"4"}},
+		},
+		{
+			query: "SELECT id AS n, name FROM users ORDER BY n DESC LIMIT 2",
+			rows:  [][]string{{"4", "Bob"}, {"3", "Zoe"}},
+		},
+		{
+			query: "SELECT name, id FROM users ORDER BY 2 LIMIT 1",
+			rows:  [][]string{{"Eve", "0"}},
+		},
+		{
+			query: "SELECT age FROM users GROUP BY age ORDER BY count(*) DESC, age",
+			rows:  [][]string{{"30"}, {"25"}, {"41"}, {"NULL"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY 3",
+			err:   ErrInvalidOrderByPosition,
+		},
+		{
+			query: "SELECT id FROM users ORDER BY height",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	users := mb.tables["users"]
+	name := Expression{Literal: &Token{Value: "name", Kind: IdentifierKind}, Kind: LiteralKind}
+	assert.Equal(t, "name_idx", users.orderedIndex(OrderByItem{Exp: &name}).name)
+	age := Expression{Literal: &Token{Value: "age", Kind: IdentifierKind}, Kind: LiteralKind}
+	assert.Nil(t, users.orderedIndex(OrderByItem{Exp: &age}))
+}
+
+func TestSelect_Distinct(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE visits (id INT, page TEXT, visitor TEXT);",
+		"INSERT INTO visits VALUES (1, 'home', 'kate')",
+		"INSERT INTO visits VALUES (2, 'about', 'ali')",
+		"INSERT INTO visits VALUES (3, 'home', 'ali')",
+		"INSERT INTO visits VALUES (4, 'home', 'kate')",
+		"INSERT INTO visits VALUES (5, null, 'kate')",
+		"INSERT INTO visits VALUES (6, null, 'bob')",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+	}{
+		{
+			query: "SELECT DISTINCT page FROM visits",
+			rows:  [][]string{{"home"}, {"about"}, {"NULL"}},
+		},
+		{
+			query: "SELECT DISTINCT page, visitor FROM visits WHERE page = 'home'",
+			rows:  [][]string{{"home", "kate"}, {"home", "ali"}},
+		},
+		{
+			query: "SELECT DISTINCT visitor FROM visits ORDER BY visitor LIMIT 2 OFFSET 1",
+			rows:  [][]string{{"bob"}, {"kate"}},
+		},
+		{
+			query: "SELECT DISTINCT ON (visitor) visitor, id FROM visits ORDER BY visitor, id DESC",
+			rows:  [][]string{{"ali", "3"}, {"bob", "6"}, {"kate", "5"}},
+		},
+		{
+			query: "SELECT DISTINCT count(*) FROM visits GROUP BY visitor",
+			rows:  [][]string{{"3"}, {"2"}, {"1"}},
+		},
+		{
+			query: "SELECT DISTINCT * FROM visits WHERE id < 3 ORDER BY visits.id DESC",
+			rows:  [][]string{{"2", "about", "ali"}, {"1", "home", "kate"}},
+		},
+		{
+			query: "SELECT DISTINCT visitor AS v FROM visits ORDER BY v DESC",
+			rows:  [][]string{{"kate"}, {"bob"}, {"ali"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+	}
+
+	for _, test := range []struct {
+		query string
+		err   error
+	}{
+		{"SELECT DISTINCT page FROM visits ORDER BY id", ErrDistinctOrderBy},
+		{"SELECT DISTINCT page FROM visits ORDER BY page, visitor", ErrDistinctOrderBy},
+		{"SELECT DISTINCT ON (visitor) visitor, id FROM visits ORDER BY id", ErrDistinctOnOrderBy},
+		{"SELECT DISTINCT ON (visitor, page) visitor FROM visits ORDER BY visitor, id", ErrDistinctOnOrderBy},
+	} {
+		_, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+	}
+}
+
+func TestSelect_SetOperations(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE a (x INT, y TEXT);",
+		"CREATE TABLE b (x INT, y TEXT);",
+		"INSERT INTO a VALUES (1, 'one')",
+		"INSERT INTO a VALUES (2, 'two')",
+		"INSERT INTO a VALUES (2, 'two')",
+		"INSERT INTO a VALUES (3, null)",
+		"INSERT INTO b VALUES (2, 'two')",
+		"INSERT INTO b VALUES (3, null)",
+		"INSERT INTO b VALUES (4, 'four')",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT x, y FROM a UNION SELECT x, y FROM b",
+			rows:  [][]string{{"1", "one"}, {"2", "two"}, {"3", "NULL"}, {"4", "four"}},
+		},
+		{
+			query: "SELECT x FROM a UNION ALL SELECT x FROM b ORDER BY x DESC LIMIT 3",
+			rows:  [][]string{{"4"}, {"3"}, {"3"}},
+		},
+		{
+			query: "SELECT x, y FROM a INTERSECT SELECT x, y FROM b",
+			rows:  [][]string{{"2", "two"}, {"3", "NULL"}},
+		},
+		{
+			query: "SELECT x FROM a INTERSECT ALL SELECT x FROM b UNION ALL SELECT x FROM b",
+			rows:  [][]string{{"2"}, {"3"}, {"2"}, {"3"}, {"4"}},
+		},
+		{
+			query: "SELECT x FROM a EXCEPT SELECT x FROM b",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT x FROM a EXCEPT ALL SELECT x FROM b ORDER BY 1",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT 1 UNION SELECT 2 INTERSECT SELECT 2",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT x AS n FROM a UNION SELECT x FROM b ORDER BY n DESC OFFSET 2",
+			rows:  [][]string{{"2"}, {"1"}},
+		},
+		{
+			query: "SELECT y FROM a UNION SELECT NULL ORDER BY 1",
+			rows:  [][]string{{"one"}, {"two"}, {"NULL"}},
+		},
+		{
+			query: "SELECT NULL UNION ALL SELECT NULL UNION ALL SELECT 'a'",
+			rows:  [][]string{{"NULL"}, {"NULL"}, {"a"}},
+		},
+		{
+			query: "SELECT 1::bigint UNION SELECT 2 UNION SELECT 2.5 ORDER BY 1",
+			rows:  [][]string{{"1"}, {"2"}, {"2.5"}},
+		},
+		{
+			query: "SELECT 1 INTERSECT SELECT 1.0",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT 'a' UNION SELECT 1",
+			err:   ErrSetOperationColumnType,
+		},
+		{
+			query: "SELECT x, y FROM a UNION SELECT x FROM b",
+			err:   ErrSetOperationColumnCount,
+		},
+		{
+			query: "SELECT x FROM a UNION SELECT y FROM b WHERE x > 10",
+			err:   ErrSetOperationColumnType,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_Subqueries(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT);",
+		"CREATE TABLE orders (id INT PRIMARY KEY, user_id INT, total INT);",
+		"INSERT INTO users VALUES (1, 'Kate')",
+		"INSERT INTO users VALUES (2, 'Ali')",
+		"INSERT INTO users VALUES (3, 'Bob')",
+		"INSERT INTO orders VALUES (10, 1, 5)",
+		"INSERT INTO orders VALUES (11, 1, 20)",
+		"INSERT INTO orders VALUES (12, 2, 15)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT name, (SELECT count(*) FROM orders WHERE user_id = users.id) FROM users",
+			rows:  [][]string{{"Kate", "2"}, {"Ali", "1"}, {"Bob", "0"}},
+		},
+		{
+			query: "SELECT (SELECT max(total) FROM orders), (SELECT total FROM orders WHERE id = 99)",
+			rows:  [][]string{{"20", "NULL"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > 10)",
+			rows:  [][]string{{"Kate"}, {"Ali"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM orders)",
+			rows:  [][]string{{"Bob"}},
+		},
+		{
+			query: "SELECT name FROM users u WHERE EXISTS (SELECT id FROM orders o WHERE o.user_id = u.id AND total < 10)",
+			rows:  [][]string{{"Kate"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE (SELECT sum(total) FROM orders WHERE user_id = id) > 10",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT name FROM users WHERE (SELECT sum(total) FROM orders WHERE user_id = users.id) > 10",
+			rows:  [][]string{{"Kate"}, {"Ali"}},
+		},
+		{
+			query: "SELECT u.name, count(*) FROM users u JOIN orders ON u.id = user_id GROUP BY u.name HAVING count(*) = (SELECT count(*) FROM orders WHERE user_id = 2)",
+			rows:  [][]string{{"Ali", "1"}},
+		},
+		{
+			query: "SELECT (SELECT id FROM orders)",
+			err:   ErrSubqueryTooManyRows,
+		},
+		{
+			query: "SELECT name FROM users WHERE id IN (SELECT id, total FROM orders)",
+			err:   ErrSubqueryColumnCount,
+		},
+		{
+			query: "SELECT name FROM users WHERE name IN (SELECT id FROM orders)",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT (SELECT missing FROM orders WHERE id = 10)",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	// A NULL in the subquery means NOT IN can't be true
+	runStatements(t, mb, "INSERT INTO orders VALUES (13, null, 1)")
+	rows, err := selectStrings(mb, "SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM orders)")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{}, rows)
+
+	runStatements(t, mb, "DELETE FROM orders WHERE user_id IN (SELECT id FROM users WHERE name = 'Kate')")
+	rows, err = selectStrings(mb, "SELECT id FROM orders")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"12"}, {"13"}}, rows)
+}
+
+func TestSelect_With(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE employees (id INT PRIMARY KEY, name TEXT, manager_id INT);",
+		"CREATE TABLE edges (src INT, dst INT);",
+		"INSERT INTO employees VALUES (1, 'Kate', null)",
+		"INSERT INTO employees VALUES (2, 'Ali', 1)",
+		"INSERT INTO employees VALUES (3, 'Bob', 1)",
+		"INSERT INTO employees VALUES (4, 'Eve', 3)",
+		"INSERT INTO employees VALUES (5, 'Sam', 4)",
+		"INSERT INTO edges VALUES (1, 2)",
+		"INSERT INTO edges VALUES (2, 3)",
+		"INSERT INTO edges VALUES (3, 1)",
+		"INSERT INTO edges VALUES (4, 5)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "WITH reports AS (SELECT id, name FROM employees WHERE manager_id = 1) SELECT name FROM reports ORDER BY name",
+			rows:  [][]string{{"Ali"}, {"Bob"}},
+		},
+		{
+			query: "WITH a AS (SELECT id FROM employees WHERE id > 2), b (n) AS (SELECT id FROM a WHERE id < 5) SELECT b.n, e.name FROM b JOIN employees e ON e.id = n",
+			rows:  [][]string{{"3", "Bob"}, {"4", "Eve"}},
+		},
+		{
+			query: "WITH employees AS (SELECT 1 AS id) SELECT id FROM employees",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "WITH bosses AS (SELECT manager_id FROM employees) SELECT name FROM employees WHERE id IN (SELECT manager_id FROM bosses)",
+			rows:  [][]string{{"Kate"}, {"Bob"}, {"Eve"}},
+		},
+		{
+			query: "WITH RECURSIVE t (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 5) SELECT count(*), sum(n) FROM t",
+			rows:  [][]string{{"5", "15"}},
+		},
+		{
+			query: "WITH RECURSIVE chain (id, name, depth) AS (SELECT id, name, 0 FROM employees WHERE manager_id = 3 UNION ALL SELECT e.id, e.name, depth + 1 FROM employees e JOIN chain c ON e.manager_id = c.id) SELECT name, depth FROM chain",
+			rows:  [][]string{{"Eve", "0"}, {"Sam", "1"}},
+		},
+		{
+			query: "WITH RECURSIVE reach (node) AS (SELECT 1 UNION SELECT dst FROM edges JOIN reach ON src = node) SELECT node FROM reach ORDER BY node",
+			rows:  [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			query: "WITH t (a, b) AS (SELECT id FROM employees) SELECT a FROM t",
+			err:   ErrCTEColumnCount,
+		},
+		{
+			query: "WITH RECURSIVE t (n) AS (SELECT 1 UNION ALL SELECT 'a' FROM t) SELECT n FROM t",
+			err:   ErrSetOperationColumnType,
+		},
+		{
+			query: "WITH x AS (SELECT 1), x AS (SELECT 2) SELECT * FROM x",
+			err:   ErrDuplicateCTE,
+		},
+		{
+			query: "WITH RECURSIVE r AS (SELECT 1 AS k UNION ALL SELECT k + 1 FROM r) SELECT k FROM r LIMIT 3",
+			err:   ErrRecursionLimit,
+		},
+		{
+			query: "SELECT id FROM reports",
+			err:   ErrTableDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_Arithmetic(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE nums (a INT, b INT);",
+		"INSERT INTO nums VALUES (7, 2)",
+		"INSERT INTO nums VALUES (-7, 0)",
+		"INSERT INTO nums VALUES (2147483647, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT a - b, a * b, a / b, a % b FROM nums WHERE a = 7",
+			rows:  [][]string{{"5", "14", "3", "1"}},
+		},
+		{
+			query: "SELECT 10 - 2 - 3, 2 + 3 * 4, (2 + 3) * 4, 7 / 2 * 2, -2 * -3",
+			rows:  [][]string{{"5", "14", "20", "6", "6"}},
+		},
+		{
+			query: "SELECT -a, a / 2, a % 3 FROM nums WHERE b = 0",
+			rows:  [][]string{{"7", "-3", "-1"}},
+		},
+		{
+			query: "SELECT -(-5), - -5, -(-(-5)), -(-2.5)",
+			rows:  [][]string{{"5", "5", "-5", "2.5"}},
+		},
+		{
+			query: "SELECT a FROM nums WHERE a < -(-2)",
+			rows:  [][]string{{"-7"}},
+		},
+		{
+			query: "SELECT a + b, -b FROM nums WHERE a > 7",
+			rows:  [][]string{{"NULL", "NULL"}},
+		},
+		{
+			query: "SELECT a FROM nums WHERE NOT a = 7",
+			rows:  [][]string{{"-7"}, {"2147483647"}},
+		},
+		{
+			query: "SELECT NOT b = 2, NOT NOT true FROM nums",
+			rows:  [][]string{{"false", "true"}, {"true", "true"}, {"NULL", "true"}},
+		},
+		{
+			query: "SELECT b = 1 AND false, b = 1 OR true, b = 1 AND true FROM nums WHERE a > 7",
+			rows:  [][]string{{"false", "true", "NULL"}},
+		},
+		{
+			query: "SELECT a FROM nums WHERE a = 7 OR a = -7 AND b = 2",
+			rows:  [][]string{{"7"}},
+		},
+		{
+			query: "SELECT a / b FROM nums WHERE b = 0",
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: "SELECT a % b FROM nums WHERE b = 0",
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: "SELECT a + 1 FROM nums WHERE a > 7",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: "SELECT sum(a) FROM nums WHERE a > 0",
+			rows:  [][]string{{"2147483654"}},
+		},
+		{
+			query: "SELECT -'a'",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT NOT 1",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_IsNull(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE people (id INT PRIMARY KEY, name TEXT, age INT);",
+		"INSERT INTO people VALUES (1, 'Kate', 30)",
+		"INSERT INTO people VALUES (2, null, 25)",
+		"INSERT INTO people VALUES (3, '', null)",
+		"INSERT INTO people VALUES (4, 'Kate', null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id FROM people WHERE name IS NULL",
+			rows:  [][]string{{"2"}},
+		},
+		{
+			query: "SELECT id FROM people WHERE age IS NOT NULL AND name IS NOT NULL",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT id, name = '' FROM people WHERE age = null OR id > 2",
+			rows:  [][]string{{"3", "true"}, {"4", "false"}},
+		},
+		{
+			query: "SELECT id FROM people WHERE age IS DISTINCT FROM 30",
+			rows:  [][]string{{"2"}, {"3"}, {"4"}},
+		},
+		{
+			query: "SELECT p.id, q.id FROM people p JOIN people q ON p.age IS NOT DISTINCT FROM q.age AND p.id < q.id",
+			rows:  [][]string{{"3", "4"}},
+		},
+		{
+			query: "SELECT null IS NULL, 1 IS NULL, name IS DISTINCT FROM 'Kate' FROM people WHERE id = 2",
+			rows:  [][]string{{"true", "false", "true"}},
+		},
+		{
+			query: "SELECT count(*) FROM people WHERE NOT age > 26",
+			rows:  [][]string{{"1"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	runStatements(t, mb,
+		"UPDATE people SET age = 0 WHERE age IS NULL",
+		"DELETE FROM people WHERE name IS NULL",
+	)
+
+	rows, err := selectStrings(mb, "SELECT id, age FROM people")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"1", "30"}, {"3", "0"}, {"4", "0"}}, rows)
+}
+
+func TestSelect_Like(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE files (id INT PRIMARY KEY, path TEXT);",
+		"CREATE INDEX path_idx ON files (path);",
+		"INSERT INTO files VALUES (1, 'src/main.go')",
+		"INSERT INTO files VALUES (2, 'src/main_test.go')",
+		"INSERT INTO files VALUES (3, 'README.md')",
+		"INSERT INTO files VALUES (4, 'src%/odd.go')",
+		"INSERT INTO files VALUES (5, 'docs/Readme.txt')",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'src/%'",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'src/main_.go' OR path LIKE '%.md'",
+			rows:  [][]string{{"3"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path NOT LIKE '%.go'",
+			rows:  [][]string{{"3"}, {"5"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path ILIKE '%readme%'",
+			rows:  [][]string{{"3"}, {"5"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'src\\%%'",
+			rows:  [][]string{{"4"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'src!%%' ESCAPE '!'",
+			rows:  [][]string{{"4"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path ~ '^src/[a-z]+\\.go$'",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path ~* 'readme' AND id > 3",
+			rows:  [][]string{{"5"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path !~ '^src/' AND path !~* 'readme'",
+			rows:  [][]string{{"4"}},
+		},
+		{
+			query: "SELECT null !~ 'a', 'a' !~* null, 'A' !~* 'a', 'A' !~ 'a'",
+			rows:  [][]string{{"NULL", "NULL", "false", "true"}},
+		},
+		{
+			query: "SELECT null LIKE 'a', 'a' LIKE null, 'a' LIKE 'a' ESCAPE ''",
+			rows:  [][]string{{"NULL", "NULL", "true"}},
+		},
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'a' ESCAPE 'ab'",
+			err:   ErrInvalidEscape,
+		},
+		{
+			query: "SELECT id FROM files WHERE path LIKE 'a!' ESCAPE '!'",
+			err:   ErrInvalidEscape,
+		},
+		{
+			query: "SELECT id FROM files WHERE path ~ '('",
+			err:   ErrInvalidRegularExpression,
+		},
+		{
+			query: "SELECT id FROM files WHERE path !~* '('",
+			err:   ErrInvalidRegularExpression,
+		},
+		{
+			query: "SELECT 1 LIKE '1'",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	// Only rows sharing the prefix are scanned
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("SELECT id FROM files WHERE path LIKE 'src/main%.go'")
+	assert.Nil(t, err)
+	files := mb.tables["files"]
+	iAndEs := files.getApplicableIndexes(ast.Statements[0].SelectStatement.Where)
+	assert.Equal(t, 1, len(iAndEs))
+	rowIndexes, ok := iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+	assert.True(t, ok)
+	assert.Equal(t, []uint{0, 1}, rowIndexes)
+}
+
+func TestSelect_InBetween(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE items (id INT PRIMARY KEY, name TEXT, price INT);",
+		"CREATE INDEX name_idx ON items (name);",
+		"INSERT INTO items VALUES (1, 'apple', 3)",
+		"INSERT INTO items VALUES (2, 'pear', 5)",
+		"INSERT INTO items VALUES (3, 'fig', null)",
+		"INSERT INTO items VALUES (4, 'kiwi', 8)",
+		"INSERT INTO items VALUES (5, 'lime', -2)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT name FROM items WHERE id IN (4, 1, 4, 9)",
+			rows:  [][]string{{"apple"}, {"kiwi"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE name IN ('fig', 'lime', null) AND id > 3",
+			rows:  [][]string{{"5"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE price NOT IN (3, 5)",
+			rows:  [][]string{{"4"}, {"5"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE price NOT IN (3, null)",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM items WHERE id IN (price - 2, 5 - 2)",
+			rows:  [][]string{{"1"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE price BETWEEN 3 AND 5 AND id BETWEEN 1 AND 4",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE id BETWEEN -1 AND 2",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE id BETWEEN 4 AND 2",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT name FROM items WHERE name BETWEEN 'fig' AND 'lime'",
+			rows:  [][]string{{"fig"}, {"kiwi"}, {"lime"}},
+		},
+		{
+			query: "SELECT name FROM items WHERE name > 'fig' AND name <= 'lime'",
+			rows:  [][]string{{"kiwi"}, {"lime"}},
+		},
+		{
+			query: "SELECT name FROM items WHERE 'kiwi' < name ORDER BY name",
+			rows:  [][]string{{"lime"}, {"pear"}},
+		},
+		{
+			query: "SELECT name FROM items WHERE name < ALL(ARRAY['fig', 'c'])",
+			rows:  [][]string{{"apple"}},
+		},
+		{
+			query: "SELECT 'a' < 'b', 'b' >= 'ba', true > false",
+			rows:  [][]string{{"true", "false", "true"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE price NOT BETWEEN 0 AND 5",
+			rows:  [][]string{{"4"}, {"5"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE id <> 2 AND id < 4",
+			rows:  [][]string{{"1"}, {"3"}},
+		},
+		{
+			query: "SELECT 1 BETWEEN null AND 0, 1 BETWEEN null AND 2, null IN (1), 1 IN (null, 1)",
+			rows:  [][]string{{"false", "NULL", "NULL", "true"}},
+		},
+		{
+			query: "SELECT id FROM items WHERE price IN ('a')",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT id FROM items WHERE name BETWEEN 1 AND 'z'",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_Conditionals(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE scores (id INT PRIMARY KEY, name TEXT, score INT, bonus INT);",
+		"INSERT INTO scores VALUES (1, 'Kate', 90, 5)",
+		"INSERT INTO scores VALUES (2, null, 40, null)",
+		"INSERT INTO scores VALUES (3, 'Bob', null, 10)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id, CASE WHEN score >= 50 THEN 'pass' WHEN score < 50 THEN 'fail' END FROM scores",
+			rows:  [][]string{{"1", "pass"}, {"2", "fail"}, {"3", "NULL"}},
+		},
+		{
+			query: "SELECT CASE id WHEN 1 THEN 'one' WHEN 2 THEN null ELSE 'many' END FROM scores",
+			rows:  [][]string{{"one"}, {"NULL"}, {"many"}},
+		},
+		{
+			query: "SELECT CASE WHEN id = 2 THEN 0 ELSE 100 / (id - 2) END FROM scores",
+			rows:  [][]string{{"-100"}, {"0"}, {"100"}},
+		},
+		{
+			query: "SELECT COALESCE(name, 'anonymous'), COALESCE(bonus, score, 0) FROM scores",
+			rows:  [][]string{{"Kate", "5"}, {"anonymous", "40"}, {"Bob", "10"}},
+		},
+		{
+			query: "SELECT NULLIF(score, 40), NULLIF(name, 'Kate') FROM scores",
+			rows:  [][]string{{"90", "NULL"}, {"NULL", "NULL"}, {"NULL", "Bob"}},
+		},
+		{
+			query: "SELECT GREATEST(score, bonus), LEAST(score, bonus, 7), GREATEST(name, 'Carl') FROM scores",
+			rows:  [][]string{{"90", "5", "Kate"}, {"40", "7", "Carl"}, {"10", "7", "Carl"}},
+		},
+		{
+			query: "SELECT sum(CASE WHEN score > 50 THEN 1 ELSE 0 END), COALESCE(max(bonus), 0) FROM scores",
+			rows:  [][]string{{"1", "10"}},
+		},
+		{
+			query: "SELECT id FROM scores WHERE COALESCE(score, 0) < 50",
+			rows:  [][]string{{"2"}, {"3"}},
+		},
+		{
+			query: "SELECT CASE WHEN id = 1 THEN 'a' ELSE 1 END FROM scores",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT CASE WHEN id THEN 1 END FROM scores",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT COALESCE(name, id) FROM scores",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	// The type comes from every branch, not just the one taken by
+	// the first row
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("SELECT CASE WHEN id = 1 THEN null ELSE name END, COALESCE(null, name) FROM scores")
+	assert.Nil(t, err)
+	res, err := mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, []ResultColumn{{Type: TextType, Name: "case"}, {Type: TextType, Name: "coalesce"}}, res.Columns)
+}
+
+func TestSelect_Functions(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT, score INT);",
+		"INSERT INTO users VALUES (1, '  Kate Smith ', -17)",
+		"INSERT INTO users VALUES (2, null, 2150)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT upper(trim(name)), lower(name), length(name), ltrim(name), rtrim(name) FROM users WHERE id = 1",
+			rows:  [][]string{{"KATE SMITH", "  kate smith ", "13", "Kate Smith ", "  Kate Smith"}},
+		},
+		{
+			query: "SELECT substr('hello', 2), substr('hello', 2, 3), substr('hello', 0, 2), substr('hello', 9), trim('xxhixx', 'x')",
+			rows:  [][]string{{"ello", "ell", "h", "", "hi"}},
+		},
+		{
+			query: "SELECT replace(name, ' ', '_'), position('Smith' IN name), position('z', name) FROM users WHERE id = 1",
+			rows:  [][]string{{"__Kate_Smith_", "8", "0"}},
+		},
+		{
+			query: "SELECT abs(score), mod(score, 5), round(score, -1), round(score, -2), round(score) FROM users",
+			rows:  [][]string{{"17", "-2", "-20", "0", "-17"}, {"2150", "0", "2150", "2200", "2150"}},
+		},
+		{
+			query: "SELECT pg_typeof(id), pg_typeof(name), pg_typeof(id = 1) FROM users WHERE id = 1",
+			rows:  [][]string{{"integer", "text", "boolean"}},
+		},
+		{
+			query: "SELECT upper(name), length(null), id FROM users WHERE lower(name) IS NULL",
+			rows:  [][]string{{"NULL", "NULL", "2"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY length(COALESCE(name, ''))",
+			rows:  [][]string{{"2"}, {"1"}},
+		},
+		{
+			query: "SELECT nope(id) FROM users",
+			err:   ErrFunctionDoesNotExist,
+		},
+		{
+			query: "SELECT upper(id) FROM users",
+			err:   ErrInvalidArguments,
+		},
+		{
+			query: "SELECT substr(name) FROM users",
+			err:   ErrInvalidArguments,
+		},
+		{
+			query: "SELECT mod(score, 0) FROM users",
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: "SELECT substr(name, 1, -1) FROM users WHERE id = 1",
+			err:   ErrInvalidArguments,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	concat := func(args []Cell) (interface{}, error) {
+		s := ""
+		for _, arg := range args {
+			s += *arg.AsText()
+		}
+
+		return s, nil
+	}
+
+	err := RegisterFunction("Concat_All", FunctionSignature{Args: []ColumnType{TextType}, Variadic: true, Returns: TextType}, concat)
+	assert.Nil(t, err)
+
+	err = RegisterFunction("concat_all", FunctionSignature{Args: []ColumnType{TextType}, Variadic: true, Returns: TextType}, concat)
+	assert.Equal(t, ErrFunctionAlreadyExists, err)
+
+	err = RegisterFunction("count", FunctionSignature{Returns: IntType}, concat)
+	assert.Equal(t, ErrFunctionAlreadyExists, err)
+
+	err = RegisterFunction("", FunctionSignature{Returns: IntType}, concat)
+	assert.Equal(t, ErrInvalidFunction, err)
+
+	err = RegisterFunction("bad_result", FunctionSignature{Returns: IntType}, concat)
+	assert.Nil(t, err)
+
+	mb := NewMemoryBackend()
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT concat_all('a'), concat_all('a', 'b', 'c'), concat_all('a', null)",
+			rows:  [][]string{{"a", "abc", "NULL"}},
+		},
+		{
+			query: "SELECT concat_all()",
+			rows:  [][]string{{""}},
+		},
+		{
+			query: "SELECT concat_all('a', 1)",
+			err:   ErrInvalidArguments,
+		},
+		{
+			query: "SELECT bad_result()",
+			err:   ErrInvalidFunctionResult,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_Cast(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE raw (id INT PRIMARY KEY, value TEXT, flag BOOLEAN);",
+		"INSERT INTO raw VALUES (1, ' 42 ', true)",
+		"INSERT INTO raw VALUES (2, 'abc', false)",
+		"INSERT INTO raw VALUES (3, null, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT CAST(value AS INT) + 1, value::int::text || '!', id::text, flag::int FROM raw WHERE id = 1",
+			rows:  [][]string{{"43", "42!", "1", "1"}},
+		},
+		{
+			query: "SELECT 'yes'::boolean, 'OFF'::boolean, 0::boolean, (-5)::boolean, false::text, true::boolean",
+			rows:  [][]string{{"true", "false", "false", "true", "false", "true"}},
+		},
+		{
+			query: "SELECT id FROM raw WHERE id::text IN ('1', '2') AND flag::int < 5",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT value::int, flag::text FROM raw WHERE id = 3",
+			rows:  [][]string{{"NULL", "NULL"}},
+		},
+		{
+			query: "SELECT -'7'::int * 2, CAST(null AS text) IS NULL",
+			rows:  [][]string{{"-14", "true"}},
+		},
+		{
+			query: "SELECT value::int FROM raw",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT 'maybe'::boolean",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT '99999999999'::int",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: "SELECT 1::limit",
+			err:   ErrInvalidDatatype,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+}
+
+func TestSelect_NumericTypes(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE measures (id BIGINT PRIMARY KEY, small SMALLINT, ratio REAL, reading DOUBLE PRECISION, price NUMERIC(8, 2));",
+		"INSERT INTO measures VALUES (3000000000, 7, 0.5, 1.25, 19.999)",
+		"INSERT INTO measures VALUES (-3000000000, -2, -1.5, -0.001, 3.14159)",
+		"INSERT INTO measures VALUES (5, null, null, null, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id, small, ratio, reading, price FROM measures ORDER BY id",
+			rows: [][]string{
+				{"-3000000000", "-2", "-1.5", "-0.001", "3.14"},
+				{"5", "NULL", "NULL", "NULL", "NULL"},
+				{"3000000000", "7", "0.5", "1.25", "20.00"},
+			},
+		},
+		{
+			query: "SELECT pg_typeof(1), pg_typeof(3000000000), pg_typeof(1.5), pg_typeof(1e3), pg_typeof(99999999999999999999)",
+			rows:  [][]string{{"integer", "bigint", "numeric", "numeric", "numeric"}},
+		},
+		{
+			query: "SELECT pg_typeof(small + 1), pg_typeof(id + 1), pg_typeof(small * price), pg_typeof(price + ratio), pg_typeof(ratio + reading) FROM measures WHERE id = 3000000000",
+			rows:  [][]string{{"integer", "bigint", "numeric", "real", "double precision"}},
+		},
+		{
+			query: "SELECT 1.5 + 1, 10 / 4, 10 / 4.0, 7 % 2.5, 2147483647 + 1::bigint, 0.1 + 0.2 = 0.3, 1 / 3.0",
+			rows:  [][]string{{"2.5", "2", "2.5000000000000000", "2.0", "2147483648", "true", "0.33333333333333333333"}},
+		},
+		{
+			query: "SELECT 1.50, 1.50 * 2, 1.5 + 1.25, -1234.5::numeric(7, 2), 20::numeric(8, 2), 1e3, 2.5e-3, 1.50 = 1.5",
+			rows:  [][]string{{"1.50", "3.00", "2.75", "-1234.50", "20.00", "1000", "0.0025", "true"}},
+		},
+		{
+			query: "SELECT 1 / 7.0, 100000 / 3.0, 0.001 / 3, 1.000 / 1.00",
+			rows:  [][]string{{"0.14285714285714285714", "33333.333333333333", "0.00033333333333333333", "1.00000000000000000000"}},
+		},
+		{
+			query: "SELECT 1.5 UNION SELECT 1.50",
+			rows:  [][]string{{"1.5"}},
+		},
+		{
+			query: "SELECT id FROM measures WHERE small < 2.5 OR price = 20 ORDER BY id DESC",
+			rows:  [][]string{{"3000000000"}, {"-3000000000"}},
+		},
+		{
+			query: "SELECT id FROM measures WHERE id < 0",
+			rows:  [][]string{{"-3000000000"}},
+		},
+		{
+			query: "SELECT id FROM measures WHERE id IN (5, 7, 3000000000.0)",
+			rows:  [][]string{{"3000000000"}, {"5"}},
+		},
+		{
+			query: "SELECT id FROM measures WHERE id = 5 AND reading IS NULL",
+			rows:  [][]string{{"5"}},
+		},
+		{
+			query: "SELECT id FROM measures WHERE id BETWEEN -5000000000 AND 5.5",
+			rows:  [][]string{{"-3000000000"}, {"5"}},
+		},
+		{
+			query: "SELECT '3.7'::numeric::int, (-2.5)::int, 2.5::double precision::int, 1e20::text, 123456789::double precision::text, 0.1::real",
+			rows:  [][]string{{"4", "-3", "2", "100000000000000000000", "123456789", "0.1"}},
+		},
+		{
+			query: "SELECT 12.345::numeric(4, 1), 1e-7::double precision, 'Infinity'::real",
+			rows:  [][]string{{"12.3", "1e-07", "Infinity"}},
+		},
+		{
+			query: "SELECT sum(small), avg(small), sum(price), avg(ratio), max(reading), min(id), sum(id) FROM measures",
+			rows:  [][]string{{"5", "2.5000000000000000", "23.14", "-0.5", "1.25", "-3000000000", "5"}},
+		},
+		{
+			query: "SELECT GREATEST(small, 2.5), COALESCE(price, 0), CASE WHEN id = 5 THEN 1 ELSE ratio END FROM measures ORDER BY id",
+			rows:  [][]string{{"2.5", "3.14", "-1.5"}, {"2.5", "0", "1"}, {"7", "20.00", "0.5"}},
+		},
+		{
+			query: "SELECT abs(small), abs(-1.5), round(2.345, 2), round(reading), mod(id, 7), round(2.3, 3), round(2.5), price * 2 FROM measures WHERE id = 3000000000",
+			rows:  [][]string{{"7", "1.5", "2.35", "1", "4", "2.300", "3", "40.00"}},
+		},
+		{
+			query: "SELECT 32767::smallint + 1::smallint",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: "SELECT 9223372036854775807 + 1",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			query: "SELECT '1e39'::real",
+			err:   ErrNumericOutOfRange,
+		},
+		{
+			query: "SELECT 123.45::numeric(4, 2)",
+			err:   ErrNumericOutOfRange,
+		},
+		{
+			query: "SELECT 1e131071",
+			err:   ErrNumericOutOfRange,
+		},
+		{
+			query: "SELECT 1.0 / 0",
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: "SELECT 1::numeric(0)",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			query: "SELECT ratio % 2 FROM measures",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	parser := Parser{HelpMessagesDisabled: true}
+	for _, test := range []struct {
+		stmt string
+		err  error
+	}{
+		{"INSERT INTO measures VALUES (1, 1, 1, 1, 1000000)", ErrNumericOutOfRange},
+		{"INSERT INTO measures VALUES (1, 1, 1, 1e200000, 1)", ErrNumericOutOfRange},
+		{"INSERT INTO measures VALUES (1, 1, 1, 1, 1e200000)", ErrNumericOutOfRange},
+		{"INSERT INTO measures VALUES (1, 40000, 1, 1, 1)", ErrIntegerOutOfRange},
+		{"INSERT INTO measures VALUES (1, 1, 1, 1, 'a')", ErrInvalidDatatype},
+		{"UPDATE measures SET small = small * 5000 WHERE id = 3000000000", ErrIntegerOutOfRange},
+		{"UPDATE measures SET price = price * 10, small = 2.6 WHERE id = 3000000000", nil},
+	} {
+		ast, err := parser.Parse(test.stmt)
+		assert.Nil(t, err, test.stmt)
+
+		stmt := ast.Statements[0]
+		if stmt.Kind == InsertKind {
+			err = mb.Insert(stmt.InsertStatement)
+		} else {
+			err = mb.Update(stmt.UpdateStatement)
+		}
+		assert.Equal(t, test.err, err, test.stmt)
+	}
+
+	rows, err := selectStrings(mb, "SELECT small, price FROM measures WHERE id = 3000000000")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"3", "200.00"}}, rows)
+
+	// Values of other numeric types are looked up in the index when
+	// converting them doesn't change them
+	measures := mb.tables["measures"]
+	for _, test := range []struct {
+		where      string
+		rowIndexes []uint
+		ok         bool
+	}{
+		{"id = 5", []uint{2}, true},
+		{"id >= 5.0", []uint{2, 0}, true},
+		{"id < 5.5", nil, false},
+		{"id = 'a'", nil, false},
+	} {
+		ast, err := parser.Parse("SELECT id FROM measures WHERE " + test.where)
+		assert.Nil(t, err, test.where)
+		iAndEs := measures.getApplicableIndexes(ast.Statements[0].SelectStatement.Where)
+		assert.Equal(t, 1, len(iAndEs), test.where)
+		rowIndexes, ok := iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+		assert.Equal(t, test.ok, ok, test.where)
+		assert.Equal(t, test.rowIndexes, rowIndexes, test.where)
+	}
+}
+
+func TestSelect_Datetime(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE events (id INT PRIMARY KEY, day DATE, at TIMESTAMP, starts TIME, length INTERVAL);",
+		"CREATE INDEX at_idx ON events (at);",
+		"INSERT INTO events VALUES (1, DATE '2024-01-31', TIMESTAMP '2024-01-31 10:30:00', TIME '09:00', INTERVAL '1 hour 30 minutes')",
+		"INSERT INTO events VALUES (2, DATE '2024-02-29', TIMESTAMP '2024-02-29T23:59:59.5', TIME '23:30:15.25', INTERVAL '1 day')",
+		"INSERT INTO events VALUES (3, DATE '1999-12-31', DATE '2000-01-01', TIME '00:00', INTERVAL '-2 mons 3 days ago')",
+		"INSERT INTO events VALUES (4, null, null, null, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT day AS date, starts AS time FROM events WHERE day = DATE '2024-01-31'",
+			rows:  [][]string{{"2024-01-31", "09:00:00"}},
+		},
+		{
+			query: "SELECT day, at, starts, length FROM events WHERE id < 4 ORDER BY id",
+			rows: [][]string{
+				{"2024-01-31", "2024-01-31 10:30:00", "09:00:00", "01:30:00"},
+				{"2024-02-29", "2024-02-29 23:59:59.5", "23:30:15.25", "1 day"},
+				{"1999-12-31", "2000-01-01 00:00:00", "00:00:00", "2 mons -3 days"},
+			},
+		},
+		{
+			query: "SELECT id FROM events WHERE at > TIMESTAMP '2024-01-01' ORDER BY at DESC",
+			rows:  [][]string{{"2"}, {"1"}},
+		},
+		{
+			query: "SELECT id FROM events WHERE day + 1 = at",
+			rows:  [][]string{{"3"}},
+		},
+		{
+			query: "SELECT id FROM events WHERE day BETWEEN DATE '2024-01-01' AND DATE '2024-12-31'",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM events WHERE length > INTERVAL '24 hours'",
+			rows:  [][]string{{"3"}},
+		},
+		{
+			query: "SELECT INTERVAL '1 mon' = INTERVAL '30 days', INTERVAL '1 day' > INTERVAL '23:59:59'",
+			rows:  [][]string{{"true", "true"}},
+		},
+		{
+			query: "SELECT day + 1, day - 31, day - DATE '2024-01-01', day + starts FROM events WHERE id = 1",
+			rows:  [][]string{{"2024-02-01", "2023-12-31", "30", "2024-01-31 09:00:00"}},
+		},
+		{
+			query: "SELECT at + INTERVAL '1 month', at - INTERVAL '1 year 1 day', INTERVAL '2 hours' + at FROM events WHERE id = 1",
+			rows:  [][]string{{"2024-02-29 10:30:00", "2023-01-30 10:30:00", "2024-01-31 12:30:00"}},
+		},
+		{
+			query: "SELECT day + INTERVAL '1 month', DATE '2024-03-01' - INTERVAL '1 day', INTERVAL '1 day 2 hours' + day FROM events WHERE id = 1",
+			rows:  [][]string{{"2024-02-29 00:00:00", "2024-02-29 00:00:00", "2024-02-01 02:00:00"}},
+		},
+		{
+			query: "SELECT at - TIMESTAMP '2024-01-01', starts + INTERVAL '1 hour', starts - TIME '12:00' FROM events WHERE id = 2",
+			rows:  [][]string{{"59 days 23:59:59.5", "00:30:15.25", "11:30:15.25"}},
+		},
+		{
+			query: "SELECT length * 3, length / 2, -length, 2 * INTERVAL '1.5 days' FROM events WHERE id = 1",
+			rows:  [][]string{{"04:30:00", "00:45:00", "-01:30:00", "2 days 24:00:00"}},
+		},
+		{
+			query: "SELECT INTERVAL '1 year 2 months 3 days 04:05:06.5', INTERVAL '-1 years -2 days', INTERVAL '90 min', INTERVAL '1.5 months'",
+			rows:  [][]string{{"1 year 2 mons 3 days 04:05:06.5", "-1 years -2 days", "01:30:00", "1 mon 15 days"}},
+		},
+		{
+			query: "SELECT CAST(at AS DATE), at::TIME, day::TIMESTAMP, CAST(length AS TEXT), '2024-03-01'::DATE FROM events WHERE id = 2",
+			rows:  [][]string{{"2024-02-29", "23:59:59.5", "2024-02-29 00:00:00", "1 day", "2024-03-01"}},
+		},
+		{
+			query: "SELECT extract(year FROM at), extract('month' FROM day), extract(dow FROM day), extract(second FROM starts), extract(epoch FROM length) FROM events WHERE id = 2",
+			rows:  [][]string{{"2024", "2", "4", "15.250000", "86400.000000"}},
+		},
+		{
+			query: "SELECT date_part('hour', at), extract(day FROM length), extract(doy FROM at) FROM events WHERE id = 1",
+			rows:  [][]string{{"10", "0", "31"}},
+		},
+		{
+			query: "SELECT date_trunc('month', at), date_trunc('week', day), date_trunc('hour', length) FROM events WHERE id = 1",
+			rows:  [][]string{{"2024-01-01 00:00:00", "2024-01-29 00:00:00", "01:00:00"}},
+		},
+		{
+			query: "SELECT min(at), max(length), count(day) FROM events",
+			rows:  [][]string{{"2000-01-01 00:00:00", "2 mons -3 days", "3"}},
+		},
+		{
+			query: "SELECT day + null, at - null, pg_typeof(at), pg_typeof(length) FROM events WHERE id = 1",
+			rows:  [][]string{{"NULL", "NULL", "timestamp without time zone", "interval"}},
+		},
+		{
+			query: "SELECT current_date <= CURRENT_TIMESTAMP, now() > TIMESTAMP '2024-01-01', pg_typeof(current_date)",
+			rows:  [][]string{{"true", "true", "date"}},
+		},
+		{
+			query: "SELECT DATE '2024-02-30'",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT INTERVAL '1 fortnight'",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT DATE '9999-12-31' + 1",
+			err:   ErrDatetimeOutOfRange,
+		},
+		{
+			query: "SELECT day < starts FROM events",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT at + at FROM events",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT date_trunc('fortnight', at) FROM events",
+			err:   ErrInvalidArguments,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("INSERT INTO events VALUES (5, '2024-01-01', null, null, null)")
+	assert.Nil(t, err)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, ErrInvalidDatatype, err)
+
+	// Typed literals can be looked up in an index
+	events := mb.tables["events"]
+	ast, err = parser.Parse("SELECT id FROM events WHERE at >= TIMESTAMP '2024-01-31 10:30'")
+	assert.Nil(t, err)
+	iAndEs := events.getApplicableIndexes(ast.Statements[0].SelectStatement.Where)
+	assert.Equal(t, 1, len(iAndEs))
+	rowIndexes, ok := iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+	assert.True(t, ok)
+	assert.Equal(t, []uint{0, 1}, rowIndexes)
+}
+
+func TestSelect_Binary(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE files (id UUID PRIMARY KEY, data BYTEA);",
+		"INSERT INTO files VALUES (UUID 'A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', BYTEA '\\xdeadbeef')",
+		"INSERT INTO files VALUES ('{00000000-0000-0000-0000-000000000001}'::uuid, 'a\\\\b\\000'::bytea)",
+		"INSERT INTO files VALUES (UUID '00000000000000000000000000000002', null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT id, data FROM files ORDER BY id",
+			rows: [][]string{
+				{"00000000-0000-0000-0000-000000000001", "\\x615c6200"},
+				{"00000000-0000-0000-0000-000000000002", "NULL"},
+				{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "\\xdeadbeef"},
+			},
+		},
+		{
+			query: "SELECT length(data), encode(data, 'base64'), encode(data, 'escape') FROM files WHERE id = UUID 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11'",
+			rows:  [][]string{{"4", "3q2+7w==", "\\336\\255\\276\\357"}},
+		},
+		{
+			query: "SELECT data || decode('ff', 'hex'), data::text FROM files WHERE data < BYTEA '\\xde'",
+			rows:  [][]string{{"\\x615c6200ff", "\\x615c6200"}},
+		},
+		{
+			query: "SELECT pg_typeof(id), pg_typeof(data), length(gen_random_uuid()::text) FROM files WHERE id::text LIKE '%0001'",
+			rows:  [][]string{{"uuid", "bytea", "36"}},
+		},
+		{
+			query: "SELECT UUID 'a0eebc99-9c0b'",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT BYTEA '\\xabc'",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT decode('zz', 'hex')",
+			err:   ErrInvalidArguments,
+		},
+		{
+			query: "SELECT id + 1 FROM files",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("INSERT INTO files VALUES (UUID '00000000-0000-0000-0000-000000000001', null)")
+	assert.Nil(t, err)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, ErrViolatesUniqueConstraint, err)
+}
+
+func TestSelect_JSON(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE docs (id INT PRIMARY KEY, raw JSON, payload JSONB);",
+		"CREATE INDEX payload_idx ON docs USING gin (payload);",
+		`INSERT INTO docs VALUES (1, JSON '{"b": 1, "a": [1, 2.50]}', '{"b": 1, "a": [1, 2.50], "b": 2}'::jsonb)`,
+		`INSERT INTO docs VALUES (2, '[{"name": "x"}, 3]'::json, JSONB '{"user": {"name": "ann", "tags": ["a", "b"]}, "n": null}')`,
+		`INSERT INTO docs VALUES (3, JSON 'null', JSONB '{"user": {"name": "bob", "tags": ["b"]}}')`,
+		"INSERT INTO docs VALUES (4, null, JSONB '[\"a\", 1, true]')",
+		"INSERT INTO docs VALUES (5, null, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT raw, payload FROM docs WHERE id = 1",
+			rows:  [][]string{{`{"b": 1, "a": [1, 2.50]}`, `{"a": [1, 2.5], "b": 2}`}},
+		},
+		{
+			query: "SELECT payload->'user'->>'name', payload#>'{user,tags}', payload#>>'{user,tags,-1}' FROM docs WHERE id IN (2, 3) ORDER BY id",
+			rows: [][]string{
+				{"ann", `["a", "b"]`, "b"},
+				{"bob", `["b"]`, "b"},
+			},
+		},
+		{
+			query: "SELECT raw->0, raw->0->>'name', raw->>1, raw->'missing', payload->>'n' FROM docs WHERE id = 2",
+			rows:  [][]string{{`{"name": "x"}`, "x", "3", "NULL", "NULL"}},
+		},
+		{
+			query: `SELECT id FROM docs WHERE payload @> JSONB '{"user": {"tags": ["b"]}}' ORDER BY id`,
+			rows:  [][]string{{"2"}, {"3"}},
+		},
+		{
+			query: `SELECT id FROM docs WHERE JSONB '{"user": {"name": "ann"}}' <@ payload`,
+			rows:  [][]string{{"2"}},
+		},
+		{
+			query: `SELECT id FROM docs WHERE payload @> JSONB '"a"' OR payload @> JSONB '{"a": [2.5]}' ORDER BY id`,
+			rows:  [][]string{{"1"}, {"4"}},
+		},
+		{
+			query: `SELECT id FROM docs WHERE payload = JSONB '{"b":2,"a":[1,2.5]}'`,
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT jsonb_typeof(payload), jsonb_array_length(payload->'a'), jsonb_extract_path_text(payload, 'a', '1'), json_typeof(raw) FROM docs WHERE id = 1",
+			rows:  [][]string{{"object", "2", "2.5", "object"}},
+		},
+		{
+			query: "SELECT jsonb_set(payload, '{user,name}', to_jsonb('cy'::text)), jsonb_set(payload, '{user,age}', to_jsonb(30), false) FROM docs WHERE id = 3",
+			rows:  [][]string{{`{"user": {"name": "cy", "tags": ["b"]}}`, `{"user": {"name": "bob", "tags": ["b"]}}`}},
+		},
+		{
+			query: "SELECT jsonb_strip_nulls(payload), jsonb_pretty(payload->'user') FROM docs WHERE id = 2",
+			rows:  [][]string{{`{"user": {"name": "ann", "tags": ["a", "b"]}}`, "{\n    \"name\": \"ann\",\n    \"tags\": [\n        \"a\",\n        \"b\"\n    ]\n}"}},
+		},
+		{
+			query: "SELECT (payload->'b')::int + 1, (payload->'a'->1)::numeric, raw::jsonb FROM docs WHERE id = 1",
+			rows:  [][]string{{"3", "2.5", `{"a": [1, 2.5], "b": 1}`}},
+		},
+		{
+			query: "SELECT payload->'user' FROM docs WHERE id = 5",
+			rows:  [][]string{{"NULL"}},
+		},
+		{
+			query: "SELECT raw = raw FROM docs",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT raw @> raw FROM docs",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT JSONB '{\"a\": }'",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT (payload->'user')::int FROM docs WHERE id = 2",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT payload#>'user' FROM docs",
+			err:   ErrInvalidCast,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	// Containment is looked up in the GIN index, which narrows rows
+	// down to the documents with every scalar asked for
+	docs := mb.tables["docs"]
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse(`SELECT id FROM docs WHERE payload @> JSONB '{"user": {"tags": ["b"]}}'`)
+	assert.Nil(t, err)
+	iAndEs := docs.getApplicableIndexes(ast.Statements[0].SelectStatement.Where)
+	assert.Equal(t, 1, len(iAndEs))
+	rowIndexes, ok := iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+	assert.True(t, ok)
+	assert.Equal(t, []uint{1, 2}, rowIndexes)
+
+	// Updates and deletes keep the entries current
+	runStatements(t, mb,
+		`UPDATE docs SET payload = JSONB '{"user": {"tags": ["c"]}}' WHERE id = 3`,
+		"DELETE FROM docs WHERE id = 2",
+	)
+	rows, err := selectStrings(mb, `SELECT id FROM docs WHERE payload @> JSONB '{"user": {"tags": ["c"]}}'`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"3"}}, rows)
+	rows, err = selectStrings(mb, `SELECT id FROM docs WHERE payload @> JSONB '{"user": {}}'`)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"3"}}, rows)
+
+	for _, stmt := range []string{
+		"CREATE INDEX raw_idx ON docs USING gin (raw)",
+		"CREATE UNIQUE INDEX payload_unique ON docs USING gin (payload)",
+		"CREATE INDEX id_idx ON docs USING hash (id)",
+	} {
+		ast, err := parser.Parse(stmt)
+		assert.Nil(t, err)
+		err = mb.CreateIndex(ast.Statements[0].CreateIndexStatement)
+		assert.Equal(t, ErrInvalidIndexMethod, err, stmt)
+	}
+
+	for _, table := range mb.GetTables() {
+		assert.Equal(t, "gin", table.Indexes[1].Type)
+	}
+}
+
+func TestSelect_Arrays(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE posts (id INT PRIMARY KEY, tags TEXT[], scores INT[]);",
+		"CREATE INDEX tags_idx ON posts USING gin (tags);",
+		"INSERT INTO posts VALUES (1, ARRAY['go', 'sql'], ARRAY[3, 1, 2])",
+		"INSERT INTO posts VALUES (2, '{sql,\"a b\",NULL}'::text[], ARRAY[]::int[])",
+		"INSERT INTO posts VALUES (3, ARRAY['rust'], ARRAY[5, NULL])",
+		"INSERT INTO posts VALUES (4, null, null)",
+	)
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT tags, scores, tags[1], scores[3], scores[0] FROM posts ORDER BY id",
+			rows: [][]string{
+				{"{go,sql}", "{3,1,2}", "go", "2", "NULL"},
+				{`{sql,"a b",NULL}`, "{}", "sql", "NULL", "NULL"},
+				{"{rust}", "{5,NULL}", "rust", "NULL", "NULL"},
+				{"NULL", "NULL", "NULL", "NULL", "NULL"},
+			},
+		},
+		{
+			query: "SELECT id FROM posts WHERE 'sql' = ANY(tags) ORDER BY id",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id, 2 < ALL(scores), 4 = ANY(scores) FROM posts ORDER BY id",
+			rows: [][]string{
+				{"1", "false", "false"},
+				{"2", "true", "false"},
+				{"3", "NULL", "NULL"},
+				{"4", "NULL", "NULL"},
+			},
+		},
+		{
+			query: "SELECT id FROM posts WHERE id = ANY(SELECT scores[1] FROM posts) ORDER BY id",
+			rows:  [][]string{{"3"}},
+		},
+		{
+			query: "SELECT id FROM posts WHERE tags @> ARRAY['sql'] ORDER BY id",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id, tags && ARRAY['go', 'rust'], ARRAY['sql'] <@ tags FROM posts ORDER BY id",
+			rows: [][]string{
+				{"1", "true", "true"},
+				{"2", "false", "true"},
+				{"3", "true", "false"},
+				{"4", "NULL", "NULL"},
+			},
+		},
+		{
+			query: "SELECT scores || 4, 0 || scores, scores || ARRAY[7.5], ARRAY[1, 2] = ARRAY[1, 2]::bigint[] FROM posts WHERE id = 1",
+			rows:  [][]string{{"{3,1,2,4}", "{0,3,1,2}", "{3,1,2,7.5}", "true"}},
+		},
+		{
+			query: "SELECT cardinality(scores), array_length(scores, 1), array_append(tags, 'x'), array_to_string(tags, '/'), pg_typeof(scores) FROM posts WHERE id = 2",
+			rows:  [][]string{{"0", "NULL", `{sql,"a b",NULL,x}`, "sql/a b", "integer[]"}},
+		},
+		{
+			query: "SELECT '{1, 2}'::int[], ARRAY[true, false]::text, CAST(ARRAY[1.5, 2] AS numeric(3, 0)[])",
+			rows:  [][]string{{"{1,2}", "{t,f}", "{2,2}"}},
+		},
+		{
+			query: "SELECT id FROM posts ORDER BY scores DESC NULLS LAST, id",
+			rows:  [][]string{{"3"}, {"1"}, {"2"}, {"4"}},
+		},
+		{
+			query: "SELECT array_agg(id), array_agg(tags[1]) FROM posts",
+			rows:  [][]string{{"{1,2,3,4}", "{go,sql,rust,NULL}"}},
+		},
+		{
+			query: "SELECT array_agg(id) FROM posts WHERE id > 4",
+			rows:  [][]string{{"NULL"}},
+		},
+		{
+			query: "SELECT tag FROM unnest(ARRAY['x', 'y']) AS tag",
+			rows:  [][]string{{"x"}, {"y"}},
+		},
+		{
+			query: "SELECT id, tag FROM posts CROSS JOIN unnest(posts.tags) AS tag ORDER BY id, tag",
+			rows: [][]string{
+				{"1", "go"},
+				{"1", "sql"},
+				{"2", "a b"},
+				{"2", "sql"},
+				{"2", "NULL"},
+				{"3", "rust"},
+			},
+		},
+		{
+			query: "SELECT id, unnest FROM posts LEFT JOIN unnest(scores) ON unnest > 1 WHERE id > 1 ORDER BY id",
+			rows: [][]string{
+				{"2", "NULL"},
+				{"3", "5"},
+				{"4", "NULL"},
+			},
+		},
+		{
+			query: "SELECT ARRAY[1, 'a']",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT ARRAY[ARRAY[1]]",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT tags[1] FROM posts WHERE id = ANY(id)",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT '{1,x}'::int[]",
+			err:   ErrInvalidCast,
+		},
+		{
+			query: "SELECT x FROM unnest(1) AS x",
+			err:   ErrInvalidArguments,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := selectStrings(mb, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if test.err == nil {
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
 
-	ast, err = parser.Parse("CREATE TABLE test(x INT, y INT, z BOOLEAN);")
-	assert.Nil(t, err)
-	assert.NotEqual(t, ast, nil)
-	err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
+	// Containment of constant arrays is looked up in the GIN index
+	posts := mb.tables["posts"]
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("SELECT id FROM posts WHERE tags @> ARRAY['sql']")
 	assert.Nil(t, err)
+	iAndEs := posts.getApplicableIndexes(ast.Statements[0].SelectStatement.Where)
+	assert.Equal(t, 1, len(iAndEs))
+	rowIndexes, ok := iAndEs[0].i.rowIndexesFromSubset(iAndEs[0].e)
+	assert.True(t, ok)
+	assert.Equal(t, []uint{0, 1}, rowIndexes)
 
-	ast, err = parser.Parse("INSERT INTO test VALUES(100, 200, true)")
+	// Text can't be stored in an array column without a cast, but
+	// arrays of other numbers can
+	ast, err = parser.Parse("INSERT INTO posts VALUES (5, '{a}', ARRAY[1.0])")
 	assert.Nil(t, err)
-	assert.NotEqual(t, ast, nil)
-	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, ErrInvalidDatatype, mb.Insert(ast.Statements[0].InsertStatement))
+	runStatements(t, mb, "INSERT INTO posts VALUES (5, ARRAY['c'], ARRAY[2.0])")
+	rows, err := selectStrings(mb, "SELECT scores FROM posts WHERE id = 5")
 	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"{2}"}}, rows)
+}
 
-	Value100 := literalToMemoryCell(&Token{"100", NumericKind, Location{}})
-	Value200 := literalToMemoryCell(&Token{"200", NumericKind, Location{}})
-	xCol := ResultColumn{IntType, "x", false}
-	yCol := ResultColumn{IntType, "y", false}
-	zCol := ResultColumn{BoolType, "z", false}
+func TestSelect_IndexKeys(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE points (id INT PRIMARY KEY, label TEXT, weight NUMERIC);",
+		"CREATE UNIQUE INDEX label_idx ON points (label);",
+		"CREATE INDEX weight_idx ON points (weight);",
+		"INSERT INTO points VALUES (3, 'c', 1.5)",
+		"INSERT INTO points VALUES (-7, null, -20)",
+		"INSERT INTO points VALUES (0, 'a', null)",
+		"INSERT INTO points VALUES (-1, null, 1.25)",
+		"INSERT INTO points VALUES (12, 'ab', -3)",
+	)
 
 	tests := []struct {
-		query   string
-		results Results
+		query string
+		rows  [][]string
 	}{
 		{
-			"SELECT * FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol, zCol},
-				[][]Cell{{Value100, Value200, trueMemoryCell}},
-			},
+			query: "SELECT id FROM points WHERE id < 0",
+			rows:  [][]string{{"-1"}, {"-7"}},
 		},
 		{
-			"SELECT x FROM test",
-			Results{
-				[]ResultColumn{xCol},
-				[][]Cell{{Value100}},
-			},
+			query: "SELECT id FROM points WHERE id >= -1",
+			rows:  [][]string{{"-1"}, {"0"}, {"3"}, {"12"}},
 		},
 		{
-			"SELECT x, y FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol},
-				[][]Cell{{Value100, Value200}},
-			},
+			query: "SELECT id FROM points WHERE 0 > id",
+			rows:  [][]string{{"-1"}, {"-7"}},
 		},
 		{
-			"SELECT x, y, z FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol, zCol},
-				[][]Cell{{Value100, Value200, trueMemoryCell}},
-			},
+			query: "SELECT id FROM points WHERE -1 <= id",
+			rows:  [][]string{{"-1"}, {"0"}, {"3"}, {"12"}},
 		},
 		{
-			"SELECT *, x FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol, zCol, xCol},
-				[][]Cell{{Value100, Value200, trueMemoryCell, Value100}},
-			},
+			query: "SELECT id FROM points WHERE 3 < id",
+			rows:  [][]string{{"12"}},
 		},
 		{
-			"SELECT *, x, y FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol, zCol, xCol, yCol},
-				[][]Cell{{Value100, Value200, trueMemoryCell, Value100, Value200}},
-			},
+			query: "SELECT id FROM points WHERE 1.25 >= weight",
+			rows:  [][]string{{"-1"}, {"12"}, {"-7"}},
 		},
 		{
-			"SELECT *, x, y, z FROM test",
-			Results{
-				[]ResultColumn{xCol, yCol, zCol, xCol, yCol, zCol},
-				[][]Cell{{Value100, Value200, trueMemoryCell, Value100, Value200, trueMemoryCell}},
-			},
+			query: "SELECT id FROM points WHERE id BETWEEN -7 AND 0",
+			rows:  [][]string{{"-7"}, {"-1"}, {"0"}},
 		},
 		{
-			"SELECT x, *, z FROM test",
-			Results{
-				[]ResultColumn{xCol, xCol, yCol, zCol, zCol},
-				[][]Cell{{Value100, Value100, Value200, trueMemoryCell, trueMemoryCell}},
-			},
+			query: "SELECT id FROM points WHERE id BETWEEN 3 AND -7",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM points WHERE label <> 'c'",
+			rows:  [][]string{{"0"}, {"12"}},
+		},
+		{
+			query: "SELECT id FROM points WHERE label = null",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM points WHERE label IS NULL",
+			rows:  [][]string{{"-7"}, {"-1"}},
+		},
+		{
+			query: "SELECT id FROM points WHERE weight <= 1.25",
+			rows:  [][]string{{"-1"}, {"12"}, {"-7"}},
+		},
+		{
+			query: "SELECT id FROM points ORDER BY id DESC",
+			rows:  [][]string{{"12"}, {"3"}, {"0"}, {"-1"}, {"-7"}},
+		},
+		{
+			query: "SELECT label FROM points ORDER BY label",
+			rows:  [][]string{{"a"}, {"ab"}, {"c"}, {"NULL"}, {"NULL"}},
+		},
+		{
+			query: "SELECT label FROM points ORDER BY label DESC",
+			rows:  [][]string{{"NULL"}, {"NULL"}, {"c"}, {"ab"}, {"a"}},
+		},
+		{
+			query: "SELECT weight FROM points ORDER BY weight NULLS FIRST",
+			rows:  [][]string{{"NULL"}, {"-20"}, {"-3"}, {"1.25"}, {"1.5"}},
 		},
 	}
 
 	for _, test := range tests {
-		ast, err = parser.Parse(test.query)
-		assert.Nil(t, err)
-		assert.NotEqual(t, ast, nil)
-
-		var res *Results
-		res, err = mb.Select(ast.Statements[0].SelectStatement)
-		assert.Nil(t, err)
-		assert.Equal(t, *res, test.results)
+		rows, err := selectStrings(mb, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, rows, test.query)
 	}
+
+	// NULLs don't conflict in unique indexes but primary keys reject them
+	runStatements(t, mb, "INSERT INTO points VALUES (20, null, 0)")
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("INSERT INTO points VALUES (null, 'z', 0)")
+	assert.Nil(t, err)
+	err = mb.Insert(ast.Statements[0].InsertStatement)
+	assert.Equal(t, ErrViolatesNotNullConstraint, err)
+
+	// Indexes are read in order unless NULLs must come first
+	points := mb.tables["points"]
+	id := Expression{Literal: &Token{Value: "id", Kind: IdentifierKind}, Kind: LiteralKind}
+	assert.Equal(t, "points_pkey", points.orderedIndex(OrderByItem{Exp: &id}).name)
+	assert.Equal(t, "points_pkey", points.orderedIndex(OrderByItem{Exp: &id, Desc: true}).name)
+	assert.Nil(t, points.orderedIndex(OrderByItem{Exp: &id, Nulls: NullsFirstOrder}))
 }
 
 func TestInsert(t *testing.T) {
@@ -133,6 +2317,112 @@ func TestInsert(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestUpdate(t *testing.T) {
+	mb = NewMemoryBackend()
+
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("UPDATE test SET x = 1")
+	assert.Nil(t, err)
+	err = mb.Update(ast.Statements[0].UpdateStatement)
+	assert.Equal(t, ErrTableDoesNotExist, err)
+
+	runStatements(t, mb,
+		"CREATE TABLE test(x INT PRIMARY KEY, y TEXT);",
+		"CREATE INDEX y_idx ON test (y);",
+		"INSERT INTO test VALUES(1, 'a')",
+		"INSERT INTO test VALUES(2, 'b')",
+		"INSERT INTO test VALUES(3, 'b')",
+	)
+
+	tests := []struct {
+		update string
+		err    error
+		query  string
+		rows   int
+	}{
+		{"UPDATE test SET z = 1", ErrColumnDoesNotExist, "SELECT x FROM test", 3},
+		{"UPDATE test SET x = 'a'", ErrInvalidDatatype, "SELECT x FROM test", 3},
+		{"UPDATE test SET x = 4, y = 'd', x = 5 WHERE x = 1", ErrDuplicateColumn, "SELECT x FROM test WHERE x = 1", 1},
+		{"UPDATE test SET x = 1 WHERE x = 2", ErrViolatesUniqueConstraint, "SELECT x FROM test WHERE x = 2", 1},
+		{"UPDATE test SET x = null WHERE x = 2", ErrViolatesNotNullConstraint, "SELECT x FROM test WHERE x = 2", 1},
+		{"UPDATE test SET y = 'c' WHERE y = 'b'", nil, "SELECT x FROM test WHERE y = 'c'", 2},
+		{"UPDATE test SET y = 'b' WHERE x = 2", nil, "SELECT x FROM test WHERE y = 'b'", 1},
+		{"UPDATE test SET x = x + 10", nil, "SELECT x FROM test WHERE x > 10", 3},
+		{"UPDATE test SET x = 5 WHERE x = 11", nil, "SELECT x FROM test WHERE x = 5", 1},
+		{"UPDATE test SET x = x + 1 WHERE x = 12 OR x = 13", nil, "SELECT x FROM test WHERE x = 14", 1},
+		{"UPDATE test SET x = 13 WHERE x = 5 OR x = 14", ErrViolatesUniqueConstraint, "SELECT x FROM test WHERE x = 5", 1},
+	}
+
+	for _, test := range tests {
+		ast, err = parser.Parse(test.update)
+		assert.Nil(t, err, test.update)
+		err = mb.Update(ast.Statements[0].UpdateStatement)
+		assert.Equal(t, test.err, err, test.update)
+
+		ast, err = parser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		res, err := mb.Select(ast.Statements[0].SelectStatement)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, len(res.Rows), test.update)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	mb = NewMemoryBackend()
+
+	parser := Parser{HelpMessagesDisabled: true}
+	ast, err := parser.Parse("DELETE FROM test")
+	assert.Nil(t, err)
+	err = mb.Delete(ast.Statements[0].DeleteStatement)
+	assert.Equal(t, ErrTableDoesNotExist, err)
+
+	runStatements(t, mb,
+		"CREATE TABLE test(x INT PRIMARY KEY, y TEXT);",
+		"CREATE INDEX y_idx ON test (y);",
+		"INSERT INTO test VALUES(1, 'a')",
+		"INSERT INTO test VALUES(2, 'b')",
+		"INSERT INTO test VALUES(3, 'b')",
+		"INSERT INTO test VALUES(4, 'c')",
+	)
+
+	tests := []struct {
+		delete string
+		query  string
+		rows   int
+	}{
+		{"DELETE FROM test WHERE x = 1", "SELECT x FROM test", 3},
+		{"DELETE FROM test WHERE x = 1", "SELECT x FROM test WHERE x = 1", 0},
+		{"DELETE FROM test WHERE y = 'b' AND x = 3", "SELECT x FROM test WHERE y = 'b'", 1},
+		{"DELETE FROM test WHERE y = 'z'", "SELECT x FROM test WHERE x > 0", 2},
+		{"DELETE FROM test", "SELECT x FROM test", 0},
+	}
+
+	for _, test := range tests {
+		ast, err = parser.Parse(test.delete)
+		assert.Nil(t, err, test.delete)
+		err = mb.Delete(ast.Statements[0].DeleteStatement)
+		assert.Nil(t, err, test.delete)
+
+		ast, err = parser.Parse(test.query)
+		assert.Nil(t, err, test.query)
+		res, err := mb.Select(ast.Statements[0].SelectStatement)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.rows, len(res.Rows), test.delete)
+	}
+
+	// Deleted keys can be reused and indexes created afterwards skip
+	// deleted rows
+	runStatements(t, mb,
+		"INSERT INTO test VALUES(1, 'a')",
+		"CREATE UNIQUE INDEX y_unique ON test (y);",
+	)
+	ast, err = parser.Parse("SELECT y FROM test WHERE x = 1")
+	assert.Nil(t, err)
+	res, err := mb.Select(ast.Statements[0].SelectStatement)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", *res.Rows[0][0].AsText())
+}
+
 func TestCreateTable(t *testing.T) {
 	mb = NewMemoryBackend()
 
@@ -147,6 +2437,279 @@ func TestCreateTable(t *testing.T) {
 	// Second time, already exists
 	err = mb.CreateTable(ast.Statements[0].CreateTableStatement)
 	assert.Equal(t, ErrTableAlreadyExists, err)
+
+	// Unreserved keywords can name columns
+	runStatements(t, mb,
+		"CREATE TABLE names (first TEXT, last TEXT, date DATE, time TIME)",
+		"INSERT INTO names VALUES ('Ada', null, DATE '1815-12-10', TIME '12:00')",
+		"INSERT INTO names VALUES ('Alan', 'Turing', null, null)",
+	)
+	rows, err := selectStrings(mb, "SELECT first, date, time FROM names ORDER BY last NULLS FIRST")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"Ada", "1815-12-10", "12:00:00"}, {"Alan", "NULL", "NULL"}}, rows)
+}
+
+func TestCreateTable_Constraints(t *testing.T) {
+	mb = NewMemoryBackend()
+	runStatements(t, mb,
+		`CREATE TABLE items (
+			id INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			qty INT DEFAULT 1 CHECK (qty >= 0),
+			price NUMERIC(5, 2) NOT NULL DEFAULT 0 CHECK (price < 1000),
+			note TEXT DEFAULT 'none',
+			CHECK (qty * price <= 5000)
+		)`,
+	)
+
+	tests := []struct {
+		stmt string
+		err  error
+	}{
+		{"INSERT INTO items (id, name) VALUES (1, 'a')", nil},
+		{"INSERT INTO items (name, id, qty, note) VALUES ('b', 2, null, null)", nil},
+		{"INSERT INTO items VALUES (3, 'c', 10, 1.5, 'x')", nil},
+		{"INSERT INTO items (id) VALUES (4)", ErrViolatesNotNullConstraint},
+		{"INSERT INTO items (name) VALUES ('d')", ErrViolatesNotNullConstraint},
+		{"INSERT INTO items (id, name, price) VALUES (4, 'd', null)", ErrViolatesNotNullConstraint},
+		{"INSERT INTO items (id, name, qty) VALUES (4, 'd', -1)", ErrViolatesCheckConstraint},
+		{"INSERT INTO items (id, name, price) VALUES (4, 'd', 999.99)", nil},
+		{"INSERT INTO items (id, name, qty, price) VALUES (5, 'e', 10, 501)", ErrViolatesCheckConstraint},
+		{"INSERT INTO items (id, name, missing) VALUES (5, 'e', 1)", ErrColumnDoesNotExist},
+		{"INSERT INTO items (id, name, id) VALUES (5, 'e', 6)", ErrDuplicateColumn},
+		{"INSERT INTO items (id, name) VALUES (5)", ErrMissingValues},
+		{"UPDATE items SET qty = qty - 2 WHERE id = 1", ErrViolatesCheckConstraint},
+		{"UPDATE items SET name = null WHERE id = 3", ErrViolatesNotNullConstraint},
+		{"UPDATE items SET qty = 4000 WHERE id = 3", ErrViolatesCheckConstraint},
+		{"UPDATE items SET qty = qty + 1", nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.err, execStatement(mb, test.stmt), test.stmt)
+	}
+
+	rows, err := selectStrings(mb, "SELECT id, name, qty, price, note FROM items ORDER BY id")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"1", "a", "2", "0.00", "none"},
+		{"2", "b", "NULL", "0.00", "NULL"},
+		{"3", "c", "11", "1.50", "x"},
+		{"4", "d", "2", "999.99", "none"},
+	}, rows)
+
+	tables := mb.GetTables()
+	assert.Equal(t, 1, len(tables))
+	notNull := []bool{}
+	for _, c := range tables[0].Columns {
+		notNull = append(notNull, c.NotNull)
+	}
+	assert.Equal(t, []bool{true, true, false, true, false}, notNull)
+	assert.Equal(t, []string{"", "", "1", "0", "'none'"}, tables[0].Defaults)
+	assert.Equal(t, []string{`("qty" >= 0)`, `("price" < 1000)`, `(("qty" * "price") <= 5000)`}, tables[0].Checks)
+
+	for _, stmt := range []string{
+		"CREATE TABLE bad (a INT DEFAULT 'x')",
+		"CREATE TABLE bad (a INT CHECK (a + 1))",
+		"CREATE TABLE bad (a INT, CHECK (b > 0))",
+		"CREATE TABLE bad (a INT DEFAULT a)",
+		"CREATE TABLE bad (a INT CHECK (a IN (SELECT 1)))",
+	} {
+		assert.NotNil(t, execStatement(mb, stmt), stmt)
+		_, ok := mb.tables["bad"]
+		assert.False(t, ok, stmt)
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	mb = NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE users (id INT PRIMARY KEY, email TEXT)",
+		"CREATE UNIQUE INDEX users_email ON users (email)",
+		`CREATE TABLE orders (
+			id INT PRIMARY KEY,
+			user_id INT REFERENCES users ON DELETE CASCADE ON UPDATE CASCADE,
+			email TEXT REFERENCES users (email) ON DELETE SET NULL ON UPDATE RESTRICT
+		)`,
+		"CREATE TABLE notes (id INT PRIMARY KEY, order_id BIGINT, FOREIGN KEY (order_id) REFERENCES orders (id))",
+		"CREATE TABLE employees (id INT PRIMARY KEY, manager INT REFERENCES employees (id) ON DELETE CASCADE)",
+		"INSERT INTO users VALUES (1, 'a')",
+		"INSERT INTO users VALUES (2, 'b')",
+		"INSERT INTO users VALUES (3, 'c')",
+		"INSERT INTO employees VALUES (1, null)",
+		"INSERT INTO employees VALUES (2, 1)",
+		"INSERT INTO employees VALUES (3, 2)",
+		"INSERT INTO employees VALUES (4, 4)",
+	)
+
+	tests := []struct {
+		stmt  string
+		err   error
+		query string
+		rows  [][]string
+	}{
+		{"INSERT INTO orders VALUES (9, 1, 'a')", nil, "", nil},
+		{"INSERT INTO orders VALUES (10, 1, null)", nil, "", nil},
+		{"INSERT INTO orders VALUES (21, 2, 'c')", nil, "", nil},
+		{"INSERT INTO orders VALUES (22, null, null)", nil, "", nil},
+		{"INSERT INTO orders VALUES (13, 5, null)", ErrViolatesForeignKeyConstraint, "", nil},
+		{"INSERT INTO orders VALUES (13, 1, 'z')", ErrViolatesForeignKeyConstraint, "", nil},
+		{"INSERT INTO notes VALUES (1, 10)", nil, "", nil},
+		{"INSERT INTO notes VALUES (2, 99)", ErrViolatesForeignKeyConstraint, "", nil},
+		{"UPDATE orders SET user_id = 4 WHERE id = 22", ErrViolatesForeignKeyConstraint, "SELECT user_id FROM orders WHERE id = 22", [][]string{{"NULL"}}},
+		// Order 10 is still referenced once every order is updated
+		{"UPDATE orders SET id = id + 1 WHERE id < 11", nil, "SELECT id FROM orders ORDER BY id", [][]string{{"10"}, {"11"}, {"21"}, {"22"}}},
+		{"UPDATE orders SET id = 13 WHERE id = 10", ErrViolatesForeignKeyConstraint, "SELECT order_id FROM notes", [][]string{{"10"}}},
+		// Deleting user 1 would delete order 10, which a note references
+		{"DELETE FROM users WHERE id = 1", ErrViolatesForeignKeyConstraint, "SELECT id FROM orders WHERE user_id = 1", [][]string{{"10"}, {"11"}}},
+		{"DELETE FROM notes", nil, "", nil},
+		{"DELETE FROM users WHERE id = 1", nil, "SELECT id, user_id, email FROM orders ORDER BY id", [][]string{{"21", "2", "c"}, {"22", "NULL", "NULL"}}},
+		{"UPDATE users SET id = 20 WHERE id = 2", nil, "SELECT id, user_id FROM orders ORDER BY id", [][]string{{"21", "20"}, {"22", "NULL"}}},
+		{"UPDATE users SET email = 'd' WHERE id = 3", ErrViolatesForeignKeyConstraint, "SELECT email FROM users WHERE id = 3", [][]string{{"c"}}},
+		{"DELETE FROM users WHERE id = 3", nil, "SELECT id, user_id, email FROM orders ORDER BY id", [][]string{{"21", "20", "NULL"}, {"22", "NULL", "NULL"}}},
+		{"DELETE FROM employees WHERE id = 1", nil, "SELECT id FROM employees", [][]string{{"4"}}},
+		{"DELETE FROM employees", nil, "SELECT id FROM employees", [][]string{}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.err, execStatement(mb, test.stmt), test.stmt)
+		if test.query != "" {
+			rows, err := selectStrings(mb, test.query)
+			assert.Nil(t, err, test.query)
+			assert.Equal(t, test.rows, rows, test.stmt)
+		}
+	}
+
+	for _, table := range mb.GetTables() {
+		if table.Name == "orders" {
+			assert.Equal(t, []ForeignKey{
+				{"orders_user_id_fkey", []string{"user_id"}, "users", []string{"id"}, CascadeReferentialAction, CascadeReferentialAction},
+				{"orders_email_fkey", []string{"email"}, "users", []string{"email"}, SetNullReferentialAction, RestrictReferentialAction},
+			}, table.ForeignKeys)
+		}
+	}
+
+	assert.Equal(t, ErrReferencedByForeignKey, execStatement(mb, "DROP TABLE users"))
+	assert.Nil(t, execStatement(mb, "DROP TABLE employees"))
+
+	errors := []struct {
+		stmt string
+		err  error
+	}{
+		{"CREATE TABLE bad (a INT REFERENCES missing)", ErrTableDoesNotExist},
+		{"CREATE TABLE bad (a INT REFERENCES users (missing))", ErrColumnDoesNotExist},
+		{"CREATE TABLE bad (a INT REFERENCES notes (order_id))", ErrInvalidForeignKey},
+		{"CREATE TABLE bad (a TEXT REFERENCES users)", ErrInvalidDatatype},
+		{"CREATE TABLE bad (a INT, b TEXT, FOREIGN KEY (a, b) REFERENCES users)", ErrInvalidForeignKey},
+		{"CREATE TABLE bad (a INT, FOREIGN KEY (b) REFERENCES users)", ErrColumnDoesNotExist},
+	}
+	for _, test := range errors {
+		assert.Equal(t, test.err, execStatement(mb, test.stmt), test.stmt)
+		_, ok := mb.tables["bad"]
+		assert.False(t, ok, test.stmt)
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	mb = NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE stock (store INT, sku TEXT, qty INT, PRIMARY KEY (store, sku), UNIQUE (sku, qty))",
+		"CREATE TABLE codes (code TEXT UNIQUE, label TEXT)",
+		`CREATE TABLE moves (
+			id INT PRIMARY KEY,
+			sku TEXT,
+			store INT,
+			FOREIGN KEY (sku, store) REFERENCES stock (sku, store) ON DELETE CASCADE ON UPDATE CASCADE
+		)`,
+		"CREATE INDEX sku_qty_idx ON stock (sku, qty + 1)",
+		"INSERT INTO stock VALUES (1, 'a', 10)",
+		"INSERT INTO stock VALUES (1, 'b', 5)",
+		"INSERT INTO stock VALUES (2, 'a', 7)",
+		"INSERT INTO stock VALUES (2, 'b', null)",
+		"INSERT INTO stock VALUES (3, 'c', 1)",
+		"INSERT INTO moves VALUES (1, 'a', 1)",
+		"INSERT INTO moves VALUES (2, 'a', 2)",
+		"INSERT INTO moves VALUES (3, null, 5)",
+	)
+
+	tests := []struct {
+		stmt  string
+		err   error
+		query string
+		rows  [][]string
+	}{
+		{"INSERT INTO stock VALUES (1, 'a', 1)", ErrViolatesUniqueConstraint, "", nil},
+		{"INSERT INTO stock VALUES (1, null, 1)", ErrViolatesNotNullConstraint, "", nil},
+		{"INSERT INTO stock VALUES (4, 'a', 10)", ErrViolatesUniqueConstraint, "", nil},
+		// NULLs never conflict
+		{"INSERT INTO stock VALUES (4, 'b', null)", nil, "", nil},
+		{"INSERT INTO codes VALUES ('x', 'a')", nil, "", nil},
+		{"INSERT INTO codes VALUES ('x', 'b')", ErrViolatesUniqueConstraint, "", nil},
+		{"INSERT INTO moves VALUES (4, 'c', 1)", ErrViolatesForeignKeyConstraint, "", nil},
+		{"INSERT INTO moves VALUES (4, 'c', 3)", nil, "", nil},
+		{"", nil, "SELECT qty FROM stock WHERE store = 2 AND sku = 'a'", [][]string{{"7"}}},
+		{"", nil, "SELECT qty FROM stock WHERE sku = 'b' AND store = 1", [][]string{{"5"}}},
+		{"", nil, "SELECT store FROM stock WHERE store = 2 AND sku = 'c'", [][]string{}},
+		{"", nil, "SELECT sku FROM stock WHERE store = 1", [][]string{{"a"}, {"b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store <= 2 ORDER BY store, sku", [][]string{{"1", "a"}, {"1", "b"}, {"2", "a"}, {"2", "b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store < 2 ORDER BY store, sku", [][]string{{"1", "a"}, {"1", "b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store > 2 ORDER BY store, sku", [][]string{{"3", "c"}, {"4", "b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store >= 3 ORDER BY store, sku", [][]string{{"3", "c"}, {"4", "b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store <> 2 ORDER BY store, sku", [][]string{{"1", "a"}, {"1", "b"}, {"3", "c"}, {"4", "b"}}},
+		{"", nil, "SELECT store, sku FROM stock WHERE store BETWEEN 2 AND 3 ORDER BY store, sku", [][]string{{"2", "a"}, {"2", "b"}, {"3", "c"}}},
+		{"", nil, "SELECT store FROM stock WHERE sku = 'a' AND qty + 1 = 8", [][]string{{"2"}}},
+		{"UPDATE stock SET sku = 'd' WHERE store = 1 AND sku = 'a'", nil, "SELECT id, sku, store FROM moves ORDER BY id", [][]string{{"1", "d", "1"}, {"2", "a", "2"}, {"3", "NULL", "5"}, {"4", "c", "3"}}},
+		{"UPDATE stock SET store = 1 WHERE store = 2 AND sku = 'b'", ErrViolatesUniqueConstraint, "SELECT store FROM stock WHERE sku = 'b' ORDER BY store", [][]string{{"1"}, {"2"}, {"4"}}},
+		{"DELETE FROM stock WHERE store = 2", nil, "SELECT id FROM moves ORDER BY id", [][]string{{"1"}, {"3"}, {"4"}}},
+	}
+
+	for _, test := range tests {
+		if test.stmt != "" {
+			assert.Equal(t, test.err, execStatement(mb, test.stmt), test.stmt)
+		}
+
+		if test.query != "" {
+			rows, err := selectStrings(mb, test.query)
+			assert.Nil(t, err, test.query)
+			assert.Equal(t, test.rows, rows, test.query)
+		}
+	}
+
+	for _, table := range mb.GetTables() {
+		switch table.Name {
+		case "stock":
+			assert.Equal(t, []Index{
+				{"stock_pkey", `"store", "sku"`, btreeIndexType, true, true},
+				{"stock_sku_qty_key", `"sku", "qty"`, btreeIndexType, true, false},
+				{"sku_qty_idx", `"sku", ("qty" + 1)`, btreeIndexType, false, false},
+			}, table.Indexes)
+			assert.True(t, table.Columns[1].NotNull)
+			assert.False(t, table.Columns[2].NotNull)
+		case "moves":
+			assert.Equal(t, []ForeignKey{
+				{"moves_sku_store_fkey", []string{"sku", "store"}, "stock", []string{"sku", "store"}, CascadeReferentialAction, CascadeReferentialAction},
+			}, table.ForeignKeys)
+		}
+	}
+
+	errors := []struct {
+		stmt string
+		err  error
+	}{
+		{"CREATE TABLE bad (a INT PRIMARY KEY, b INT PRIMARY KEY)", ErrPrimaryKeyAlreadyExists},
+		{"CREATE TABLE bad (a INT PRIMARY KEY, b INT, PRIMARY KEY (a, b))", ErrPrimaryKeyAlreadyExists},
+		{"CREATE TABLE bad (a INT, b INT, PRIMARY KEY (a, c))", ErrColumnDoesNotExist},
+		{"CREATE TABLE bad (a INT, b INT, UNIQUE (a, a))", ErrDuplicateColumn},
+		{"CREATE TABLE bad (a INT, b TEXT, FOREIGN KEY (a, b) REFERENCES stock (store, qty))", ErrInvalidForeignKey},
+		{"CREATE TABLE bad (a INT, b TEXT, FOREIGN KEY (b, a) REFERENCES stock)", ErrInvalidDatatype},
+	}
+
+	for _, test := range errors {
+		assert.Equal(t, test.err, execStatement(mb, test.stmt), test.stmt)
+		_, ok := mb.tables["bad"]
+		assert.False(t, ok, test.stmt)
+	}
+
+	assert.Equal(t, ErrInvalidIndexMethod, execStatement(mb, "CREATE INDEX bad_idx ON stock USING gin (sku, qty)"))
 }
 
 func TestCreateIndex(t *testing.T) {
@@ -163,7 +2726,7 @@ func TestCreateIndex(t *testing.T) {
 	err = mb.CreateIndex(ast.Statements[0].CreateIndexStatement)
 	assert.Nil(t, err)
 	assert.Equal(t, mb.tables["test"].indexes[0].name, "foo")
-	assert.Equal(t, mb.tables["test"].indexes[0].exp.GenerateCode(), `"x"`)
+	assert.Equal(t, mb.tables["test"].indexes[0].expressionsCode(), `"x"`)
 
 	// Second time, already exists
 	err = mb.CreateIndex(ast.Statements[0].CreateIndexStatement)
@@ -223,10 +2786,30 @@ func TestTable_GetApplicableIndexes(t *testing.T) {
 			"x = 2 AND y = 3",
 			[]string{`"x"`},
 		},
+		{
+			"2 < x AND 3 >= y",
+			[]string{`"x"`},
+		},
 		{
 			"x = 2 AND (y = 3 OR y = 5)",
 			[]string{`"x"`},
 		},
+		{
+			"x LIKE 'a%' AND y LIKE '%a'",
+			[]string{`"x"`},
+		},
+		{
+			"x LIKE '%a' OR x ILIKE 'a%' OR x LIKE 'a%' ESCAPE '!'",
+			[]string{},
+		},
+		{
+			"x IN (1, 2) AND x BETWEEN 1 AND 3",
+			[]string{`"x"`, `"x"`},
+		},
+		{
+			"x NOT IN (1, 2) AND x IN (y) AND x NOT BETWEEN 1 AND 3 AND x BETWEEN 1 AND y",
+			[]string{},
+		},
 	}
 
 	for _, test := range tests {
@@ -235,12 +2818,61 @@ func TestTable_GetApplicableIndexes(t *testing.T) {
 		where := ast.Statements[0].SelectStatement.Where
 		indexes := []string{}
 		for _, i := range mb.tables["test"].getApplicableIndexes(where) {
-			indexes = append(indexes, i.i.exp.GenerateCode())
+			indexes = append(indexes, i.i.expressionsCode())
 		}
 		assert.Equal(t, test.indexes, indexes, test.where)
 	}
 }
 
+func TestTable_GetApplicableIndexes_Prefix(t *testing.T) {
+	mb := NewMemoryBackend()
+	runStatements(t, mb,
+		"CREATE TABLE test (a INT, b INT, c INT, PRIMARY KEY (a, b, c))",
+	)
+
+	tests := []struct {
+		where string
+		exps  []string
+	}{
+		{
+			"a = 1",
+			[]string{`("a" = 1)`},
+		},
+		{
+			"b = 1 AND a = 2",
+			[]string{`(("a" = 2) and ("b" = 1))`, `("a" = 2)`},
+		},
+		{
+			"c = 3 AND 2 = b AND a = 1",
+			[]string{`((("a" = 1) and (2 = "b")) and ("c" = 3))`, `("a" = 1)`},
+		},
+		{
+			"a = 1 AND b > 2 AND c = 3",
+			[]string{`("a" = 1)`},
+		},
+		{
+			"b = 1 AND c = 2",
+			[]string{},
+		},
+		{
+			"a = 1 OR b = 2",
+			[]string{},
+		},
+	}
+
+	parser := Parser{HelpMessagesDisabled: true}
+	for _, test := range tests {
+		ast, err := parser.Parse(fmt.Sprintf("SELECT * FROM test WHERE %s", test.where))
+		assert.Nil(t, err, test.where)
+		where := ast.Statements[0].SelectStatement.Where
+		exps := []string{}
+		for _, i := range mb.tables["test"].getApplicableIndexes(where) {
+			exps = append(exps, i.e.GenerateCode())
+		}
+		assert.Equal(t, test.exps, exps, test.where)
+	}
+}
+
 func TestLiteralToMemoryCell(t *testing.T) {
 	var i *int32
 	assert.Equal(t, i, literalToMemoryCell(&Token{Value: "null", Kind: NullKind}).AsInt())
@@ -0,0 +1,908 @@
+package gosql
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numbers other than int are stored so that comparing their bytes
+// orders them like their values, which lets index trees hold them as
+// is. Integers have their sign bit flipped, negative floats have all
+// their bits inverted and positive ones only the sign bit, and
+// NUMERIC values are encoded by decimalToMemoryCell.
+
+func (mc memoryCell) AsSmallInt() *int16 {
+	if len(mc) < 2 {
+		return nil
+	}
+
+	i := int16(binary.BigEndian.Uint16(mc) ^ 1<<15)
+	return &i
+}
+
+func (mc memoryCell) AsBigInt() *int64 {
+	if len(mc) < 8 {
+		return nil
+	}
+
+	i := int64(binary.BigEndian.Uint64(mc) ^ 1<<63)
+	return &i
+}
+
+func (mc memoryCell) AsReal() *float32 {
+	if len(mc) < 4 {
+		return nil
+	}
+
+	bits := binary.BigEndian.Uint32(mc)
+	if bits&(1<<31) != 0 {
+		bits &^= 1 << 31
+	} else {
+		bits = ^bits
+	}
+
+	f := math.Float32frombits(bits)
+	return &f
+}
+
+func (mc memoryCell) AsDouble() *float64 {
+	if len(mc) < 8 {
+		return nil
+	}
+
+	bits := binary.BigEndian.Uint64(mc)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+
+	f := math.Float64frombits(bits)
+	return &f
+}
+
+func (mc memoryCell) AsNumeric() *string {
+	if len(mc) == 0 {
+		return nil
+	}
+
+	s := memoryCellToDecimal(mc).String()
+	return &s
+}
+
+func smallIntToMemoryCell(i int16) memoryCell {
+	mc := make(memoryCell, 2)
+	binary.BigEndian.PutUint16(mc, uint16(i)^1<<15)
+	return mc
+}
+
+func bigIntToMemoryCell(i int64) memoryCell {
+	mc := make(memoryCell, 8)
+	binary.BigEndian.PutUint64(mc, uint64(i)^1<<63)
+	return mc
+}
+
+func realToMemoryCell(f float32) memoryCell {
+	// -0 and 0 are equal so they must be stored the same way
+	if f == 0 {
+		f = 0
+	}
+
+	bits := math.Float32bits(f)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+
+	mc := make(memoryCell, 4)
+	binary.BigEndian.PutUint32(mc, bits)
+	return mc
+}
+
+func doubleToMemoryCell(f float64) memoryCell {
+	if f == 0 {
+		f = 0
+	}
+
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	mc := make(memoryCell, 8)
+	binary.BigEndian.PutUint64(mc, bits)
+	return mc
+}
+
+// decimal is an exact number, coef × 10^-scale. The scale is also
+// how many digits it shows after the decimal point, like Postgres
+// shows 1.50 rather than 1.5, so trailing zeros are kept.
+type decimal struct {
+	coef  *big.Int
+	scale int
+}
+
+// maxDecimalExponent bounds the exponent accepted when parsing so a
+// short literal like 1e999999999 can't expand into a huge number
+const maxDecimalExponent = 100000
+
+// divisionDigits is the minimum number of significant digits kept
+// when dividing
+const divisionDigits = 16
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// parseDecimal reads a number like 12, -1.5, .5 or 2.5e-3
+func parseDecimal(s string) (decimal, bool) {
+	s = strings.TrimSpace(s)
+	mantissa, exponent := s, 0
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > maxDecimalExponent || e < -maxDecimalExponent {
+			return decimal{}, false
+		}
+
+		mantissa, exponent = s[:i], e
+	}
+
+	negative := false
+	if strings.HasPrefix(mantissa, "-") || strings.HasPrefix(mantissa, "+") {
+		negative = mantissa[0] == '-'
+		mantissa = mantissa[1:]
+	}
+
+	integer, fraction := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i != -1 {
+		integer, fraction = mantissa[:i], mantissa[i+1:]
+	}
+
+	digits := integer + fraction
+	if digits == "" {
+		return decimal{}, false
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return decimal{}, false
+		}
+	}
+
+	coef, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		coef.Neg(coef)
+	}
+
+	return decimal{coef, len(fraction) - exponent}.normalize(), true
+}
+
+// normalize multiplies out a negative scale, since numbers show at
+// least their integer digits
+func (d decimal) normalize() decimal {
+	if d.scale >= 0 {
+		return d
+	}
+
+	return decimal{new(big.Int).Mul(d.coef, pow10(-d.scale)), 0}
+}
+
+// trim drops the trailing zeros after the decimal point
+func (d decimal) trim() decimal {
+	coef := new(big.Int).Set(d.coef)
+	scale := d.scale
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for scale > 0 {
+		quotient, _ := new(big.Int).QuoRem(coef, ten, remainder)
+		if remainder.Sign() != 0 {
+			break
+		}
+
+		coef = quotient
+		scale--
+	}
+
+	return decimal{coef, scale}
+}
+
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.coef).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+
+	if d.coef.Sign() < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// round rounds half away from zero to a number of digits after the
+// decimal point, which may be negative to round to tens, hundreds...
+// Numbers with fewer digits are padded with zeros.
+func (d decimal) round(scale int) decimal {
+	if scale >= d.scale {
+		return decimal{new(big.Int).Mul(d.coef, pow10(scale-d.scale)), scale}
+	}
+
+	unit := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(d.coef, unit, new(big.Int))
+	remainder.Abs(remainder)
+	if remainder.Lsh(remainder, 1).Cmp(unit) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(d.coef.Sign())))
+	}
+
+	return decimal{quotient, scale}.normalize()
+}
+
+// align returns the coefficients of d and o at their largest scale
+func (d decimal) align(o decimal) (*big.Int, *big.Int, int) {
+	a, b := new(big.Int).Set(d.coef), new(big.Int).Set(o.coef)
+	if d.scale < o.scale {
+		a.Mul(a, pow10(o.scale-d.scale))
+		return a, b, o.scale
+	}
+
+	b.Mul(b, pow10(d.scale-o.scale))
+	return a, b, d.scale
+}
+
+// weight returns the position of the first significant digit of d
+// in base 10000, counting from 0 for units, and the value of that
+// base 10000 digit. Postgres stores numbers in that base, which
+// decides the scale of quotients.
+func (d decimal) weight() (int, int64) {
+	digits := new(big.Int).Abs(d.coef).String()
+	if d.coef.Sign() == 0 {
+		return 0, 0
+	}
+
+	// The first digit is the units digit of 10^position
+	position := len(digits) - d.scale - 1
+	weight := position / 4
+	if position < 0 && position%4 != 0 {
+		weight--
+	}
+
+	length := position - weight*4 + 1
+	for len(digits) < length {
+		digits += "0"
+	}
+
+	first, _ := strconv.ParseInt(digits[:length], 10, 64)
+	return weight, first
+}
+
+// divisionScale returns the scale of d / o, enough for at least
+// divisionDigits significant digits as Postgres computes it
+func divisionScale(d, o decimal) int {
+	weight, first := d.weight()
+	otherWeight, otherFirst := o.weight()
+	quotientWeight := weight - otherWeight
+	if first <= otherFirst {
+		quotientWeight--
+	}
+
+	scale := divisionDigits - quotientWeight*4
+	if d.scale > scale {
+		scale = d.scale
+	}
+	if o.scale > scale {
+		scale = o.scale
+	}
+	if scale < 0 {
+		scale = 0
+	}
+
+	return scale
+}
+
+// integerDigits counts the digits before the decimal point
+func (d decimal) integerDigits() int {
+	if d.coef.Sign() == 0 {
+		return 0
+	}
+
+	digits := len(new(big.Int).Abs(d.coef).String()) - d.scale
+	if digits < 0 {
+		return 0
+	}
+
+	return digits
+}
+
+func decimalArithmetic(op Symbol, d, o decimal) (decimal, error) {
+	switch op {
+	case PlusSymbol:
+		a, b, scale := d.align(o)
+		return decimal{a.Add(a, b), scale}.normalize(), nil
+	case MinusSymbol:
+		a, b, scale := d.align(o)
+		return decimal{a.Sub(a, b), scale}.normalize(), nil
+	case AsteriskSymbol:
+		return decimal{new(big.Int).Mul(d.coef, o.coef), d.scale + o.scale}.normalize(), nil
+	case SlashSymbol:
+		if o.coef.Sign() == 0 {
+			return decimal{}, ErrDivisionByZero
+		}
+
+		scale := divisionScale(d, o)
+
+		// Truncate one digit further than needed and round that
+		// digit off
+		numerator := new(big.Int).Mul(d.coef, pow10(scale+1+o.scale-d.scale))
+		quotient := numerator.Quo(numerator, o.coef)
+		return decimal{quotient, scale + 1}.round(scale), nil
+	case PercentSymbol:
+		if o.coef.Sign() == 0 {
+			return decimal{}, ErrDivisionByZero
+		}
+
+		a, b, scale := d.align(o)
+		return decimal{a.Rem(a, b), scale}.normalize(), nil
+	}
+
+	return decimal{}, ErrInvalidOperands
+}
+
+// numericScaleSize is the size of the scale ending NUMERIC cells
+const numericScaleSize = 4
+
+// decimalToMemoryCell stores d so that the order of the bytes before
+// its scale is the order of the values. A sign byte comes first, then
+// for non-zero values the position of the decimal point and each
+// significant digit, with d read as 0.digits × 10^exponent. Negative
+// values have all of these inverted so that larger magnitudes sort
+// first, and end with 0xFF so that they sort before longer values
+// they are a prefix of. The scale follows, which only changes how
+// the value is shown.
+func decimalToMemoryCell(d decimal) memoryCell {
+	scale := make([]byte, numericScaleSize)
+	binary.BigEndian.PutUint32(scale, uint32(d.scale))
+
+	sign := d.coef.Sign()
+	if sign == 0 {
+		return append(memoryCell{1}, scale...)
+	}
+
+	digits := new(big.Int).Abs(d.coef).String()
+	exponent := len(digits) - d.scale
+	digits = strings.TrimRight(digits, "0")
+
+	mc := make(memoryCell, 5, 6+len(digits))
+	mc[0] = byte(sign + 1)
+	binary.BigEndian.PutUint32(mc[1:], uint32(int32(exponent))^1<<31)
+	for _, c := range digits {
+		// Digits are stored from 1 so that none inverts to 0xFF
+		mc = append(mc, byte(c-'0')+1)
+	}
+
+	if sign < 0 {
+		for i := 1; i < len(mc); i++ {
+			mc[i] = ^mc[i]
+		}
+		mc = append(mc, 0xFF)
+	}
+
+	return append(mc, scale...)
+}
+
+// numericValue returns a NUMERIC cell without its scale, so that
+// equal values have equal bytes
+func numericValue(mc memoryCell) memoryCell {
+	return mc[:len(mc)-numericScaleSize]
+}
+
+func memoryCellToDecimal(mc memoryCell) decimal {
+	scale := int(binary.BigEndian.Uint32(mc[len(mc)-numericScaleSize:]))
+	mc = numericValue(mc)
+	if mc[0] == 1 {
+		return decimal{new(big.Int), scale}
+	}
+
+	negative := mc[0] == 0
+	body := append(memoryCell{}, mc[1:]...)
+	if negative {
+		body = body[:len(body)-1]
+		for i := range body {
+			body[i] = ^body[i]
+		}
+	}
+
+	exponent := int(int32(binary.BigEndian.Uint32(body) ^ 1<<31))
+	digits := make([]byte, 0, len(body)-4)
+	for _, b := range body[4:] {
+		digits = append(digits, '0'+b-1)
+	}
+
+	coef, _ := new(big.Int).SetString(string(digits), 10)
+	if negative {
+		coef.Neg(coef)
+	}
+
+	return decimal{coef, len(digits) - exponent}.round(scale)
+}
+
+// numericRank orders the numeric types by the values they can hold.
+// Operations on numbers of different types convert both to the
+// higher ranked one. Other types have no rank.
+func numericRank(typ ColumnType) int {
+	switch typ {
+	case SmallIntType:
+		return 1
+	case IntType:
+		return 2
+	case BigIntType:
+		return 3
+	case NumericType:
+		return 4
+	case RealType:
+		return 5
+	case DoubleType:
+		return 6
+	}
+
+	return 0
+}
+
+func isNumericType(typ ColumnType) bool {
+	return numericRank(typ) > 0
+}
+
+func isIntegerType(typ ColumnType) bool {
+	return typ == SmallIntType || typ == IntType || typ == BigIntType
+}
+
+func isFloatType(typ ColumnType) bool {
+	return typ == RealType || typ == DoubleType
+}
+
+// commonNumericType is the type numbers of types a and b are
+// converted to before operating on them
+func commonNumericType(a, b ColumnType) ColumnType {
+	if numericRank(a) > numericRank(b) {
+		return a
+	}
+
+	return b
+}
+
+// numericLiteralType is the type of a number written in a query: int
+// if it fits, then bigint, and numeric for anything larger or with a
+// fractional part or an exponent
+func numericLiteralType(value string) ColumnType {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return NumericType
+	}
+
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return BigIntType
+	}
+
+	return IntType
+}
+
+// numericLiteralToMemoryCell stores a numeric literal as the
+// narrowest type it fits, failing if it is out of NUMERIC's range
+func numericLiteralToMemoryCell(value string) (memoryCell, error) {
+	typ := numericLiteralType(value)
+	if typ == NumericType {
+		d, ok := parseDecimal(value)
+		if !ok {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return decimalToMemoryCell(d), nil
+	}
+
+	i, _ := strconv.ParseInt(value, 10, 64)
+	return integerToMemoryCell(i, typ)
+}
+
+// integerToMemoryCell stores an integer as one of the integer types,
+// failing if it doesn't fit
+func integerToMemoryCell(i int64, typ ColumnType) (memoryCell, error) {
+	switch typ {
+	case SmallIntType:
+		if i < math.MinInt16 || i > math.MaxInt16 {
+			return nil, ErrIntegerOutOfRange
+		}
+
+		return smallIntToMemoryCell(int16(i)), nil
+	case IntType:
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, ErrIntegerOutOfRange
+		}
+
+		mc := make(memoryCell, 4)
+		binary.BigEndian.PutUint32(mc, uint32(int32(i)))
+		return mc, nil
+	case BigIntType:
+		return bigIntToMemoryCell(i), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// cellInt64 reads a non-NULL value of one of the integer types
+func cellInt64(value memoryCell, typ ColumnType) int64 {
+	switch typ {
+	case SmallIntType:
+		return int64(*value.AsSmallInt())
+	case IntType:
+		return int64(*value.AsInt())
+	}
+
+	return *value.AsBigInt()
+}
+
+// cellFloat reads a non-NULL number as a float, which is infinite
+// for NUMERIC values beyond the range of a double
+func cellFloat(value memoryCell, typ ColumnType) float64 {
+	switch typ {
+	case RealType:
+		return float64(*value.AsReal())
+	case DoubleType:
+		return *value.AsDouble()
+	case NumericType:
+		f, _ := strconv.ParseFloat(memoryCellToDecimal(value).String(), 64)
+		return f
+	}
+
+	return float64(cellInt64(value, typ))
+}
+
+// cellDecimal reads a non-NULL number exactly, floats are read as
+// the shortest decimal that converts back to them
+func cellDecimal(value memoryCell, typ ColumnType) (decimal, error) {
+	switch typ {
+	case NumericType:
+		return memoryCellToDecimal(value), nil
+	case RealType, DoubleType:
+		f := cellFloat(value, typ)
+		if math.IsInf(f, 0) {
+			return decimal{}, ErrNumericOutOfRange
+		}
+
+		bitSize := 64
+		if typ == RealType {
+			bitSize = 32
+		}
+
+		d, _ := parseDecimal(strconv.FormatFloat(f, 'e', -1, bitSize))
+		return d, nil
+	}
+
+	return decimal{big.NewInt(cellInt64(value, typ)), 0}, nil
+}
+
+func floatToMemoryCell(f float64, typ ColumnType) (memoryCell, error) {
+	if math.IsNaN(f) {
+		return nil, ErrNumericOutOfRange
+	}
+
+	if typ == RealType {
+		if !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return realToMemoryCell(float32(f)), nil
+	}
+
+	return doubleToMemoryCell(f), nil
+}
+
+// castNumber converts a non-NULL number between numeric types.
+// Conversions to integers round to the nearest one.
+func castNumber(value memoryCell, from, to ColumnType) (memoryCell, error) {
+	if from == to {
+		return value, nil
+	}
+
+	switch {
+	case isFloatType(to):
+		f := cellFloat(value, from)
+		if math.IsInf(f, 0) && !isFloatType(from) {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return floatToMemoryCell(f, to)
+	case isIntegerType(to) && isFloatType(from):
+		f := math.RoundToEven(cellFloat(value, from))
+		if math.IsInf(f, 0) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, ErrIntegerOutOfRange
+		}
+
+		return integerToMemoryCell(int64(f), to)
+	case isIntegerType(to):
+		d, err := cellDecimal(value, from)
+		if err != nil {
+			return nil, err
+		}
+
+		d = d.round(0)
+		if !d.coef.IsInt64() {
+			return nil, ErrIntegerOutOfRange
+		}
+
+		return integerToMemoryCell(d.coef.Int64(), to)
+	case to == NumericType:
+		d, err := cellDecimal(value, from)
+		if err != nil {
+			return nil, err
+		}
+
+		return decimalToMemoryCell(d), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+// formatFloat writes floats like Postgres, switching to an exponent
+// for very large or small magnitudes
+func formatFloat(f float64, bitSize int) string {
+	if math.IsInf(f, 1) {
+		return "Infinity"
+	} else if math.IsInf(f, -1) {
+		return "-Infinity"
+	}
+
+	maxDigits := 15
+	if bitSize == 32 {
+		maxDigits = 6
+	}
+
+	exponent := 0
+	if f != 0 {
+		exponent = int(math.Floor(math.Log10(math.Abs(f))))
+	}
+
+	if exponent < -4 || exponent >= maxDigits {
+		return strconv.FormatFloat(f, 'e', -1, bitSize)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, bitSize)
+}
+
+// numberToText writes a non-NULL number
+func numberToText(value memoryCell, typ ColumnType) string {
+	switch typ {
+	case RealType:
+		return formatFloat(float64(*value.AsReal()), 32)
+	case DoubleType:
+		return formatFloat(*value.AsDouble(), 64)
+	case NumericType:
+		return memoryCellToDecimal(value).String()
+	}
+
+	return strconv.FormatInt(cellInt64(value, typ), 10)
+}
+
+// textToNumber reads text as a number of type typ
+func textToNumber(s string, typ ColumnType) (memoryCell, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case isIntegerType(typ):
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			if errors.Is(err, strconv.ErrRange) {
+				return nil, ErrIntegerOutOfRange
+			}
+
+			return nil, ErrInvalidCast
+		}
+
+		return integerToMemoryCell(i, typ)
+	case isFloatType(typ):
+		f, err := strconv.ParseFloat(s, 64)
+		if (err != nil && !errors.Is(err, strconv.ErrRange)) || math.IsNaN(f) {
+			return nil, ErrInvalidCast
+		}
+		if err != nil {
+			return nil, ErrNumericOutOfRange
+		}
+
+		return floatToMemoryCell(f, typ)
+	case typ == NumericType:
+		d, ok := parseDecimal(s)
+		if !ok {
+			return nil, ErrInvalidCast
+		}
+
+		return decimalToMemoryCell(d), nil
+	}
+
+	return nil, ErrInvalidCast
+}
+
+func integerArithmetic(op Symbol, a, b int64, typ ColumnType) (memoryCell, error) {
+	x, y := big.NewInt(a), big.NewInt(b)
+	res := new(big.Int)
+	switch op {
+	case PlusSymbol:
+		res.Add(x, y)
+	case MinusSymbol:
+		res.Sub(x, y)
+	case AsteriskSymbol:
+		res.Mul(x, y)
+	case SlashSymbol, PercentSymbol:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if op == SlashSymbol {
+			res.Quo(x, y)
+		} else {
+			res.Rem(x, y)
+		}
+	default:
+		return nil, ErrInvalidOperands
+	}
+
+	if !res.IsInt64() {
+		return nil, ErrIntegerOutOfRange
+	}
+
+	return integerToMemoryCell(res.Int64(), typ)
+}
+
+func floatArithmetic(op Symbol, a, b float64, typ ColumnType) (memoryCell, error) {
+	var res float64
+	switch op {
+	case PlusSymbol:
+		res = a + b
+	case MinusSymbol:
+		res = a - b
+	case AsteriskSymbol:
+		res = a * b
+	case SlashSymbol:
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		res = a / b
+	default:
+		return nil, ErrInvalidOperands
+	}
+
+	// Overflowing to infinity is an error, operating on one isn't
+	if math.IsInf(res, 0) && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+		return nil, ErrNumericOutOfRange
+	}
+
+	return floatToMemoryCell(res, typ)
+}
+
+// numericArithmetic applies an arithmetic operator to two non-NULL
+// numbers, converting both to their common type first. The result
+// has that type and it is an error for it not to fit.
+func numericArithmetic(op Symbol, l memoryCell, lt ColumnType, r memoryCell, rt ColumnType) (memoryCell, ColumnType, error) {
+	typ := commonNumericType(lt, rt)
+	l, err := castNumber(l, lt, typ)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r, err = castNumber(r, rt, typ)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var res memoryCell
+	switch {
+	case isIntegerType(typ):
+		res, err = integerArithmetic(op, cellInt64(l, typ), cellInt64(r, typ), typ)
+	case isFloatType(typ):
+		res, err = floatArithmetic(op, cellFloat(l, typ), cellFloat(r, typ), typ)
+	default:
+		var d decimal
+		d, err = decimalArithmetic(op, memoryCellToDecimal(l), memoryCellToDecimal(r))
+		if err == nil {
+			res = decimalToMemoryCell(d)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, typ, nil
+}
+
+// compareValues orders two non-NULL values. Numbers of different
+// types are converted to their common type first, as are dates
+// compared to timestamps and the elements of arrays. Other values can
+// only be compared to values of the same type.
+func compareValues(a memoryCell, at ColumnType, b memoryCell, bt ColumnType) (int, error) {
+	if at == bt {
+		return compareCells(a, b, at), nil
+	}
+
+	if isDatetimeType(at) && isDatetimeType(bt) {
+		return compareDatetimes(a, at, b, bt)
+	}
+
+	if at.IsArray() && bt.IsArray() {
+		typ, ok := commonElementType(at.ElementType(), bt.ElementType())
+		if !ok {
+			return 0, ErrInvalidOperands
+		}
+
+		a, err := castArray(a, at, ArrayType|typ)
+		if err != nil {
+			return 0, err
+		}
+
+		b, err = castArray(b, bt, ArrayType|typ)
+		if err != nil {
+			return 0, err
+		}
+
+		return compareArrays(a, b, typ), nil
+	}
+
+	if !isNumericType(at) || !isNumericType(bt) {
+		return 0, ErrInvalidOperands
+	}
+
+	typ := commonNumericType(at, bt)
+	a, err := castNumber(a, at, typ)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err = castNumber(b, bt, typ)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareCells(a, b, typ), nil
+}
+
+// orderable reports whether values of types a and b can be compared
+// with < and >
+func orderable(a, b ColumnType) bool {
+	if a.IsArray() && b.IsArray() {
+		_, ok := commonElementType(a.ElementType(), b.ElementType())
+		return ok
+	}
+
+	if a == b && (a == TextType || a == BoolType || isBinaryType(a)) {
+		return true
+	}
+
+	return (isNumericType(a) && isNumericType(b)) || (isDatetimeType(a) && isDatetimeType(b))
+}
+
+// numericPrecision is the precision and scale of a NUMERIC(p, s)
+// column or cast
+type numericPrecision struct {
+	precision int
+	scale     int
+}
+
+// maxNumericPrecision is the largest precision NUMERIC(p, s) accepts
+const maxNumericPrecision = 1000
+
+// apply rounds a NUMERIC value to the scale, failing if it then has
+// more digits than the precision allows
+func (np numericPrecision) apply(value memoryCell) (memoryCell, error) {
+	d := memoryCellToDecimal(value).round(np.scale)
+	if d.integerDigits() > np.precision-np.scale {
+		return nil, ErrNumericOutOfRange
+	}
+
+	return decimalToMemoryCell(d), nil
+}
@@ -3,6 +3,7 @@ package gosql
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 func tokenFromKeyword(k Keyword) Token {
@@ -71,118 +72,1707 @@ func (p Parser) parseToken(tokens []*Token, initialCursor uint, t Token) (*Token
 	return nil, initialCursor, false
 }
 
+// parseContextualKeyword looks for a keyword that isn't reserved. It
+// is lexed as an identifier so that it can name columns, and is only
+// taken as the keyword where the parser expects it.
+func (p Parser) parseContextualKeyword(tokens []*Token, initialCursor uint, k Keyword) (*Token, uint, bool) {
+	t, cursor, ok := p.parseToken(tokens, initialCursor, Token{Kind: IdentifierKind, Value: string(k)})
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	return &Token{
+		Value: string(k),
+		Kind:  KeywordKind,
+		Loc:   t.Loc,
+	}, cursor, true
+}
+
 func (p Parser) parseLiteralExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
 	cursor := initialCursor
 
-	kinds := []TokenKind{IdentifierKind, NumericKind, StringKind, BoolKind, NullKind}
-	for _, kind := range kinds {
-		t, newCursor, ok := p.parseTokenKind(tokens, cursor, kind)
-		if ok {
-			return &Expression{
-				Literal: t,
-				Kind:    LiteralKind,
-			}, newCursor, true
+	kinds := []TokenKind{IdentifierKind, NumericKind, StringKind, BoolKind, NullKind}
+	for _, kind := range kinds {
+		t, newCursor, ok := p.parseTokenKind(tokens, cursor, kind)
+		if ok {
+			return &Expression{
+				Literal: t,
+				Kind:    LiteralKind,
+			}, newCursor, true
+		}
+	}
+
+	return nil, initialCursor, false
+}
+
+// parseCallExpression looks for a function call, e.g. lower(name) or
+// count(*)
+func (p Parser) parseCallExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	name, cursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	call := CallExpression{Name: *name}
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol))
+	if ok {
+		call.Asterisk = true
+	} else if args, newCursor, ok := p.parsePositionArgs(tokens, cursor, name); ok {
+		cursor = newCursor
+		call.Args = args
+	} else if args, newCursor, ok := p.parseExtractArgs(tokens, cursor, name); ok {
+		cursor = newCursor
+		call.Args = args
+	} else {
+		_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(DistinctKeyword))
+		if ok {
+			call.Distinct = true
+			cursor = newCursor
+		}
+
+		args, newCursor, ok := p.parseExpressions(tokens, cursor, []Token{rightParenToken})
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected function arguments")
+			return nil, initialCursor, false
+		}
+
+		cursor = newCursor
+		call.Args = args
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Call: &call,
+		Kind: CallKind,
+	}, cursor, true
+}
+
+// parsePositionArgs parses the SQL standard arguments of position,
+// substring IN string, as the two arguments of a regular call
+func (p Parser) parsePositionArgs(tokens []*Token, initialCursor uint, name *Token) (*[]*Expression, uint, bool) {
+	cursor := initialCursor
+	if name.Value != "position" {
+		return nil, initialCursor, false
+	}
+
+	inToken := tokenFromKeyword(InKeyword)
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+
+	substring, cursor, ok := p.parseExpression(tokens, cursor, []Token{inToken, tokenFromSymbol(CommaSymbol), rightParenToken}, 0)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, inToken)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	s, cursor, ok := p.parseExpression(tokens, cursor, []Token{rightParenToken}, 0)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected string after IN")
+		return nil, initialCursor, false
+	}
+
+	return &[]*Expression{substring, s}, cursor, true
+}
+
+// parseExtractArgs parses the SQL standard arguments of extract,
+// field FROM source, as the name of the field and the source
+func (p Parser) parseExtractArgs(tokens []*Token, initialCursor uint, name *Token) (*[]*Expression, uint, bool) {
+	cursor := initialCursor
+	if name.Value != "extract" {
+		return nil, initialCursor, false
+	}
+
+	field, cursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		field, cursor, ok = p.parseTokenKind(tokens, cursor, StringKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(FromKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	source, cursor, ok := p.parseExpression(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)}, 0)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected expression after FROM")
+		return nil, initialCursor, false
+	}
+
+	unit := Expression{
+		Literal: &Token{Value: field.Value, Kind: StringKind, Loc: field.Loc},
+		Kind:    LiteralKind,
+	}
+	return &[]*Expression{&unit, source}, cursor, true
+}
+
+// parseCurrentExpression parses CURRENT_DATE or CURRENT_TIMESTAMP,
+// which are function calls written without parens
+func (p Parser) parseCurrentExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	for _, keyword := range []Keyword{CurrentDateKeyword, CurrentTimestampKeyword} {
+		name, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(keyword))
+		if ok {
+			return &Expression{
+				Call: &CallExpression{
+					Name: *name,
+					Args: &[]*Expression{},
+				},
+				Kind: CallKind,
+			}, cursor, true
+		}
+	}
+
+	return nil, initialCursor, false
+}
+
+// parseTypedLiteralExpression parses a string preceded by its type,
+// e.g. DATE '2024-01-01' or JSONB '{"a": 1}', as a cast of the string
+func (p Parser) parseTypedLiteralExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	types := []Token{
+		tokenFromKeyword(TimestampKeyword),
+		tokenFromKeyword(IntervalKeyword),
+		tokenFromKeyword(ByteaKeyword),
+		tokenFromKeyword(UuidKeyword),
+		tokenFromKeyword(JsonKeyword),
+		tokenFromKeyword(JsonbKeyword),
+	}
+
+	var datatype *Token
+	for _, typ := range types {
+		var ok bool
+		datatype, cursor, ok = p.parseToken(tokens, cursor, typ)
+		if ok {
+			break
+		}
+	}
+
+	// DATE and TIME also name columns, so they are only types when a
+	// string follows
+	if datatype == nil {
+		for _, k := range []Keyword{DateKeyword, TimeKeyword} {
+			var ok bool
+			datatype, cursor, ok = p.parseContextualKeyword(tokens, cursor, k)
+			if !ok {
+				continue
+			}
+
+			if _, _, ok := p.parseTokenKind(tokens, cursor, StringKind); !ok {
+				return nil, initialCursor, false
+			}
+			break
+		}
+	}
+
+	if datatype == nil {
+		return nil, initialCursor, false
+	}
+
+	value, cursor, ok := p.parseTokenKind(tokens, cursor, StringKind)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected string after "+datatype.Value)
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Cast: &CastExpression{
+			Exp: Expression{
+				Literal: value,
+				Kind:    LiteralKind,
+			},
+			Datatype: *datatype,
+		},
+		Kind: CastKind,
+	}, cursor, true
+}
+
+// parseQualifiedExpression looks for a column prefixed by its table,
+// e.g. users.id
+func (p Parser) parseQualifiedExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	table, cursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(DotSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	column, cursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected column name after table")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Qualified: &QualifiedColumn{
+			Table:  *table,
+			Column: *column,
+		},
+		Kind: QualifiedKind,
+	}, cursor, true
+}
+
+// parseSubquery looks for a SELECT in parens
+func (p Parser) parseSubquery(tokens []*Token, initialCursor uint) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+	slct, cursor, ok := p.parseSelectStatement(tokens, cursor, rightParenToken)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren after subquery")
+		return nil, initialCursor, false
+	}
+
+	return slct, cursor, true
+}
+
+// parseInExpression looks for [NOT] IN followed by a subquery or a
+// list of values, after the expression being checked
+func (p Parser) parseInExpression(tokens []*Token, initialCursor uint, exp Expression) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, not := p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(InKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	if subquery, newCursor, ok := p.parseSubquery(tokens, cursor); ok {
+		return &Expression{
+			In: &InExpression{
+				Exp:      exp,
+				Not:      not,
+				Subquery: subquery,
+			},
+			Kind: InKind,
+		}, newCursor, true
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected subquery or list of values after IN")
+		return nil, initialCursor, false
+	}
+
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+	list, cursor, ok := p.parseExpressions(tokens, cursor, []Token{rightParenToken})
+	if !ok || len(*list) == 0 {
+		p.helpMessage(tokens, cursor, "Expected list of values after IN")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren after IN list")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		In: &InExpression{
+			Exp:  exp,
+			Not:  not,
+			List: list,
+		},
+		Kind: InKind,
+	}, cursor, true
+}
+
+// parseBetweenExpression looks for [NOT] BETWEEN low AND high after
+// the expression being checked
+func (p Parser) parseBetweenExpression(tokens []*Token, initialCursor uint, delimiters []Token, exp Expression) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, not := p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+	between, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(BetweenKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	// AND binds more loosely than BETWEEN, so it ends the lower bound
+	bp := between.bindingPower()
+	low, cursor, ok := p.parseExpression(tokens, cursor, delimiters, bp+1)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected lower bound after BETWEEN")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(AndKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected AND after lower bound of BETWEEN")
+		return nil, initialCursor, false
+	}
+
+	high, cursor, ok := p.parseExpression(tokens, cursor, delimiters, bp+1)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected upper bound after BETWEEN")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Between: &BetweenExpression{
+			Exp:  exp,
+			Not:  not,
+			Low:  *low,
+			High: *high,
+		},
+		Kind: BetweenKind,
+	}, cursor, true
+}
+
+// parseIsExpression parses IS [NOT] NULL and IS [NOT] DISTINCT FROM
+// applied to an already parsed expression
+func (p Parser) parseIsExpression(tokens []*Token, initialCursor uint, delimiters []Token, exp Expression) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	is, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(IsKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, not := p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+
+	if _, newCursor, ok := p.parseToken(tokens, cursor, Token{Kind: NullKind, Value: string(NullKeyword)}); ok {
+		return &Expression{
+			Is: &IsExpression{
+				Exp: exp,
+				Not: not,
+			},
+			Kind: IsKind,
+		}, newCursor, true
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(DistinctKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected NULL or DISTINCT FROM after IS")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(FromKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected FROM after IS DISTINCT")
+		return nil, initialCursor, false
+	}
+
+	b, cursor, ok := p.parseExpression(tokens, cursor, delimiters, is.bindingPower()+1)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected expression after DISTINCT FROM")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Is: &IsExpression{
+			Exp:          exp,
+			Not:          not,
+			DistinctFrom: b,
+		},
+		Kind: IsKind,
+	}, cursor, true
+}
+
+// parsePrefixExpression looks for NOT or - followed by the operand
+// they apply to
+func (p Parser) parsePrefixExpression(tokens []*Token, initialCursor uint, delimiters []Token) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	prefixOps := []Token{
+		tokenFromKeyword(NotKeyword),
+		tokenFromSymbol(MinusSymbol),
+	}
+
+	var op *Token
+	for _, po := range prefixOps {
+		var ok bool
+		op, cursor, ok = p.parseToken(tokens, cursor, po)
+		if ok {
+			break
+		}
+	}
+
+	if op == nil {
+		return nil, initialCursor, false
+	}
+
+	operand, cursor, ok := p.parseExpression(tokens, cursor, delimiters, op.prefixBindingPower())
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected operand after "+op.Value)
+		return nil, initialCursor, false
+	}
+
+	// Negative numbers are kept as literals, negating an already
+	// negative one drops its sign
+	if op.Kind == SymbolKind && operand.Kind == LiteralKind && operand.Literal.Kind == NumericKind {
+		value := op.Value + operand.Literal.Value
+		if strings.HasPrefix(operand.Literal.Value, op.Value) {
+			value = operand.Literal.Value[len(op.Value):]
+		}
+
+		return &Expression{
+			Literal: &Token{
+				Value: value,
+				Kind:  NumericKind,
+				Loc:   op.Loc,
+			},
+			Kind: LiteralKind,
+		}, cursor, true
+	}
+
+	return &Expression{
+		Unary: &UnaryExpression{
+			Op:  *op,
+			Exp: *operand,
+		},
+		Kind: UnaryKind,
+	}, cursor, true
+}
+
+// parseCaseExpression parses CASE [operand] WHEN ... THEN ...
+// [ELSE ...] END
+func (p Parser) parseCaseExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(CaseKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	whenToken := tokenFromKeyword(WhenKeyword)
+	thenToken := tokenFromKeyword(ThenKeyword)
+	elseToken := tokenFromKeyword(ElseKeyword)
+	endToken := tokenFromKeyword(EndKeyword)
+
+	ce := CaseExpression{Whens: &[]*CaseWhen{}}
+	if _, _, ok = p.parseToken(tokens, cursor, whenToken); !ok {
+		ce.Operand, cursor, ok = p.parseExpression(tokens, cursor, []Token{whenToken}, 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected expression or WHEN after CASE")
+			return nil, initialCursor, false
+		}
+	}
+
+	for {
+		_, newCursor, ok := p.parseToken(tokens, cursor, whenToken)
+		if !ok {
+			break
+		}
+
+		when, newCursor, ok := p.parseExpression(tokens, newCursor, []Token{thenToken}, 0)
+		if !ok {
+			p.helpMessage(tokens, newCursor, "Expected expression after WHEN")
+			return nil, initialCursor, false
+		}
+
+		_, newCursor, ok = p.parseToken(tokens, newCursor, thenToken)
+		if !ok {
+			p.helpMessage(tokens, newCursor, "Expected THEN")
+			return nil, initialCursor, false
+		}
+
+		then, newCursor, ok := p.parseExpression(tokens, newCursor, []Token{whenToken, elseToken, endToken}, 0)
+		if !ok {
+			p.helpMessage(tokens, newCursor, "Expected expression after THEN")
+			return nil, initialCursor, false
+		}
+
+		*ce.Whens = append(*ce.Whens, &CaseWhen{When: *when, Then: *then})
+		cursor = newCursor
+	}
+
+	if len(*ce.Whens) == 0 {
+		p.helpMessage(tokens, cursor, "Expected WHEN")
+		return nil, initialCursor, false
+	}
+
+	if _, newCursor, ok := p.parseToken(tokens, cursor, elseToken); ok {
+		ce.Else, cursor, ok = p.parseExpression(tokens, newCursor, []Token{endToken}, 0)
+		if !ok {
+			p.helpMessage(tokens, newCursor, "Expected expression after ELSE")
+			return nil, initialCursor, false
+		}
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, endToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected END")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Case: &ce,
+		Kind: CaseKind,
+	}, cursor, true
+}
+
+// parseConditionalExpression parses COALESCE, NULLIF, GREATEST or
+// LEAST and their arguments
+func (p Parser) parseConditionalExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	functions := []Token{
+		tokenFromKeyword(CoalesceKeyword),
+		tokenFromKeyword(NullifKeyword),
+		tokenFromKeyword(GreatestKeyword),
+		tokenFromKeyword(LeastKeyword),
+	}
+
+	var function *Token
+	for _, f := range functions {
+		var ok bool
+		function, cursor, ok = p.parseToken(tokens, cursor, f)
+		if ok {
+			break
+		}
+	}
+
+	if function == nil {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected opening paren after "+function.Value)
+		return nil, initialCursor, false
+	}
+
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+	args, cursor, ok := p.parseExpressions(tokens, cursor, []Token{rightParenToken})
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	if len(*args) == 0 || (Keyword(function.Value) == NullifKeyword && len(*args) != 2) {
+		p.helpMessage(tokens, cursor, "Wrong number of arguments to "+function.Value)
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Conditional: &ConditionalExpression{
+			Function: *function,
+			Args:     args,
+		},
+		Kind: ConditionalKind,
+	}, cursor, true
+}
+
+// parseCastExpression parses CAST(x AS type)
+func (p Parser) parseCastExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(CastKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected opening paren after CAST")
+		return nil, initialCursor, false
+	}
+
+	asToken := tokenFromKeyword(AsKeyword)
+	exp, cursor, ok := p.parseExpression(tokens, cursor, []Token{asToken}, 0)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected expression to cast")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, asToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected AS")
+		return nil, initialCursor, false
+	}
+
+	datatype, modifiers, array, cursor, ok := p.parseDatatype(tokens, cursor)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected type")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Cast: &CastExpression{
+			Exp:       *exp,
+			Datatype:  *datatype,
+			Modifiers: modifiers,
+			Array:     array,
+		},
+		Kind: CastKind,
+	}, cursor, true
+}
+
+// parseDatatype parses a type and the modifiers that may follow it,
+// like the precision and scale in NUMERIC(10, 2), and whether it is
+// an array of the type, like int[]
+func (p Parser) parseDatatype(tokens []*Token, initialCursor uint) (*Token, *[]*Token, bool, uint, bool) {
+	cursor := initialCursor
+
+	datatype, cursor, ok := p.parseTokenKind(tokens, cursor, KeywordKind)
+	for _, k := range []Keyword{DateKeyword, TimeKeyword} {
+		if ok {
+			break
+		}
+		datatype, cursor, ok = p.parseContextualKeyword(tokens, cursor, k)
+	}
+	if !ok {
+		return nil, nil, false, initialCursor, false
+	}
+
+	var modifiers *[]*Token
+	if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)); ok {
+		cursor = newCursor
+		modifiers = &[]*Token{}
+		for {
+			modifier, newCursor, ok := p.parseTokenKind(tokens, cursor, NumericKind)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected type modifier")
+				return nil, nil, false, initialCursor, false
+			}
+			cursor = newCursor
+			*modifiers = append(*modifiers, modifier)
+
+			_, newCursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+			if !ok {
+				break
+			}
+			cursor = newCursor
+		}
+
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected closing paren")
+			return nil, nil, false, initialCursor, false
+		}
+	}
+
+	// Brackets only make an array type when empty, so that a cast
+	// can still be followed by a subscript
+	_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftBracketSymbol))
+	if !ok {
+		return datatype, modifiers, false, cursor, true
+	}
+
+	_, newCursor, ok = p.parseToken(tokens, newCursor, tokenFromSymbol(RightBracketSymbol))
+	if !ok {
+		return datatype, modifiers, false, cursor, true
+	}
+
+	return datatype, modifiers, true, newCursor, true
+}
+
+// parseArrayExpression looks for ARRAY[...]
+func (p Parser) parseArrayExpression(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(ArrayKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftBracketSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected opening bracket after ARRAY")
+		return nil, initialCursor, false
+	}
+
+	rightBracketToken := tokenFromSymbol(RightBracketSymbol)
+	items, cursor, ok := p.parseExpressions(tokens, cursor, []Token{rightBracketToken})
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected array elements")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightBracketToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing bracket")
+		return nil, initialCursor, false
+	}
+
+	return &Expression{
+		Array: &ArrayExpression{Items: items},
+		Kind:  ArrayKind,
+	}, cursor, true
+}
+
+// parseQuantifiedOperand looks for ANY or ALL followed by a subquery
+// or an array in parens, after a comparison operator
+func (p Parser) parseQuantifiedOperand(tokens []*Token, initialCursor uint, op *Token) (*Token, *Expression, uint, bool) {
+	cursor := initialCursor
+
+	if op.Kind != SymbolKind || op.bindingPower() != tokenFromSymbol(EqSymbol).bindingPower() {
+		return nil, nil, initialCursor, false
+	}
+
+	quantifier, cursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(AnyKeyword))
+	if !ok {
+		quantifier, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(AllKeyword))
+		if !ok {
+			return nil, nil, initialCursor, false
+		}
+	}
+
+	if subquery, newCursor, ok := p.parseSubquery(tokens, cursor); ok {
+		return quantifier, &Expression{
+			Subquery: &SubqueryExpression{
+				Select: subquery,
+			},
+			Kind: SubqueryKind,
+		}, newCursor, true
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected opening paren after "+quantifier.Value)
+		return nil, nil, initialCursor, false
+	}
+
+	rightParenToken := tokenFromSymbol(RightParenSymbol)
+	b, cursor, ok := p.parseExpression(tokens, cursor, []Token{rightParenToken}, 0)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected array after "+quantifier.Value)
+		return nil, nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, nil, initialCursor, false
+	}
+
+	return quantifier, b, cursor, true
+}
+
+func (p Parser) parseExpression(tokens []*Token, initialCursor uint, delimiters []Token, minBp uint) (*Expression, uint, bool) {
+	cursor := initialCursor
+
+	var exp *Expression
+	_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(ExistsKeyword))
+	if ok {
+		subquery, newCursor, ok := p.parseSubquery(tokens, newCursor)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected subquery after EXISTS")
+			return nil, initialCursor, false
+		}
+
+		cursor = newCursor
+		exp = &Expression{
+			Subquery: &SubqueryExpression{
+				Select: subquery,
+				Exists: true,
+			},
+			Kind: SubqueryKind,
+		}
+	} else if subquery, newCursor, ok := p.parseSubquery(tokens, cursor); ok {
+		cursor = newCursor
+		exp = &Expression{
+			Subquery: &SubqueryExpression{
+				Select: subquery,
+			},
+			Kind: SubqueryKind,
+		}
+	} else if exp, newCursor, ok = p.parsePrefixExpression(tokens, cursor, delimiters); ok {
+		cursor = newCursor
+	} else if _, newCursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)); ok {
+		cursor = newCursor
+		RightParenToken := tokenFromSymbol(RightParenSymbol)
+
+		exp, cursor, ok = p.parseExpression(tokens, cursor, append(delimiters, RightParenToken), 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected expression after opening paren")
+			return nil, initialCursor, false
+		}
+
+		_, cursor, ok = p.parseToken(tokens, cursor, RightParenToken)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected closing paren")
+			return nil, initialCursor, false
+		}
+	} else if exp, newCursor, ok = p.parseCastExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseCaseExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseConditionalExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseArrayExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseTypedLiteralExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseCurrentExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseCallExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else if exp, newCursor, ok = p.parseQualifiedExpression(tokens, cursor); ok {
+		cursor = newCursor
+	} else {
+		exp, cursor, ok = p.parseLiteralExpression(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	lastCursor := cursor
+outer:
+	for cursor < uint(len(tokens)) {
+		for _, d := range delimiters {
+			_, _, ok = p.parseToken(tokens, cursor, d)
+			if ok {
+				break outer
+			}
+		}
+
+		// IN isn't a binary operator since its right side is a
+		// subquery or list rather than an expression
+		_, inCursor, _ := p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+		if in, _, ok := p.parseToken(tokens, inCursor, tokenFromKeyword(InKeyword)); ok {
+			if in.bindingPower() < minBp {
+				break
+			}
+
+			exp, cursor, ok = p.parseInExpression(tokens, cursor, *exp)
+			if !ok {
+				return nil, initialCursor, false
+			}
+
+			lastCursor = cursor
+			continue
+		}
+
+		if cast, newCursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(CastSymbol)); ok {
+			if cast.bindingPower() < minBp {
+				break
+			}
+
+			datatype, modifiers, array, newCursor, ok := p.parseDatatype(tokens, newCursor)
+			if !ok {
+				p.helpMessage(tokens, newCursor, "Expected type after ::")
+				return nil, initialCursor, false
+			}
+
+			exp = &Expression{
+				Cast: &CastExpression{
+					Exp:       *exp,
+					Datatype:  *datatype,
+					Modifiers: modifiers,
+					Array:     array,
+				},
+				Kind: CastKind,
+			}
+			cursor = newCursor
+			lastCursor = cursor
+			continue
+		}
+
+		if bracket, newCursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftBracketSymbol)); ok {
+			if bracket.bindingPower() < minBp {
+				break
+			}
+
+			rightBracketToken := tokenFromSymbol(RightBracketSymbol)
+			index, newCursor, ok := p.parseExpression(tokens, newCursor, []Token{rightBracketToken}, 0)
+			if !ok {
+				p.helpMessage(tokens, newCursor, "Expected subscript")
+				return nil, initialCursor, false
+			}
+
+			_, newCursor, ok = p.parseToken(tokens, newCursor, rightBracketToken)
+			if !ok {
+				p.helpMessage(tokens, newCursor, "Expected closing bracket")
+				return nil, initialCursor, false
+			}
+
+			exp = &Expression{
+				Subscript: &SubscriptExpression{
+					Exp:   *exp,
+					Index: *index,
+				},
+				Kind: SubscriptKind,
+			}
+			cursor = newCursor
+			lastCursor = cursor
+			continue
+		}
+
+		if between, _, ok := p.parseToken(tokens, inCursor, tokenFromKeyword(BetweenKeyword)); ok {
+			if between.bindingPower() < minBp {
+				break
+			}
+
+			exp, cursor, ok = p.parseBetweenExpression(tokens, cursor, delimiters, *exp)
+			if !ok {
+				return nil, initialCursor, false
+			}
+
+			lastCursor = cursor
+			continue
+		}
+
+		if is, _, ok := p.parseToken(tokens, cursor, tokenFromKeyword(IsKeyword)); ok {
+			if is.bindingPower() < minBp {
+				break
+			}
+
+			exp, cursor, ok = p.parseIsExpression(tokens, cursor, delimiters, *exp)
+			if !ok {
+				return nil, initialCursor, false
+			}
+
+			lastCursor = cursor
+			continue
+		}
+
+		binOps := []Token{
+			tokenFromKeyword(AndKeyword),
+			tokenFromKeyword(OrKeyword),
+			tokenFromSymbol(EqSymbol),
+			tokenFromSymbol(NeqSymbol),
+			tokenFromSymbol(LtSymbol),
+			tokenFromSymbol(LteSymbol),
+			tokenFromSymbol(GtSymbol),
+			tokenFromSymbol(GteSymbol),
+			tokenFromSymbol(ConcatSymbol),
+			tokenFromSymbol(PlusSymbol),
+			tokenFromSymbol(MinusSymbol),
+			tokenFromSymbol(AsteriskSymbol),
+			tokenFromSymbol(SlashSymbol),
+			tokenFromSymbol(PercentSymbol),
+			tokenFromSymbol(TildeSymbol),
+			tokenFromSymbol(TildeStarSymbol),
+			tokenFromSymbol(NotTildeSymbol),
+			tokenFromSymbol(NotTildeStarSymbol),
+			tokenFromSymbol(ArrowSymbol),
+			tokenFromSymbol(ArrowTextSymbol),
+			tokenFromSymbol(PathSymbol),
+			tokenFromSymbol(PathTextSymbol),
+			tokenFromSymbol(ContainsSymbol),
+			tokenFromSymbol(ContainedBySymbol),
+			tokenFromSymbol(OverlapSymbol),
+			tokenFromKeyword(LikeKeyword),
+			tokenFromKeyword(IlikeKeyword),
+		}
+
+		// Besides IN and BETWEEN, NOT may only come before LIKE and
+		// ILIKE
+		var not bool
+		_, cursor, not = p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+
+		var op *Token
+		for _, bo := range binOps {
+			var t *Token
+			t, cursor, ok = p.parseToken(tokens, cursor, bo)
+			if ok {
+				op = t
+				break
+			}
+		}
+
+		if op == nil {
+			p.helpMessage(tokens, cursor, "Expected binary operator")
+			return nil, initialCursor, false
+		}
+
+		isLike := op.Kind == KeywordKind && (Keyword(op.Value) == LikeKeyword || Keyword(op.Value) == IlikeKeyword)
+		if not && !isLike {
+			p.helpMessage(tokens, cursor, "Expected IN, BETWEEN, LIKE or ILIKE after NOT")
+			return nil, initialCursor, false
+		}
+
+		bp := op.bindingPower()
+		if bp < minBp {
+			cursor = lastCursor
+			break
+		}
+
+		// A comparison may instead be made with each element of an
+		// array or each row of a subquery
+		if quantifier, b, newCursor, ok := p.parseQuantifiedOperand(tokens, cursor, op); ok {
+			exp = &Expression{
+				Binary: &BinaryExpression{
+					A:          *exp,
+					B:          *b,
+					Op:         *op,
+					Quantifier: quantifier,
+				},
+				Kind: BinaryKind,
+			}
+			cursor = newCursor
+			lastCursor = cursor
+			continue
+		}
+
+		rightDelimiters := delimiters
+		if isLike {
+			rightDelimiters = append(delimiters, tokenFromKeyword(EscapeKeyword))
+		}
+
+		// Only operators binding more tightly are part of the right
+		// operand, so operators of the same power associate left
+		b, newCursor, ok := p.parseExpression(tokens, cursor, rightDelimiters, bp+1)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected right operand")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		var escape *Expression
+		if _, newCursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(EscapeKeyword)); ok && isLike {
+			escape, cursor, ok = p.parseExpression(tokens, newCursor, delimiters, bp+1)
+			if !ok {
+				p.helpMessage(tokens, newCursor, "Expected expression after ESCAPE")
+				return nil, initialCursor, false
+			}
+		}
+
+		exp = &Expression{
+			Binary: &BinaryExpression{
+				A:      *exp,
+				B:      *b,
+				Op:     *op,
+				Escape: escape,
+			},
+			Kind: BinaryKind,
+		}
+		if not {
+			exp = &Expression{
+				Unary: &UnaryExpression{
+					Op:  tokenFromKeyword(NotKeyword),
+					Exp: *exp,
+				},
+				Kind: UnaryKind,
+			}
+		}
+		lastCursor = cursor
+	}
+
+	return exp, cursor, true
+}
+
+func (p Parser) parseSelectItem(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*SelectItem, uint, bool) {
+	cursor := initialCursor
+
+	var s []*SelectItem
+outer:
+	for {
+		if cursor >= uint(len(tokens)) {
+			return nil, initialCursor, false
+		}
+
+		current := tokens[cursor]
+		for _, delimiter := range delimiters {
+			if delimiter.equals(current) {
+				break outer
+			}
+		}
+
+		var ok bool
+		if len(s) > 0 {
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected comma")
+				return nil, initialCursor, false
+			}
+		}
+
+		var si SelectItem
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol))
+		if ok {
+			si = SelectItem{Asterisk: true}
+		} else {
+			asToken := tokenFromKeyword(AsKeyword)
+			delimiters := append(delimiters, tokenFromSymbol(CommaSymbol), asToken)
+			exp, newCursor, ok := p.parseExpression(tokens, cursor, delimiters, 0)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected expression")
+				return nil, initialCursor, false
+			}
+
+			cursor = newCursor
+			si.Exp = exp
+
+			_, cursor, ok = p.parseToken(tokens, cursor, asToken)
+			if ok {
+				id, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+				if !ok {
+					p.helpMessage(tokens, cursor, "Expected identifier after AS")
+					return nil, initialCursor, false
+				}
+
+				cursor = newCursor
+				si.As = id
+			}
+		}
+
+		s = append(s, &si)
+	}
+
+	return &s, cursor, true
+}
+
+// parseJoin looks for the keywords introducing a join, e.g. LEFT
+// OUTER JOIN
+func (p Parser) parseJoin(tokens []*Token, initialCursor uint) (JoinKind, uint, bool) {
+	cursor := initialCursor
+
+	_, cursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+	if ok {
+		return CrossJoinKind, cursor, true
+	}
+
+	kinds := []struct {
+		keyword Keyword
+		kind    JoinKind
+	}{
+		{InnerKeyword, InnerJoinKind},
+		{LeftKeyword, LeftJoinKind},
+		{RightKeyword, RightJoinKind},
+		{FullKeyword, FullJoinKind},
+		{CrossKeyword, CrossJoinKind},
+	}
+
+	kind := InnerJoinKind
+	for _, k := range kinds {
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(k.keyword))
+		if ok {
+			kind = k.kind
+			break
+		}
+	}
+
+	if kind == LeftJoinKind || kind == RightJoinKind || kind == FullJoinKind {
+		_, cursor, _ = p.parseToken(tokens, cursor, tokenFromKeyword(OuterKeyword))
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(JoinKeyword))
+	if !ok {
+		return 0, initialCursor, false
+	}
+
+	return kind, cursor, true
+}
+
+func (p Parser) parseFromItems(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*FromItem, uint, bool) {
+	cursor := initialCursor
+
+	onDelimiters := append([]Token{
+		tokenFromSymbol(CommaSymbol),
+		tokenFromKeyword(JoinKeyword),
+		tokenFromKeyword(InnerKeyword),
+		tokenFromKeyword(LeftKeyword),
+		tokenFromKeyword(RightKeyword),
+		tokenFromKeyword(FullKeyword),
+		tokenFromKeyword(CrossKeyword),
+	}, delimiters...)
+
+	var from []*FromItem
+	for {
+		var fi FromItem
+		if len(from) > 0 {
+			join, newCursor, ok := p.parseJoin(tokens, cursor)
+			if !ok {
+				break
+			}
+
+			cursor = newCursor
+			fi.Join = join
+		}
+
+		if call, newCursor, ok := p.parseCallExpression(tokens, cursor); ok {
+			cursor = newCursor
+			fi.Function = call.Call
+		} else {
+			table, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected FROM item")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+			fi.Table = *table
+		}
+
+		_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(AsKeyword))
+		cursor = newCursor
+		alias, newCursor, aliasOk := p.parseTokenKind(tokens, cursor, IdentifierKind)
+		if ok && !aliasOk {
+			p.helpMessage(tokens, cursor, "Expected alias after AS")
+			return nil, initialCursor, false
+		}
+		if aliasOk {
+			cursor = newCursor
+			fi.As = alias
+		}
+
+		if len(from) > 0 && fi.Join != CrossJoinKind {
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(OnKeyword))
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected ON")
+				return nil, initialCursor, false
+			}
+
+			on, newCursor, ok := p.parseExpression(tokens, cursor, onDelimiters, 0)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected join condition")
+				return nil, initialCursor, false
+			}
+
+			cursor = newCursor
+			fi.On = on
+		}
+
+		from = append(from, &fi)
+	}
+
+	return &from, cursor, true
+}
+
+func (p Parser) parseOrderByItems(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*OrderByItem, uint, bool) {
+	cursor := initialCursor
+
+	ascToken := tokenFromKeyword(AscKeyword)
+	descToken := tokenFromKeyword(DescKeyword)
+	nullsToken := tokenFromKeyword(NullsKeyword)
+	expDelimiters := append([]Token{tokenFromSymbol(CommaSymbol), ascToken, descToken, nullsToken}, delimiters...)
+
+	var items []*OrderByItem
+	for {
+		if len(items) > 0 {
+			var ok bool
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+			if !ok {
+				break
+			}
+		}
+
+		exp, newCursor, ok := p.parseExpression(tokens, cursor, expDelimiters, 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected ORDER BY expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		item := OrderByItem{Exp: exp}
+		_, cursor, ok = p.parseToken(tokens, cursor, descToken)
+		if ok {
+			item.Desc = true
+		} else {
+			_, cursor, _ = p.parseToken(tokens, cursor, ascToken)
+		}
+
+		_, cursor, ok = p.parseToken(tokens, cursor, nullsToken)
+		if ok {
+			if _, newCursor, ok := p.parseContextualKeyword(tokens, cursor, FirstKeyword); ok {
+				item.Nulls = NullsFirstOrder
+				cursor = newCursor
+			} else if _, newCursor, ok := p.parseContextualKeyword(tokens, cursor, LastKeyword); ok {
+				item.Nulls = NullsLastOrder
+				cursor = newCursor
+			} else {
+				p.helpMessage(tokens, cursor, "Expected FIRST or LAST after NULLS")
+				return nil, initialCursor, false
+			}
+		}
+
+		items = append(items, &item)
+	}
+
+	return &items, cursor, true
+}
+
+// parseSelectCore parses a single SELECT up to and including HAVING
+func (p Parser) parseSelectCore(tokens []*Token, initialCursor uint, delimiters []Token) (*SelectStatement, uint, bool) {
+	var ok bool
+	cursor := initialCursor
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(SelectKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	slct := SelectStatement{}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(DistinctKeyword))
+	if ok {
+		slct.Distinct = true
+
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(OnKeyword))
+		if ok {
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected left paren after DISTINCT ON")
+				return nil, initialCursor, false
+			}
+
+			rightParenToken := tokenFromSymbol(RightParenSymbol)
+			on, newCursor, ok := p.parseExpressions(tokens, cursor, []Token{rightParenToken})
+			if !ok || len(*on) == 0 {
+				p.helpMessage(tokens, cursor, "Expected DISTINCT ON expressions")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			_, cursor, ok = p.parseToken(tokens, cursor, rightParenToken)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected right paren")
+				return nil, initialCursor, false
+			}
+
+			slct.DistinctOn = on
+		}
+	}
+
+	fromToken := tokenFromKeyword(FromKeyword)
+	whereToken := tokenFromKeyword(WhereKeyword)
+	groupToken := tokenFromKeyword(GroupKeyword)
+	havingToken := tokenFromKeyword(HavingKeyword)
+
+	// Each clause ends where any of the clauses after it begins
+	clauses := append([]Token{fromToken, whereToken, groupToken, havingToken}, delimiters...)
+
+	item, newCursor, ok := p.parseSelectItem(tokens, cursor, clauses)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	slct.Item = item
+	cursor = newCursor
+
+	_, cursor, ok = p.parseToken(tokens, cursor, fromToken)
+	if ok {
+		from, newCursor, ok := p.parseFromItems(tokens, cursor, clauses[1:])
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		slct.From = from
+		cursor = newCursor
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, whereToken)
+	if ok {
+		where, newCursor, ok := p.parseExpression(tokens, cursor, clauses[2:], 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			return nil, initialCursor, false
+		}
+
+		slct.Where = where
+		cursor = newCursor
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, groupToken)
+	if ok {
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(ByKeyword))
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected BY after GROUP")
+			return nil, initialCursor, false
+		}
+
+		groupBy, newCursor, ok := p.parseExpressions(tokens, cursor, clauses[3:])
+		if !ok || len(*groupBy) == 0 {
+			p.helpMessage(tokens, cursor, "Expected GROUP BY expressions")
+			return nil, initialCursor, false
+		}
+
+		slct.GroupBy = groupBy
+		cursor = newCursor
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, havingToken)
+	if ok {
+		having, newCursor, ok := p.parseExpression(tokens, cursor, clauses[4:], 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected HAVING conditionals")
+			return nil, initialCursor, false
+		}
+
+		slct.Having = having
+		cursor = newCursor
+	}
+
+	return &slct, cursor, true
+}
+
+// parseSetOperator looks for UNION, INTERSECT or EXCEPT optionally
+// followed by ALL or DISTINCT
+func (p Parser) parseSetOperator(tokens []*Token, initialCursor uint) (SetOperator, bool, uint, bool) {
+	operators := []struct {
+		keyword Keyword
+		op      SetOperator
+	}{
+		{UnionKeyword, UnionOperator},
+		{IntersectKeyword, IntersectOperator},
+		{ExceptKeyword, ExceptOperator},
+	}
+
+	for _, o := range operators {
+		_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(o.keyword))
+		if !ok {
+			continue
+		}
+
+		_, cursor, all := p.parseToken(tokens, cursor, tokenFromKeyword(AllKeyword))
+		if !all {
+			_, cursor, _ = p.parseToken(tokens, cursor, tokenFromKeyword(DistinctKeyword))
+		}
+
+		return o.op, all, cursor, true
+	}
+
+	return 0, false, initialCursor, false
+}
+
+// parseCommonTableExpressions parses the comma separated list of
+// named SELECTs after WITH
+// parseColumnNames parses a parenthesized list of column names
+func (p Parser) parseColumnNames(tokens []*Token, initialCursor uint) (*[]*Token, uint, bool) {
+	_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	var columns []*Token
+	for {
+		column, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+		columns = append(columns, column)
+
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+		if !ok {
+			break
 		}
 	}
 
-	return nil, initialCursor, false
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
+	return &columns, cursor, true
 }
 
-func (p Parser) parseExpression(tokens []*Token, initialCursor uint, delimiters []Token, minBp uint) (*Expression, uint, bool) {
+func (p Parser) parseCommonTableExpressions(tokens []*Token, initialCursor uint) (*[]*CommonTableExpression, uint, bool) {
 	cursor := initialCursor
 
-	var exp *Expression
-	_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
-	if ok {
+	var ctes []*CommonTableExpression
+	for {
+		if len(ctes) > 0 {
+			var ok bool
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+			if !ok {
+				break
+			}
+		}
+
+		name, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected WITH query name")
+			return nil, initialCursor, false
+		}
 		cursor = newCursor
-		RightParenToken := tokenFromSymbol(RightParenSymbol)
 
-		exp, cursor, ok = p.parseExpression(tokens, cursor, append(delimiters, RightParenToken), minBp)
+		cte := CommonTableExpression{Name: *name}
+		if _, _, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)); ok {
+			cte.Columns, cursor, ok = p.parseColumnNames(tokens, cursor)
+			if !ok {
+				return nil, initialCursor, false
+			}
+		}
+
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(AsKeyword))
 		if !ok {
-			p.helpMessage(tokens, cursor, "Expected expression after opening paren")
+			p.helpMessage(tokens, cursor, "Expected AS")
 			return nil, initialCursor, false
 		}
 
-		_, cursor, ok = p.parseToken(tokens, cursor, RightParenToken)
+		slct, newCursor, ok := p.parseSubquery(tokens, cursor)
 		if !ok {
-			p.helpMessage(tokens, cursor, "Expected closing paren")
+			p.helpMessage(tokens, cursor, "Expected SELECT in parens")
 			return nil, initialCursor, false
 		}
-	} else {
-		exp, cursor, ok = p.parseLiteralExpression(tokens, cursor)
+		cursor = newCursor
+		cte.Select = slct
+
+		ctes = append(ctes, &cte)
+	}
+
+	return &ctes, cursor, true
+}
+
+func (p Parser) parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	var with *[]*CommonTableExpression
+	recursive := false
+	_, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(WithKeyword))
+	if ok {
+		cursor = newCursor
+		_, cursor, recursive = p.parseToken(tokens, cursor, tokenFromKeyword(RecursiveKeyword))
+
+		ctes, newCursor, ok := p.parseCommonTableExpressions(tokens, cursor)
 		if !ok {
 			return nil, initialCursor, false
 		}
+		cursor = newCursor
+		with = ctes
 	}
 
-	lastCursor := cursor
-outer:
-	for cursor < uint(len(tokens)) {
-		for _, d := range delimiters {
-			_, _, ok = p.parseToken(tokens, cursor, d)
-			if ok {
-				break outer
-			}
+	orderToken := tokenFromKeyword(OrderKeyword)
+	limitToken := tokenFromKeyword(LimitKeyword)
+	offsetToken := tokenFromKeyword(OffsetKeyword)
+
+	// Each clause ends where any of the clauses after it begins
+	clauses := []Token{orderToken, limitToken, offsetToken, delimiter}
+	coreDelimiters := append([]Token{
+		tokenFromKeyword(UnionKeyword),
+		tokenFromKeyword(IntersectKeyword),
+		tokenFromKeyword(ExceptKeyword),
+	}, clauses...)
+
+	first, newCursor, ok := p.parseSelectCore(tokens, cursor, coreDelimiters)
+	if !ok {
+		if with != nil {
+			p.helpMessage(tokens, cursor, "Expected SELECT after WITH")
 		}
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
 
-		binOps := []Token{
-			tokenFromKeyword(AndKeyword),
-			tokenFromKeyword(OrKeyword),
-			tokenFromSymbol(EqSymbol),
-			tokenFromSymbol(NeqSymbol),
-			tokenFromSymbol(LtSymbol),
-			tokenFromSymbol(LteSymbol),
-			tokenFromSymbol(GtSymbol),
-			tokenFromSymbol(GteSymbol),
-			tokenFromSymbol(ConcatSymbol),
-			tokenFromSymbol(PlusSymbol),
+	operands := []*SelectStatement{first}
+	operations := []SetOperation{}
+	for {
+		op, all, newCursor, ok := p.parseSetOperator(tokens, cursor)
+		if !ok {
+			break
 		}
+		cursor = newCursor
 
-		var op *Token
-		for _, bo := range binOps {
-			var t *Token
-			t, cursor, ok = p.parseToken(tokens, cursor, bo)
-			if ok {
-				op = t
-				break
+		next, newCursor, ok := p.parseSelectCore(tokens, cursor, coreDelimiters)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected SELECT after "+op.GenerateCode())
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		operands = append(operands, next)
+		operations = append(operations, SetOperation{Op: op, All: all})
+	}
+
+	// INTERSECT binds more tightly than UNION and EXCEPT, which are
+	// applied left to right
+	for _, tight := range []bool{true, false} {
+		for i := 0; i < len(operations); {
+			if tight != (operations[i].Op == IntersectOperator) {
+				i++
+				continue
 			}
+
+			so := operations[i]
+			so.Left = operands[i]
+			so.Right = operands[i+1]
+			operands[i] = &SelectStatement{SetOperation: &so}
+			operands = append(operands[:i+1], operands[i+2:]...)
+			operations = append(operations[:i], operations[i+1:]...)
 		}
+	}
 
-		if op == nil {
-			p.helpMessage(tokens, cursor, "Expected binary operator")
+	slct := operands[0]
+	slct.With = with
+	slct.Recursive = recursive
+	_, cursor, ok = p.parseToken(tokens, cursor, orderToken)
+	if ok {
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(ByKeyword))
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected BY after ORDER")
 			return nil, initialCursor, false
 		}
 
-		bp := op.bindingPower()
-		if bp < minBp {
-			cursor = lastCursor
-			break
+		orderBy, newCursor, ok := p.parseOrderByItems(tokens, cursor, clauses[1:])
+		if !ok {
+			return nil, initialCursor, false
 		}
 
-		b, newCursor, ok := p.parseExpression(tokens, cursor, delimiters, bp)
+		slct.OrderBy = orderBy
+		cursor = newCursor
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, limitToken)
+	if ok {
+		limit, newCursor, ok := p.parseExpression(tokens, cursor, clauses[2:], 0)
 		if !ok {
-			p.helpMessage(tokens, cursor, "Expected right operand")
+			p.helpMessage(tokens, cursor, "Expected LIMIT value")
 			return nil, initialCursor, false
 		}
-		exp = &Expression{
-			Binary: &BinaryExpression{
-				*exp,
-				*b,
-				*op,
-			},
-			Kind: BinaryKind,
+
+		slct.Limit = limit
+		cursor = newCursor
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, offsetToken)
+	if ok {
+		offset, newCursor, ok := p.parseExpression(tokens, cursor, clauses[3:], 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected OFFSET value")
+			return nil, initialCursor, false
 		}
+
+		slct.Offset = offset
 		cursor = newCursor
-		lastCursor = cursor
 	}
 
-	return exp, cursor, true
+	return slct, cursor, true
 }
 
-func (p Parser) parseSelectItem(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*SelectItem, uint, bool) {
+// parseExpressions parses a comma separated list of expressions up
+// to any of the delimiters
+func (p Parser) parseExpressions(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*Expression, uint, bool) {
 	cursor := initialCursor
 
-	var s []*SelectItem
+	var exps []*Expression
 outer:
 	for {
 		if cursor >= uint(len(tokens)) {
@@ -196,8 +1786,8 @@ outer:
 			}
 		}
 
-		var ok bool
-		if len(s) > 0 {
+		if len(exps) > 0 {
+			var ok bool
 			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
 			if !ok {
 				p.helpMessage(tokens, cursor, "Expected comma")
@@ -205,212 +1795,454 @@ outer:
 			}
 		}
 
-		var si SelectItem
-		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(AsteriskSymbol))
-		if ok {
-			si = SelectItem{Asterisk: true}
-		} else {
-			asToken := tokenFromKeyword(AsKeyword)
-			delimiters := append(delimiters, tokenFromSymbol(CommaSymbol), asToken)
-			exp, newCursor, ok := p.parseExpression(tokens, cursor, delimiters, 0)
-			if !ok {
-				p.helpMessage(tokens, cursor, "Expected expression")
-				return nil, initialCursor, false
-			}
+		exp, newCursor, ok := p.parseExpression(tokens, cursor, append([]Token{tokenFromSymbol(CommaSymbol)}, delimiters...), 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
 
-			cursor = newCursor
-			si.Exp = exp
+		exps = append(exps, exp)
+	}
 
-			_, cursor, ok = p.parseToken(tokens, cursor, asToken)
-			if ok {
-				id, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
-				if !ok {
-					p.helpMessage(tokens, cursor, "Expected identifier after AS")
-					return nil, initialCursor, false
-				}
+	return &exps, cursor, true
+}
+
+func (p Parser) parseInsertStatement(tokens []*Token, initialCursor uint, _ Token) (*InsertStatement, uint, bool) {
+	cursor := initialCursor
+	ok := false
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(InsertKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(IntoKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected into")
+		return nil, initialCursor, false
+	}
+
+	table, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	var columns *[]*Token
+	if _, _, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)); ok {
+		columns, cursor, ok = p.parseColumnNames(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(ValuesKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected VALUES")
+		return nil, initialCursor, false
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected left paren")
+		return nil, initialCursor, false
+	}
+
+	values, newCursor, ok := p.parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected expressions")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected right paren")
+		return nil, initialCursor, false
+	}
+
+	return &InsertStatement{
+		Table:   *table,
+		Columns: columns,
+		Values:  values,
+	}, cursor, true
+}
+
+func (p Parser) parseSetItems(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*SetItem, uint, bool) {
+	cursor := initialCursor
+
+	var set []*SetItem
+outer:
+	for {
+		if cursor >= uint(len(tokens)) {
+			return nil, initialCursor, false
+		}
+
+		current := tokens[cursor]
+		for _, delimiter := range delimiters {
+			if delimiter.equals(current) {
+				break outer
+			}
+		}
 
-				cursor = newCursor
-				si.As = id
+		var ok bool
+		if len(set) > 0 {
+			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected comma")
+				return nil, initialCursor, false
 			}
 		}
 
-		s = append(s, &si)
+		column, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(EqSymbol))
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected =")
+			return nil, initialCursor, false
+		}
+
+		exp, newCursor, ok := p.parseExpression(tokens, cursor, append(delimiters, tokenFromSymbol(CommaSymbol)), 0)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		set = append(set, &SetItem{
+			Column: *column,
+			Exp:    *exp,
+		})
 	}
 
-	return &s, cursor, true
+	return &set, cursor, true
 }
 
-func (p Parser) parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token) (*SelectStatement, uint, bool) {
-	var ok bool
+func (p Parser) parseUpdateStatement(tokens []*Token, initialCursor uint, delimiter Token) (*UpdateStatement, uint, bool) {
 	cursor := initialCursor
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(SelectKeyword))
+	ok := false
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(UpdateKeyword))
 	if !ok {
 		return nil, initialCursor, false
 	}
 
-	slct := SelectStatement{}
+	table, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
 
-	fromToken := tokenFromKeyword(FromKeyword)
-	item, newCursor, ok := p.parseSelectItem(tokens, cursor, []Token{fromToken, delimiter})
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(SetKeyword))
 	if !ok {
+		p.helpMessage(tokens, cursor, "Expected SET")
 		return nil, initialCursor, false
 	}
 
-	slct.Item = item
+	whereToken := tokenFromKeyword(WhereKeyword)
+	set, newCursor, ok := p.parseSetItems(tokens, cursor, []Token{whereToken, delimiter})
+	if !ok || len(*set) == 0 {
+		p.helpMessage(tokens, cursor, "Expected SET items")
+		return nil, initialCursor, false
+	}
 	cursor = newCursor
 
-	whereToken := tokenFromKeyword(WhereKeyword)
+	upd := UpdateStatement{
+		Table: *table,
+		Set:   set,
+	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, fromToken)
+	_, cursor, ok = p.parseToken(tokens, cursor, whereToken)
 	if ok {
-		from, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+		where, newCursor, ok := p.parseExpression(tokens, cursor, []Token{delimiter}, 0)
 		if !ok {
-			p.helpMessage(tokens, cursor, "Expected FROM item")
+			p.helpMessage(tokens, cursor, "Expected WHERE conditionals")
 			return nil, initialCursor, false
 		}
 
-		slct.From = from
+		upd.Where = where
 		cursor = newCursor
 	}
 
-	limitToken := tokenFromKeyword(LimitKeyword)
-	offsetToken := tokenFromKeyword(OffsetKeyword)
+	return &upd, cursor, true
+}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, whereToken)
-	if ok {
-		where, newCursor, ok := p.parseExpression(tokens, cursor, []Token{limitToken, offsetToken, delimiter}, 0)
-		if !ok {
-			p.helpMessage(tokens, cursor, "Expected WHERE conditionals")
-			return nil, initialCursor, false
-		}
+func (p Parser) parseDeleteStatement(tokens []*Token, initialCursor uint, delimiter Token) (*DeleteStatement, uint, bool) {
+	cursor := initialCursor
+	ok := false
 
-		slct.Where = where
-		cursor = newCursor
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(DeleteKeyword))
+	if !ok {
+		return nil, initialCursor, false
 	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, limitToken)
-	if ok {
-		limit, newCursor, ok := p.parseExpression(tokens, cursor, []Token{offsetToken, delimiter}, 0)
-		if !ok {
-			p.helpMessage(tokens, cursor, "Expected LIMIT value")
-			return nil, initialCursor, false
-		}
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(FromKeyword))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected FROM")
+		return nil, initialCursor, false
+	}
 
-		slct.Limit = limit
-		cursor = newCursor
+	table, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	del := DeleteStatement{
+		Table: *table,
 	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, offsetToken)
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(WhereKeyword))
 	if ok {
-		offset, newCursor, ok := p.parseExpression(tokens, cursor, []Token{delimiter}, 0)
+		where, newCursor, ok := p.parseExpression(tokens, cursor, []Token{delimiter}, 0)
 		if !ok {
-			p.helpMessage(tokens, cursor, "Expected OFFSET value")
+			p.helpMessage(tokens, cursor, "Expected WHERE conditionals")
 			return nil, initialCursor, false
 		}
 
-		slct.Offset = offset
+		del.Where = where
 		cursor = newCursor
 	}
 
-	return &slct, cursor, true
+	return &del, cursor, true
 }
 
-func (p Parser) parseExpressions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*Expression, uint, bool) {
-	cursor := initialCursor
+// parseCheckConstraint parses CHECK followed by a parenthesized
+// condition
+func (p Parser) parseCheckConstraint(tokens []*Token, initialCursor uint) (*Expression, uint, bool) {
+	_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(CheckKeyword))
+	if !ok {
+		return nil, initialCursor, false
+	}
 
-	var exps []*Expression
-	for {
-		if cursor >= uint(len(tokens)) {
-			return nil, initialCursor, false
-		}
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected left parenthesis")
+		return nil, initialCursor, false
+	}
 
-		current := tokens[cursor]
-		if delimiter.equals(current) {
-			break
-		}
+	check, cursor, ok := p.parseExpression(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)}, 0)
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected CHECK condition")
+		return nil, initialCursor, false
+	}
 
-		if len(exps) > 0 {
-			var ok bool
-			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
-			if !ok {
-				p.helpMessage(tokens, cursor, "Expected comma")
-				return nil, initialCursor, false
-			}
-		}
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected right parenthesis")
+		return nil, initialCursor, false
+	}
 
-		exp, newCursor, ok := p.parseExpression(tokens, cursor, []Token{tokenFromSymbol(CommaSymbol), tokenFromSymbol(RightParenSymbol)}, 0)
-		if !ok {
-			p.helpMessage(tokens, cursor, "Expected expression")
-			return nil, initialCursor, false
-		}
-		cursor = newCursor
+	return check, cursor, true
+}
 
-		exps = append(exps, exp)
+// parseReferentialAction parses the action following ON DELETE or ON
+// UPDATE
+func (p Parser) parseReferentialAction(tokens []*Token, initialCursor uint) (ReferentialAction, uint, bool) {
+	if _, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(CascadeKeyword)); ok {
+		return CascadeReferentialAction, cursor, true
 	}
 
-	return &exps, cursor, true
-}
+	if _, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(RestrictKeyword)); ok {
+		return RestrictReferentialAction, cursor, true
+	}
 
-func (p Parser) parseInsertStatement(tokens []*Token, initialCursor uint, _ Token) (*InsertStatement, uint, bool) {
-	cursor := initialCursor
-	ok := false
+	if _, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(NoactionKeyword)); ok {
+		return NoActionReferentialAction, cursor, true
+	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(InsertKeyword))
-	if !ok {
-		return nil, initialCursor, false
+	if _, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(SetKeyword)); ok {
+		if _, cursor, ok = p.parseToken(tokens, cursor, Token{Kind: NullKind, Value: string(NullKeyword)}); ok {
+			return SetNullReferentialAction, cursor, true
+		}
 	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(IntoKeyword))
+	p.helpMessage(tokens, initialCursor, "Expected CASCADE, SET NULL, RESTRICT or NO ACTION")
+	return 0, initialCursor, false
+}
+
+// parseReferences parses REFERENCES followed by the referenced table
+// and columns and the ON DELETE and ON UPDATE actions into fk
+func (p Parser) parseReferences(tokens []*Token, initialCursor uint, fk *ForeignKeyDefinition) (uint, bool) {
+	_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(ReferencesKeyword))
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected into")
-		return nil, initialCursor, false
+		return initialCursor, false
 	}
 
 	table, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected table name")
-		return nil, initialCursor, false
+		p.helpMessage(tokens, cursor, "Expected referenced table")
+		return initialCursor, false
 	}
 	cursor = newCursor
+	fk.Table = *table
 
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(ValuesKeyword))
-	if !ok {
-		p.helpMessage(tokens, cursor, "Expected VALUES")
-		return nil, initialCursor, false
+	if _, _, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol)); ok {
+		fk.RefColumns, cursor, ok = p.parseColumnNames(tokens, cursor)
+		if !ok {
+			return initialCursor, false
+		}
 	}
 
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
+	for {
+		_, onCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(OnKeyword))
+		if !ok {
+			return cursor, true
+		}
+
+		var action *ReferentialAction
+		if _, newCursor, ok = p.parseToken(tokens, onCursor, tokenFromKeyword(DeleteKeyword)); ok {
+			action = &fk.OnDelete
+		} else if _, newCursor, ok = p.parseToken(tokens, onCursor, tokenFromKeyword(UpdateKeyword)); ok {
+			action = &fk.OnUpdate
+		} else {
+			p.helpMessage(tokens, onCursor, "Expected DELETE or UPDATE")
+			return initialCursor, false
+		}
+
+		*action, cursor, ok = p.parseReferentialAction(tokens, newCursor)
+		if !ok {
+			return initialCursor, false
+		}
+	}
+}
+
+// parseTableConstraint parses a constraint in the list of columns
+// of a table
+func (p Parser) parseTableConstraint(tokens []*Token, initialCursor uint) (*TableConstraint, uint, bool) {
+	if check, cursor, ok := p.parseCheckConstraint(tokens, initialCursor); ok {
+		return &TableConstraint{Check: check}, cursor, true
+	}
+
+	for _, keyword := range []Keyword{PrimarykeyKeyword, UniqueKeyword} {
+		_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(keyword))
+		if !ok {
+			continue
+		}
+
+		columns, cursor, ok := p.parseColumnNames(tokens, cursor)
+		if !ok {
+			p.helpMessage(tokens, cursor, "Expected key columns")
+			return nil, initialCursor, false
+		}
+
+		if keyword == PrimarykeyKeyword {
+			return &TableConstraint{PrimaryKey: columns}, cursor, true
+		}
+
+		return &TableConstraint{Unique: columns}, cursor, true
+	}
+
+	_, cursor, ok := p.parseToken(tokens, initialCursor, tokenFromKeyword(ForeignkeyKeyword))
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected left paren")
 		return nil, initialCursor, false
 	}
 
-	values, newCursor, ok := p.parseExpressions(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	var fk ForeignKeyDefinition
+	fk.Columns, cursor, ok = p.parseColumnNames(tokens, cursor)
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected expressions")
+		p.helpMessage(tokens, cursor, "Expected foreign key columns")
 		return nil, initialCursor, false
 	}
-	cursor = newCursor
 
-	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	cursor, ok = p.parseReferences(tokens, cursor, &fk)
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected right paren")
+		p.helpMessage(tokens, cursor, "Expected REFERENCES")
 		return nil, initialCursor, false
 	}
 
-	return &InsertStatement{
-		Table:  *table,
-		Values: values,
-	}, cursor, true
+	return &TableConstraint{ForeignKey: &fk}, cursor, true
+}
+
+// parseColumnConstraints parses the constraints following the type
+// of a column into cd
+func (p Parser) parseColumnConstraints(tokens []*Token, initialCursor uint, delimiter Token, cd *ColumnDefinition) (uint, bool) {
+	cursor := initialCursor
+
+	defaultDelimiters := []Token{
+		tokenFromSymbol(CommaSymbol),
+		delimiter,
+		tokenFromKeyword(PrimarykeyKeyword),
+		tokenFromKeyword(UniqueKeyword),
+		tokenFromKeyword(NotKeyword),
+		tokenFromKeyword(DefaultKeyword),
+		tokenFromKeyword(CheckKeyword),
+		tokenFromKeyword(ReferencesKeyword),
+	}
+
+	for {
+		if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(PrimarykeyKeyword)); ok {
+			cursor = newCursor
+			cd.PrimaryKey = true
+			continue
+		}
+
+		if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(UniqueKeyword)); ok {
+			cursor = newCursor
+			cd.Unique = true
+			continue
+		}
+
+		if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(NotKeyword)); ok {
+			_, newCursor, ok = p.parseToken(tokens, newCursor, Token{Kind: NullKind, Value: string(NullKeyword)})
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected NULL after NOT")
+				return initialCursor, false
+			}
+
+			cursor = newCursor
+			cd.NotNull = true
+			continue
+		}
+
+		if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(DefaultKeyword)); ok {
+			exp, newCursor, ok := p.parseExpression(tokens, newCursor, defaultDelimiters, 0)
+			if !ok {
+				p.helpMessage(tokens, cursor, "Expected DEFAULT expression")
+				return initialCursor, false
+			}
+
+			cursor = newCursor
+			cd.Default = exp
+			continue
+		}
+
+		if check, newCursor, ok := p.parseCheckConstraint(tokens, cursor); ok {
+			cursor = newCursor
+			cd.Check = check
+			continue
+		}
+
+		var fk ForeignKeyDefinition
+		if newCursor, ok := p.parseReferences(tokens, cursor, &fk); ok {
+			cursor = newCursor
+			cd.References = &fk
+			continue
+		}
+
+		return cursor, true
+	}
 }
 
-func (p Parser) parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*ColumnDefinition, uint, bool) {
+// parseColumnDefinitions parses the columns and table constraints
+// of a table
+func (p Parser) parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*ColumnDefinition, *[]*TableConstraint, uint, bool) {
 	cursor := initialCursor
 
 	var cds []*ColumnDefinition
+	var constraints *[]*TableConstraint
 	for {
 		if cursor >= uint(len(tokens)) {
-			return nil, initialCursor, false
+			return nil, nil, initialCursor, false
 		}
 
 		current := tokens[cursor]
@@ -418,43 +2250,53 @@ func (p Parser) parseColumnDefinitions(tokens []*Token, initialCursor uint, deli
 			break
 		}
 
-		if len(cds) > 0 {
+		if len(cds) > 0 || constraints != nil {
 			var ok bool
 			_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(CommaSymbol))
 			if !ok {
 				p.helpMessage(tokens, cursor, "Expected comma")
-				return nil, initialCursor, false
+				return nil, nil, initialCursor, false
+			}
+		}
+
+		if constraint, newCursor, ok := p.parseTableConstraint(tokens, cursor); ok {
+			cursor = newCursor
+			if constraints == nil {
+				constraints = &[]*TableConstraint{}
 			}
+			*constraints = append(*constraints, constraint)
+			continue
 		}
 
 		id, newCursor, ok := p.parseTokenKind(tokens, cursor, IdentifierKind)
 		if !ok {
 			p.helpMessage(tokens, cursor, "Expected column name")
-			return nil, initialCursor, false
+			return nil, nil, initialCursor, false
 		}
 		cursor = newCursor
 
-		ty, newCursor, ok := p.parseTokenKind(tokens, cursor, KeywordKind)
+		ty, modifiers, array, newCursor, ok := p.parseDatatype(tokens, cursor)
 		if !ok {
 			p.helpMessage(tokens, cursor, "Expected column type")
-			return nil, initialCursor, false
+			return nil, nil, initialCursor, false
 		}
 		cursor = newCursor
 
-		primaryKey := false
-		_, cursor, ok = p.parseToken(tokens, cursor, tokenFromKeyword(PrimarykeyKeyword))
-		if ok {
-			primaryKey = true
+		cd := ColumnDefinition{
+			Name:      *id,
+			Datatype:  *ty,
+			Modifiers: modifiers,
+			Array:     array,
+		}
+		cursor, ok = p.parseColumnConstraints(tokens, cursor, delimiter, &cd)
+		if !ok {
+			return nil, nil, initialCursor, false
 		}
 
-		cds = append(cds, &ColumnDefinition{
-			Name:       *id,
-			Datatype:   *ty,
-			PrimaryKey: primaryKey,
-		})
+		cds = append(cds, &cd)
 	}
 
-	return &cds, cursor, true
+	return &cds, constraints, cursor, true
 }
 
 func (p Parser) parseCreateTableStatement(tokens []*Token, initialCursor uint, _ Token) (*CreateTableStatement, uint, bool) {
@@ -484,7 +2326,7 @@ func (p Parser) parseCreateTableStatement(tokens []*Token, initialCursor uint, _
 		return nil, initialCursor, false
 	}
 
-	cols, newCursor, ok := p.parseColumnDefinitions(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	cols, constraints, newCursor, ok := p.parseColumnDefinitions(tokens, cursor, tokenFromSymbol(RightParenSymbol))
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -497,8 +2339,9 @@ func (p Parser) parseCreateTableStatement(tokens []*Token, initialCursor uint, _
 	}
 
 	return &CreateTableStatement{
-		Name: *name,
-		Cols: cols,
+		Name:        *name,
+		Cols:        cols,
+		Constraints: constraints,
 	}, cursor, true
 }
 
@@ -548,6 +2391,22 @@ func (p Parser) parseStatement(tokens []*Token, initialCursor uint, _ Token) (*S
 		}, newCursor, true
 	}
 
+	upd, newCursor, ok := p.parseUpdateStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:            UpdateKind,
+			UpdateStatement: upd,
+		}, newCursor, true
+	}
+
+	del, newCursor, ok := p.parseDeleteStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:            DeleteKind,
+			DeleteStatement: del,
+		}, newCursor, true
+	}
+
 	crtTbl, newCursor, ok := p.parseCreateTableStatement(tokens, cursor, semicolonToken)
 	if ok {
 		return &Statement{
@@ -615,18 +2474,40 @@ func (p Parser) parseCreateIndexStatement(tokens []*Token, initialCursor uint, d
 	}
 	cursor = newCursor
 
-	e, newCursor, ok := p.parseExpression(tokens, cursor, []Token{delimiter}, 0)
+	var method *Token
+	if _, newCursor, ok := p.parseToken(tokens, cursor, tokenFromKeyword(UsingKeyword)); ok {
+		method, cursor, ok = p.parseTokenKind(tokens, newCursor, IdentifierKind)
+		if !ok {
+			p.helpMessage(tokens, newCursor, "Expected index method")
+			return nil, initialCursor, false
+		}
+	}
+
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(LeftParenSymbol))
 	if !ok {
-		p.helpMessage(tokens, cursor, "Expected table name")
+		p.helpMessage(tokens, cursor, "Expected opening paren")
+		return nil, initialCursor, false
+	}
+
+	exps, newCursor, ok := p.parseExpressions(tokens, cursor, []Token{tokenFromSymbol(RightParenSymbol)})
+	if !ok || len(*exps) == 0 {
+		p.helpMessage(tokens, cursor, "Expected index expressions")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
+	_, cursor, ok = p.parseToken(tokens, cursor, tokenFromSymbol(RightParenSymbol))
+	if !ok {
+		p.helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
 	return &CreateIndexStatement{
 		Name:   *name,
 		Unique: unique,
 		Table:  *table,
-		Exp:    *e,
+		Method: method,
+		Exps:   exps,
 	}, cursor, true
 }
 
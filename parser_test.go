@@ -165,6 +165,66 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "UPDATE users SET age = 2 WHERE id = 1",
+			ast: &Ast{
+				Statements: []*Statement{
+					{
+						Kind: UpdateKind,
+						UpdateStatement: &UpdateStatement{
+							Table: Token{
+								Loc:   Location{Col: 7, Line: 0},
+								Kind:  IdentifierKind,
+								Value: "users",
+							},
+							Set: &[]*SetItem{
+								{
+									Column: Token{
+										Loc:   Location{Col: 17, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "age",
+									},
+									Exp: Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 23, Line: 0},
+											Kind:  NumericKind,
+											Value: "2",
+										},
+									},
+								},
+							},
+							Where: &Expression{
+								Kind: BinaryKind,
+								Binary: &BinaryExpression{
+									A: Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 32, Line: 0},
+											Kind:  IdentifierKind,
+											Value: "id",
+										},
+									},
+									B: Expression{
+										Kind: LiteralKind,
+										Literal: &Token{
+											Loc:   Location{Col: 37, Line: 0},
+											Kind:  NumericKind,
+											Value: "1",
+										},
+									},
+									Op: Token{
+										Loc:   Location{Col: 35, Line: 0},
+										Kind:  SymbolKind,
+										Value: string(EqSymbol),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			source: "SELECT *, exclusive",
 			ast: &Ast{
@@ -226,10 +286,14 @@ func TestParse(t *testing.T) {
 									},
 								},
 							},
-							From: &Token{
-								Loc:   Location{Col: 33, Line: 0},
-								Kind:  IdentifierKind,
-								Value: "sketchy name",
+							From: &[]*FromItem{
+								{
+									Table: Token{
+										Loc:   Location{Col: 33, Line: 0},
+										Kind:  IdentifierKind,
+										Value: "sketchy name",
+									},
+								},
 							},
 							Limit: &Expression{
 								Kind: LiteralKind,
@@ -262,3 +326,418 @@ func TestParse(t *testing.T) {
 		assert.Equal(t, test.ast, ast, test.source)
 	}
 }
+
+func TestParse_GenerateCode(t *testing.T) {
+	tests := []struct {
+		source string
+		code   string
+	}{
+		{
+			source: "SELECT * FROM a LEFT OUTER JOIN b ON x = y, c RIGHT JOIN d ON z = 1 WHERE x = 2",
+			code: `SELECT
+	*
+FROM
+	"a"
+	LEFT JOIN "b" ON ("x" = "y")
+	CROSS JOIN "c"
+	RIGHT JOIN "d" ON ("z" = 1)
+WHERE
+	("x" = 2);`,
+		},
+		{
+			source: "SELECT * FROM a INNER JOIN b ON x = y JOIN c ON x = z FULL OUTER JOIN d ON true LIMIT 1",
+			code: `SELECT
+	*
+FROM
+	"a"
+	INNER JOIN "b" ON ("x" = "y")
+	INNER JOIN "c" ON ("x" = "z")
+	FULL JOIN "d" ON true
+LIMIT
+	1;`,
+		},
+		{
+			source: "SELECT u.id, name FROM users u JOIN orders AS o ON u.id = o.user_id WHERE o.total > .5",
+			code: `SELECT
+	"u"."id",
+	"name"
+FROM
+	"users" AS "u"
+	INNER JOIN "orders" AS "o" ON ("u"."id" = "o"."user_id")
+WHERE
+	("o"."total" > .5);`,
+		},
+		{
+			source: "SELECT count(DISTINCT a), sum(DISTINCT a + 1) FROM t GROUP BY 1",
+			code: `SELECT
+	count(DISTINCT "a"),
+	sum(DISTINCT ("a" + 1))
+FROM
+	"t"
+GROUP BY
+	1;`,
+		},
+		{
+			source: "SELECT region, count(*), sum(amount + 1) FROM sales GROUP BY region, id HAVING count(*) > 1",
+			code: `SELECT
+	"region",
+	count(*),
+	sum(("amount" + 1))
+FROM
+	"sales"
+GROUP BY
+	"region",
+	"id"
+HAVING
+	(count(*) > 1);`,
+		},
+		{
+			source: "SELECT a FROM x UNION ALL SELECT b FROM y WHERE b > 1 EXCEPT SELECT c FROM z ORDER BY 1 LIMIT 2",
+			code: `SELECT
+	"a"
+FROM
+	"x"
+UNION ALL
+SELECT
+	"b"
+FROM
+	"y"
+WHERE
+	("b" > 1)
+EXCEPT
+SELECT
+	"c"
+FROM
+	"z"
+ORDER BY
+	1
+LIMIT
+	2;`,
+		},
+		{
+			source: "SELECT (SELECT max(x) FROM t) FROM u WHERE id NOT IN (SELECT id FROM v) AND a + 1 IN (SELECT b FROM x)",
+			code: `SELECT
+	(SELECT
+	max("x")
+FROM
+	"t")
+FROM
+	"u"
+WHERE
+	(("id" NOT IN (SELECT
+	"id"
+FROM
+	"v")) and (("a" + 1) IN (SELECT
+	"b"
+FROM
+	"x")));`,
+		},
+		{
+			source: "SELECT 1 WHERE EXISTS (SELECT 1 FROM w WHERE w.id = u.id)",
+			code: `SELECT
+	1
+WHERE
+	EXISTS (SELECT
+	1
+FROM
+	"w"
+WHERE
+	("w"."id" = "u"."id"));`,
+		},
+		{
+			source: "WITH RECURSIVE t (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t), u AS (SELECT n FROM t) SELECT n FROM u LIMIT 3",
+			code: `WITH RECURSIVE "t" ("n") AS (SELECT
+	1
+UNION ALL
+SELECT
+	("n" + 1)
+FROM
+	"t"),
+"u" AS (SELECT
+	"n"
+FROM
+	"t")
+SELECT
+	"n"
+FROM
+	"u"
+LIMIT
+	3;`,
+		},
+		{
+			source: "SELECT DISTINCT name FROM users",
+			code: `SELECT DISTINCT
+	"name"
+FROM
+	"users";`,
+		},
+		{
+			source: "SELECT DISTINCT ON (a, b + 1) a, c FROM t ORDER BY a",
+			code: `SELECT DISTINCT ON ("a", ("b" + 1))
+	"a",
+	"c"
+FROM
+	"t"
+ORDER BY
+	"a";`,
+		},
+		{
+			source: "SELECT id FROM users ORDER BY age DESC NULLS LAST, name ASC, id NULLS FIRST LIMIT 3",
+			code: `SELECT
+	"id"
+FROM
+	"users"
+ORDER BY
+	"age" DESC NULLS LAST,
+	"name",
+	"id" NULLS FIRST
+LIMIT
+	3;`,
+		},
+		{
+			source: "SELECT 1 - 2 - 3, 1 + 2 * 3 % 4, -x * 2, -5, NOT a = 1 OR b AND NOT c",
+			code: `SELECT
+	((1 - 2) - 3),
+	(1 + ((2 * 3) % 4)),
+	((-"x") * 2),
+	-5,
+	((not ("a" = 1)) or ("b" and (not "c")));`,
+		},
+		{
+			source: "SELECT (a - b) / c FROM t WHERE a = 1 AND b = 2 OR c = 3",
+			code: `SELECT
+	(("a" - "b") / "c")
+FROM
+	"t"
+WHERE
+	((("a" = 1) and ("b" = 2)) or ("c" = 3));`,
+		},
+		{
+			source: "SELECT a IS NULL, a + 1 IS NOT DISTINCT FROM b, NOT a = 1 IS NOT NULL FROM t WHERE a IS DISTINCT FROM b OR b IS NOT NULL",
+			code: `SELECT
+	("a" IS NULL),
+	(("a" + 1) IS NOT DISTINCT FROM "b"),
+	(not (("a" = 1) IS NOT NULL))
+FROM
+	"t"
+WHERE
+	(("a" IS DISTINCT FROM "b") or ("b" IS NOT NULL));`,
+		},
+		{
+			source: "SELECT a LIKE 'x%' || b ESCAPE '!', a NOT ILIKE '_y', a ~ '^z' = b ~* 'w', a !~ 'v' OR b !~* 'u' FROM t WHERE a LIKE 'p%' AND b LIKE c",
+			code: `SELECT
+	("a" like ('x%' || "b") ESCAPE '!'),
+	(not ("a" ilike '_y')),
+	(("a" ~ '^z') = ("b" ~* 'w')),
+	(("a" !~ 'v') or ("b" !~* 'u'))
+FROM
+	"t"
+WHERE
+	(("a" like 'p%') and ("b" like "c"));`,
+		},
+		{
+			source: "SELECT -(-5), - -5, -(-(-5)), - -a",
+			code: `SELECT
+	5,
+	5,
+	-5,
+	(-(-"a"));`,
+		},
+		{
+			source: "SELECT a NOT IN (1, -2, b + 1), a BETWEEN 1 AND b + 2 AND c NOT BETWEEN 'x' AND 'y' FROM t WHERE a IN (SELECT a FROM u) OR a IN (1)",
+			code: `SELECT
+	("a" NOT IN (1, -2, ("b" + 1))),
+	(("a" BETWEEN 1 AND ("b" + 2)) and ("c" NOT BETWEEN 'x' AND 'y'))
+FROM
+	"t"
+WHERE
+	(("a" IN (SELECT
+	"a"
+FROM
+	"u")) or ("a" IN (1)));`,
+		},
+		{
+			source: "SELECT CASE WHEN a > 1 THEN 'x' WHEN a IS NULL THEN 'y' ELSE b || 'z' END, CASE a + 1 WHEN 2 THEN true END = b, COALESCE(a, NULLIF(b, 0), GREATEST(c, 1), LEAST(d)) FROM t",
+			code: `SELECT
+	CASE WHEN ("a" > 1) THEN 'x' WHEN ("a" IS NULL) THEN 'y' ELSE ("b" || 'z') END,
+	(CASE ("a" + 1) WHEN 2 THEN true END = "b"),
+	COALESCE("a", NULLIF("b", 0), GREATEST("c", 1), LEAST("d"))
+FROM
+	"t";`,
+		},
+		{
+			source: "SELECT upper(trim(a)), position('x' IN a || b), position(a, 'y'), substr(a, 1, 2) FROM t",
+			code: `SELECT
+	upper(trim("a")),
+	position('x', ("a" || "b")),
+	position("a", 'y'),
+	substr("a", 1, 2)
+FROM
+	"t";`,
+		},
+		{
+			source: "SELECT CAST(a + 1 AS text), a::int * 2, -b::int, (a || b)::boolean FROM t",
+			code: `SELECT
+	CAST(("a" + 1) AS TEXT),
+	(CAST("a" AS INT) * 2),
+	(-CAST("b" AS INT)),
+	CAST(("a" || "b") AS BOOLEAN)
+FROM
+	"t";`,
+		},
+		{
+			source: "SELECT CAST(a AS numeric(10,2)), b::double precision, 1.5e3 FROM t",
+			code: `SELECT
+	CAST("a" AS NUMERIC(10, 2)),
+	CAST("b" AS DOUBLE PRECISION),
+	1.5e3
+FROM
+	"t";`,
+		},
+		{
+			source: "CREATE TABLE t (a SMALLINT PRIMARY KEY, b BIGINT, c REAL, d double precision, e NUMERIC(5), f DECIMAL)",
+			code: `CREATE TABLE "t" (
+	"a" SMALLINT PRIMARY KEY,
+	"b" BIGINT,
+	"c" REAL,
+	"d" DOUBLE PRECISION,
+	"e" NUMERIC(5),
+	"f" DECIMAL
+);`,
+		},
+		{
+			source: "CREATE TABLE t (first TEXT, last TEXT, date DATE, time TIME[])",
+			code: `CREATE TABLE "t" (
+	"first" TEXT,
+	"last" TEXT,
+	"date" DATE,
+	"time" TIME[]
+);`,
+		},
+		{
+			source: "SELECT date, time::time FROM t WHERE date < DATE '2024-01-01' ORDER BY first NULLS FIRST, last NULLS LAST",
+			code: `SELECT
+	"date",
+	CAST("time" AS TIME)
+FROM
+	"t"
+WHERE
+	("date" < CAST('2024-01-01' AS DATE))
+ORDER BY
+	"first" NULLS FIRST,
+	"last" NULLS LAST;`,
+		},
+		{
+			source: "SELECT DATE '2024-01-01', extract(year FROM at), current_timestamp - interval '1 day' FROM t",
+			code: `SELECT
+	CAST('2024-01-01' AS DATE),
+	extract('year', "at"),
+	(CURRENT_TIMESTAMP - CAST('1 day' AS INTERVAL))
+FROM
+	"t";`,
+		},
+		{
+			source: `SELECT a->'b'->>0 = 'x', a#>>'{b,c}', a @> JSONB '{"b": 1}' FROM t WHERE u = '00000000-0000-0000-0000-000000000001'::uuid`,
+			code: `SELECT
+	((("a" -> 'b') ->> 0) = 'x'),
+	("a" #>> '{b,c}'),
+	("a" @> CAST('{"b": 1}' AS JSONB))
+FROM
+	"t"
+WHERE
+	("u" = CAST('00000000-0000-0000-0000-000000000001' AS UUID));`,
+		},
+		{
+			source: "CREATE INDEX payload_idx ON docs USING gin (payload)",
+			code:   `CREATE INDEX "payload_idx" ON "docs" USING gin ("payload");`,
+		},
+		{
+			source: "CREATE TABLE t (a INT PRIMARY KEY, b TEXT NOT NULL DEFAULT 'x' || 'y', c INT DEFAULT -1 NOT NULL CHECK (c <> 0), CHECK (a < c))",
+			code: `CREATE TABLE "t" (
+	"a" INT PRIMARY KEY,
+	"b" TEXT NOT NULL DEFAULT ('x' || 'y'),
+	"c" INT NOT NULL DEFAULT -1 CHECK (("c" <> 0)),
+	CHECK (("a" < "c"))
+);`,
+		},
+		{
+			source: "CREATE TABLE t (a INT REFERENCES u, b INT REFERENCES u (id) ON UPDATE NO ACTION ON DELETE SET NULL, c INT, FOREIGN KEY (b, c) REFERENCES v (x, y) ON UPDATE CASCADE ON DELETE RESTRICT)",
+			code: `CREATE TABLE "t" (
+	"a" INT REFERENCES "u",
+	"b" INT REFERENCES "u" ("id") ON DELETE SET NULL,
+	"c" INT,
+	FOREIGN KEY ("b", "c") REFERENCES "v" ("x", "y") ON DELETE RESTRICT ON UPDATE CASCADE
+);`,
+		},
+		{
+			source: "CREATE TABLE t (a INT UNIQUE NOT NULL, b TEXT, PRIMARY KEY (a, b), UNIQUE (b, a))",
+			code: `CREATE TABLE "t" (
+	"a" INT UNIQUE NOT NULL,
+	"b" TEXT,
+	PRIMARY KEY ("a", "b"),
+	UNIQUE ("b", "a")
+);`,
+		},
+		{
+			source: "CREATE UNIQUE INDEX t_idx ON t (a, lower(b))",
+			code:   `CREATE UNIQUE INDEX "t_idx" ON "t" ("a", lower("b"));`,
+		},
+		{
+			source: "INSERT INTO t (b, a) VALUES ('x', 1)",
+			code:   `INSERT INTO "t" ("b", "a") VALUES ('x', 1);`,
+		},
+		{
+			source: "CREATE TABLE t (a INT[], b NUMERIC(5, 2)[])",
+			code: `CREATE TABLE "t" (
+	"a" INT[],
+	"b" NUMERIC(5, 2)[]
+);`,
+		},
+		{
+			source: "SELECT ARRAY[1, 2][1], -a[b + 1], '{x}'::text[], CAST(a AS int[])[2], ARRAY[] || a, a && b FROM t",
+			code: `SELECT
+	ARRAY[1, 2][1],
+	(-"a"[("b" + 1)]),
+	CAST('{x}' AS TEXT[]),
+	CAST("a" AS INT[])[2],
+	(ARRAY[] || "a"),
+	("a" && "b")
+FROM
+	"t";`,
+		},
+		{
+			source: "SELECT a FROM t WHERE 1 = ANY(a) AND b <> ALL (SELECT c FROM u)",
+			code: `SELECT
+	"a"
+FROM
+	"t"
+WHERE
+	((1 = ANY("a")) and ("b" <> ALL(SELECT
+	"c"
+FROM
+	"u")));`,
+		},
+		{
+			source: "SELECT x, y FROM t, unnest(t.a) AS x LEFT JOIN unnest(ARRAY[1]) y ON true",
+			code: `SELECT
+	"x",
+	"y"
+FROM
+	"t"
+	CROSS JOIN unnest("t"."a") AS "x"
+	LEFT JOIN unnest(ARRAY[1]) AS "y" ON true;`,
+		},
+	}
+
+	for _, test := range tests {
+		parser := Parser{HelpMessagesDisabled: true}
+		ast, err := parser.Parse(test.source)
+		assert.Nil(t, err, test.source)
+		assert.Equal(t, test.code, ast.Statements[0].GenerateCode(), test.source)
+
+		// Generated code must parse back to the same statement
+		ast, err = parser.Parse(test.code)
+		assert.Nil(t, err, test.code)
+		assert.Equal(t, test.code, ast.Statements[0].GenerateCode(), test.code)
+	}
+}
@@ -54,6 +54,70 @@ func doSelect(mb Backend, slct *SelectStatement) error {
 						r = "f"
 					}
 				}
+			case SmallIntType:
+				i := cell.AsSmallInt()
+				if i != nil {
+					r = fmt.Sprintf("%d", *i)
+				}
+			case BigIntType:
+				i := cell.AsBigInt()
+				if i != nil {
+					r = fmt.Sprintf("%d", *i)
+				}
+			case RealType:
+				f := cell.AsReal()
+				if f != nil {
+					r = formatFloat(float64(*f), 32)
+				}
+			case DoubleType:
+				f := cell.AsDouble()
+				if f != nil {
+					r = formatFloat(*f, 64)
+				}
+			case NumericType:
+				s := cell.AsNumeric()
+				if s != nil {
+					r = *s
+				}
+			case DateType:
+				t := cell.AsDate()
+				if t != nil {
+					r = formatDate(*t)
+				}
+			case TimeType:
+				t := cell.AsTime()
+				if t != nil {
+					r = formatTime(*t)
+				}
+			case TimestampType:
+				t := cell.AsTimestamp()
+				if t != nil {
+					r = formatTimestamp(*t)
+				}
+			case IntervalType:
+				i := cell.AsInterval()
+				if i != nil {
+					r = i.String()
+				}
+			case ByteaType:
+				b := cell.AsBytea()
+				if b != nil {
+					r = formatBytea(*b)
+				}
+			case UUIDType:
+				s := cell.AsUUID()
+				if s != nil {
+					r = *s
+				}
+			case JSONType, JSONBType:
+				s := cell.AsJSON()
+				if s != nil {
+					r = *s
+				}
+			default:
+				if typ.IsArray() && cell.AsArray() != nil {
+					r = arrayToText(cell.(memoryCell), typ)
+				}
 			}
 
 			row = append(row, r)
@@ -97,24 +161,23 @@ func debugTable(b Backend, name string) {
 	fmt.Printf("Table \"%s\"\n", name)
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Column", "Type", "Nullable"})
+	table.SetHeader([]string{"Column", "Type", "Nullable", "Default"})
 	table.SetAutoFormatHeaders(false)
 	table.SetBorder(false)
 
 	rows := [][]string{}
-	for _, c := range tm.Columns {
-		typeString := "integer"
-		switch c.Type {
-		case TextType:
-			typeString = "text"
-		case BoolType:
-			typeString = "boolean"
-		}
+	for i, c := range tm.Columns {
+		typeString := columnTypeName(c.Type)
 		nullable := ""
 		if c.NotNull {
 			nullable = "not null"
 		}
-		rows = append(rows, []string{c.Name, typeString, nullable})
+		// Backends may not report defaults
+		def := ""
+		if i < len(tm.Defaults) {
+			def = tm.Defaults[i]
+		}
+		rows = append(rows, []string{c.Name, typeString, nullable, def})
 	}
 
 	table.AppendBulk(rows)
@@ -136,6 +199,30 @@ func debugTable(b Backend, name string) {
 		fmt.Printf("\t\"%s\" %s (%s)\n", index.Name, strings.Join(attributes, ", "), index.Exp)
 	}
 
+	if len(tm.Checks) > 0 {
+		fmt.Println("Check constraints:")
+	}
+
+	for _, check := range tm.Checks {
+		fmt.Printf("\tCHECK (%s)\n", check)
+	}
+
+	if len(tm.ForeignKeys) > 0 {
+		fmt.Println("Foreign-key constraints:")
+	}
+
+	for _, fk := range tm.ForeignKeys {
+		actions := ""
+		if fk.OnDelete != NoActionReferentialAction {
+			actions += " ON DELETE " + fk.OnDelete.GenerateCode()
+		}
+		if fk.OnUpdate != NoActionReferentialAction {
+			actions += " ON UPDATE " + fk.OnUpdate.GenerateCode()
+		}
+
+		fmt.Printf("\t\"%s\" FOREIGN KEY (%s) REFERENCES %s(%s)%s\n", fk.Name, strings.Join(fk.Columns, ", "), fk.Table, strings.Join(fk.RefColumns, ", "), actions)
+	}
+
 	fmt.Println("")
 }
 
@@ -256,6 +343,18 @@ repl:
 					fmt.Println("Error inserting values:", err)
 					continue repl
 				}
+			case UpdateKind:
+				err = b.Update(stmt.UpdateStatement)
+				if err != nil {
+					fmt.Println("Error updating values:", err)
+					continue repl
+				}
+			case DeleteKind:
+				err = b.Delete(stmt.DeleteStatement)
+				if err != nil {
+					fmt.Println("Error deleting values:", err)
+					continue repl
+				}
 			case SelectKind:
 				err := doSelect(b, stmt.SelectStatement)
 				if err != nil {